@@ -0,0 +1,255 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "runtime"
+  "sort"
+  "strings"
+  "sync"
+  "text/tabwriter"
+
+  "github.com/asnodgrass/columbus-v1000/export"
+)
+
+var batchJobs = runtime.NumCPU()
+var batchForce bool
+var batchInclude = "*.gps"
+
+// batchInput is an input file found from the command line, with the
+// directory its output paths are taken relative to
+type batchInput struct {
+  path string
+  root string
+}
+
+// batchJob converts one input file to the outputs that need writing
+type batchJob struct {
+  in string
+  formats []export.Format
+  outputs []string
+  skipped int // outputs that already exist
+  err error
+}
+
+// findInputs expands files, globs and directories into input files.
+// Directories are searched recursively for files matching --include.
+func findInputs(args []string) ([]batchInput, error) {
+  var inputs []batchInput
+  seen := map[string]bool{}
+  add := func(path, root string) {
+    path = filepath.Clean(path)
+    if !seen[path] {
+      seen[path] = true
+      inputs = append(inputs, batchInput{path, root})
+    }
+  }
+  walk := func(dir string) error {
+    var found []string
+    err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+      if err != nil {
+        return err
+      }
+      if info.IsDir() {
+        return nil
+      }
+      match, err := filepath.Match(strings.ToLower(batchInclude), strings.ToLower(info.Name()))
+      if match {
+        found = append(found, path)
+      }
+      return err
+    })
+    for _, path := range found {
+      add(path, dir)
+    }
+    return err
+  }
+
+  for _, arg := range args {
+    paths := []string{arg}
+    if strings.ContainsAny(arg, "*?[") {
+      matches, err := filepath.Glob(arg)
+      if err != nil {
+        return nil, err
+      }
+      if len(matches) == 0 {
+        return nil, fmt.Errorf("%s: no matching files", arg)
+      }
+      paths = matches
+    }
+    for _, path := range paths {
+      info, err := os.Stat(path)
+      if err != nil {
+        return nil, err
+      }
+      if info.IsDir() {
+        if err := walk(path); err != nil {
+          return nil, err
+        }
+      } else {
+        add(path, filepath.Dir(path))
+      }
+    }
+  }
+  return inputs, nil
+}
+
+// planJobs works out each input's output paths, mirroring the input tree
+// under --out-dir, or beside the input without it. Outputs that exist are
+// left alone unless --force is given.
+func planJobs(inputs []batchInput, formats []export.Format) []*batchJob {
+  var jobs []*batchJob
+  claimed := map[string]string{}
+  for _, input := range inputs {
+    job := &batchJob{in: input.path}
+    jobs = append(jobs, job)
+    base := outDir
+    if base == "" {
+      base = input.root
+    }
+    rel, err := filepath.Rel(input.root, input.path)
+    if err != nil {
+      job.err = err
+      continue
+    }
+    dir := filepath.Join(base, filepath.Dir(rel))
+    name, err := outputName(input.path)
+    if err != nil {
+      job.err = err
+      continue
+    }
+    for _, f := range formats {
+      out := filepath.Join(dir, name + f.Extension)
+      if out == input.path {
+        job.err = fmt.Errorf("%s would overwrite the input", out)
+        break
+      }
+      if other, ok := claimed[out]; ok {
+        job.err = fmt.Errorf("%s is also the output of %s", out, other)
+        break
+      }
+      claimed[out] = input.path
+      if _, err := os.Stat(out); err == nil && !batchForce {
+        job.skipped++
+        continue
+      }
+      job.formats = append(job.formats, f)
+      job.outputs = append(job.outputs, out)
+    }
+  }
+  return jobs
+}
+
+// run converts the job's input, removing its outputs if it fails
+func (j *batchJob) run() error {
+  var files []io.WriteCloser
+  var outputs []io.Writer
+  for _, path := range j.outputs {
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+      return err
+    }
+    f, err := createFile(path)
+    if err != nil {
+      return err
+    }
+    files = append(files, f)
+    outputs = append(outputs, f)
+  }
+  err := convertFile(j.in, j.formats, outputs, segmentGap)
+  for _, f := range files {
+    if cerr := f.Close(); err == nil {
+      err = cerr
+    }
+  }
+  if err != nil {
+    for _, path := range j.outputs {
+      os.Remove(path)
+    }
+  }
+  return err
+}
+
+// runBatch converts jobs with a pool of --jobs workers, and returns the
+// number that failed
+func runBatch(jobs []*batchJob) int {
+  workers := batchJobs
+  if workers < 1 {
+    workers = 1
+  }
+  queue := make(chan *batchJob)
+  var wg sync.WaitGroup
+  for i := 0; i < workers; i++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for job := range queue {
+        job.err = job.run()
+      }
+    }()
+  }
+  for _, job := range jobs {
+    if job.err == nil && len(job.outputs) > 0 {
+      queue <- job
+    }
+  }
+  close(queue)
+  wg.Wait()
+
+  failed := 0
+  for _, job := range jobs {
+    if job.err != nil {
+      failed++
+    }
+  }
+  return failed
+}
+
+// writeBatchSummary writes a table of each input's outcome, and the totals
+func writeBatchSummary(out io.Writer, jobs []*batchJob) {
+  sorted := append([]*batchJob(nil), jobs...)
+  sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].in < sorted[j].in })
+
+  var converted, skipped, failed int
+  tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+  fmt.Fprintln(tw, "FILE\tSTATUS\tDETAIL")
+  for _, job := range sorted {
+    var status, detail string
+    switch {
+    case job.err != nil:
+      status, detail = "failed", job.err.Error()
+      failed++
+    case len(job.outputs) == 0:
+      status, detail = "skipped", "outputs exist (use --force to replace them)"
+      skipped++
+    default:
+      status, detail = "ok", fmt.Sprintf("%d written", len(job.outputs))
+      if job.skipped > 0 {
+        detail += fmt.Sprintf(", %d existing skipped", job.skipped)
+      }
+      converted++
+    }
+    fmt.Fprintf(tw, "%s\t%s\t%s\n", job.in, status, detail)
+  }
+  tw.Flush()
+  fmt.Fprintf(out, "%d converted, %d skipped, %d failed\n", converted, skipped, failed)
+}
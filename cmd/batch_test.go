@@ -0,0 +1,150 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+
+  "github.com/asnodgrass/columbus-v1000/export"
+)
+
+// batchTree writes device CSV logs to dir/a/one.log and dir/a/b/two.log,
+// and an unreadable dir/a/bad.log
+func batchTree(t *testing.T, dir string) {
+  os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+  log := writeDeviceCSV(t, dir)
+  data, _ := ioutil.ReadFile(log)
+  os.Remove(log)
+  ioutil.WriteFile(filepath.Join(dir, "a", "one.log"), data, 0644)
+  ioutil.WriteFile(filepath.Join(dir, "a", "b", "two.log"), data, 0644)
+  ioutil.WriteFile(filepath.Join(dir, "a", "bad.log"), []byte("garbage"), 0644)
+  ioutil.WriteFile(filepath.Join(dir, "a", "notes.txt"), []byte("notes"), 0644)
+}
+
+func Test_findInputs(t *testing.T) {
+  t.Log("Checking whether findInputs expands directories, globs and files..")
+  defer func() { batchInclude = "*.gps" }()
+  batchInclude = "*.LOG"
+  dir := t.TempDir()
+  batchTree(t, dir)
+
+  inputs, err := findInputs([]string{dir, filepath.Join(dir, "a", "*.log"), filepath.Join(dir, "a", "notes.txt")})
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var found []string
+  for _, input := range inputs {
+    rel, _ := filepath.Rel(input.root, input.path)
+    found = append(found, rel)
+  }
+  expected := "a/b/two.log a/bad.log a/one.log notes.txt"
+  if strings.Join(found, " ") != filepath.FromSlash(expected) {
+    t.Errorf("Expected %s, got %s", expected, strings.Join(found, " "))
+  }
+
+  if _, err := findInputs([]string{filepath.Join(dir, "*.gps")}); err == nil {
+    t.Errorf("Expected an error for a glob with no matches")
+  }
+  if _, err := findInputs([]string{filepath.Join(dir, "missing.gps")}); err == nil {
+    t.Errorf("Expected an error for a missing file")
+  }
+}
+
+func Test_planJobs(t *testing.T) {
+  t.Log("Checking whether planJobs mirrors the input tree and skips existing outputs..")
+  defer func() { outDir, batchForce = "", false }()
+  dir := t.TempDir()
+  outDir = filepath.Join(dir, "out")
+  gpx, _ := export.Lookup("gpx")
+  csv, _ := export.Lookup("csv")
+  inputs := []batchInput{
+    {filepath.Join(dir, "in", "x", "trip.gps"), filepath.Join(dir, "in")},
+    {filepath.Join(dir, "other", "x", "trip.gps"), filepath.Join(dir, "other")},
+  }
+  os.MkdirAll(filepath.Join(outDir, "x"), 0755)
+  ioutil.WriteFile(filepath.Join(outDir, "x", "trip.csv"), nil, 0644)
+
+  jobs := planJobs(inputs, []export.Format{gpx, csv})
+  if len(jobs[0].outputs) != 1 || jobs[0].outputs[0] != filepath.Join(outDir, "x", "trip.gpx") || jobs[0].skipped != 1 {
+    t.Errorf("Expected trip.gpx under the mirrored x, and trip.csv skipped, got %v", jobs[0].outputs)
+  }
+  if jobs[1].err == nil {
+    t.Errorf("Expected an error for two inputs with the same output")
+  }
+
+  batchForce = true
+  jobs = planJobs(inputs[:1], []export.Format{gpx, csv})
+  if len(jobs[0].outputs) != 2 {
+    t.Errorf("Expected --force to replace trip.csv, got %v", jobs[0].outputs)
+  }
+
+  outDir = ""
+  jobs = planJobs([]batchInput{{filepath.Join(dir, "trip.csv"), dir}}, []export.Format{csv})
+  if jobs[0].err == nil {
+    t.Errorf("Expected an error for an output that would overwrite its input")
+  }
+}
+
+func Test_runBatch(t *testing.T) {
+  t.Log("Checking whether runBatch converts concurrently and reports failures..")
+  defer func(jobs int) { outDir, batchInclude, batchJobs = "", "*.gps", jobs }(batchJobs)
+  dir := t.TempDir()
+  batchTree(t, dir)
+  outDir = filepath.Join(dir, "out")
+  batchInclude = "*.log"
+  batchJobs = 2
+
+  inputs, err := findInputs([]string{filepath.Join(dir, "a")})
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  kml, _ := export.Lookup("kml")
+  jobs := planJobs(inputs, []export.Format{kml})
+  if failed := runBatch(jobs); failed != 1 {
+    t.Errorf("Expected 1 failure, got %d", failed)
+  }
+  for _, path := range []string{"one.kml", "b/two.kml"} {
+    if _, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(path))); err != nil {
+      t.Errorf("Expected %s, got %v", path, err)
+    }
+  }
+  if _, err := os.Stat(filepath.Join(outDir, "bad.kml")); !os.IsNotExist(err) {
+    t.Errorf("Expected the failed output to be removed")
+  }
+
+  var buf bytes.Buffer
+  writeBatchSummary(&buf, jobs)
+  lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+  if len(lines) != 5 || !strings.Contains(lines[2], "failed") || lines[4] != "2 converted, 0 skipped, 1 failed" {
+    t.Errorf("Expected a summary table, got %s", buf.String())
+  }
+
+  jobs = planJobs(inputs[:1], []export.Format{kml})
+  runBatch(jobs)
+  buf.Reset()
+  writeBatchSummary(&buf, jobs)
+  if !strings.Contains(buf.String(), "skipped") {
+    t.Errorf("Expected the second run to skip, got %s", buf.String())
+  }
+}
@@ -0,0 +1,128 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "bytes"
+  "compress/gzip"
+  "io"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/klauspost/compress/zstd"
+  "github.com/ulikunitz/xz"
+)
+
+// compression is a stream format, recognised by its magic bytes on input and
+// by its file name extension on output
+type compression struct {
+  ext string
+  magic []byte
+  reader func(r io.Reader) (io.ReadCloser, error)
+  writer func(w io.Writer) (io.WriteCloser, error)
+}
+
+var compressions = []compression{
+  {".gz", []byte{0x1f, 0x8b},
+    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+    func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }},
+  {".zst", []byte{0x28, 0xb5, 0x2f, 0xfd},
+    func(r io.Reader) (io.ReadCloser, error) {
+      d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+      if err != nil {
+        return nil, err
+      }
+      return d.IOReadCloser(), nil
+    },
+    func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }},
+  {".xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+    func(r io.Reader) (io.ReadCloser, error) {
+      x, err := xz.NewReader(r)
+      return ioutil.NopCloser(x), err
+    },
+    func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }},
+}
+
+// decompress returns r, decompressed if it starts with the magic bytes of a
+// known compression
+func decompress(r io.Reader) (io.ReadCloser, error) {
+  br := bufio.NewReader(r)
+  for _, c := range compressions {
+    magic, _ := br.Peek(len(c.magic))
+    if bytes.Equal(magic, c.magic) {
+      return c.reader(br)
+    }
+  }
+  return ioutil.NopCloser(br), nil
+}
+
+// compressionFor returns the compression named by path's extension, if any
+func compressionFor(path string) (compression, bool) {
+  ext := strings.ToLower(filepath.Ext(path))
+  for _, c := range compressions {
+    if c.ext == ext {
+      return c, true
+    }
+  }
+  return compression{}, false
+}
+
+// compressedFile closes its compressor, then the file beneath it
+type compressedFile struct {
+  io.WriteCloser
+  file *os.File
+}
+
+func (f compressedFile) Close() error {
+  err := f.WriteCloser.Close()
+  if cerr := f.file.Close(); err == nil {
+    err = cerr
+  }
+  return err
+}
+
+// createFile creates path, compressed according to its extension, so
+// trip.gpx.gz is a gzipped GPX file
+func createFile(path string) (io.WriteCloser, error) {
+  file, err := os.Create(path)
+  if err != nil {
+    return nil, err
+  }
+  c, ok := compressionFor(path)
+  if !ok {
+    return file, nil
+  }
+  w, err := c.writer(file)
+  if err != nil {
+    file.Close()
+    return nil, err
+  }
+  return compressedFile{w, file}, nil
+}
+
+// trimCompressionExt removes a compression extension from path
+func trimCompressionExt(path string) string {
+  if _, ok := compressionFor(path); ok {
+    return strings.TrimSuffix(path, filepath.Ext(path))
+  }
+  return path
+}
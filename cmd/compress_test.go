@@ -0,0 +1,96 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func Test_createFile(t *testing.T) {
+  t.Log("Checking whether output is compressed by extension and input by magic..")
+  dir := t.TempDir()
+  data := "INDEX,TAG\r\n1,T\r\n"
+  for _, name := range []string{"trip.csv", "trip.csv.gz", "trip.csv.ZST", "trip.csv.xz"} {
+    path := filepath.Join(dir, name)
+    w, err := createFile(path)
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    w.Write([]byte(data))
+    if err := w.Close(); err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    raw, _ := ioutil.ReadFile(path)
+    if compressed := string(raw) != data; compressed != (name != "trip.csv") {
+      t.Errorf("Expected %s to be compressed: %v", name, name != "trip.csv")
+    }
+
+    f, _ := os.Open(path)
+    r, err := decompress(f)
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    out, err := ioutil.ReadAll(r)
+    r.Close()
+    f.Close()
+    if err != nil || string(out) != data {
+      t.Errorf("Expected %s to read back as %q, got %q (%v)", name, data, out, err)
+    }
+  }
+}
+
+func Test_createOutput(t *testing.T) {
+  t.Log("Checking whether closing the output leaves stdout open..")
+  defer func() { outFile = "" }()
+  for _, name := range []string{"", "-"} {
+    outFile = name
+    out, err := createOutput()
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    if err := out.Close(); err != nil {
+      t.Errorf("Unexpected error: %v", err)
+    }
+    if _, err := os.Stdout.Stat(); err != nil {
+      t.Errorf("Expected stdout to be open, got %v", err)
+    }
+  }
+}
+
+func Test_trimCompressionExt(t *testing.T) {
+  t.Log("Checking whether compression extensions are ignored in names..")
+  cases := map[string]string{"trip.gps.gz": "trip.gps", "trip.gps.xz": "trip.gps", "trip.gps": "trip.gps", "trip.zip": "trip.zip"}
+  for in, expected := range cases {
+    if out := trimCompressionExt(in); out != expected {
+      t.Errorf("Expected %s, got %s", expected, out)
+    }
+  }
+  if out := filenamePrefix("/logs/trip.gps.zst"); out != "trip" {
+    t.Errorf("Expected trip, got %s", out)
+  }
+  if out := filenamePrefix("-"); out != "stdin" {
+    t.Errorf("Expected stdin, got %s", out)
+  }
+  if out := tsdbBatchPath("out/trip.lp.gz", 2); out != "out/trip-0002.lp.gz" {
+    t.Errorf("Expected out/trip-0002.lp.gz, got %s", out)
+  }
+}
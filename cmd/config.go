@@ -0,0 +1,241 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "text/tabwriter"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/spf13/viper"
+)
+
+// configEnvPrefix starts the environment variables that override settings
+const configEnvPrefix = "COLUMBUS_V1000"
+
+// configSetting is a config file key and the flag it gives a default for
+type configSetting struct {
+  key string
+  flag string
+  fallback string // the flag's own default, for config show
+}
+
+// configSettings lists every setting. filter.to sets --until on commands
+// whose --to names formats.
+var configSettings = []configSetting{
+  {"timezone", "timezone", "UTC"},
+  {"units", "units", "metric"},
+  {"out-dir", "out-dir", ""},
+  {"filename", "filename", "{{.Name}}"},
+  {"segment-gap", "segment-gap", "5m0s"},
+  {"device", "device", ""},
+  {"filter.from", "from", ""},
+  {"filter.to", "to", ""},
+  {"filter.bbox", "bbox", ""},
+  {"filter.within", "within", ""},
+  {"filter.type", "type", ""},
+  {"filter.where", "where", ""},
+}
+
+var (
+  profile string
+  config = viper.New()
+  configDevice *viper.Viper // the profile's section of the config file
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+  Use:   "config",
+  Short: "Works with the config file",
+  Long: `Settings can be given defaults in a YAML config file, read from --config,
+$COLUMBUS_V1000_CONFIG or ~/.config/columbus-v1000/config.yaml:
+
+  timezone: Europe/London
+  units: imperial          # for info: metric or imperial
+  out-dir: exports         # for convert
+  filename: "{{.Device}}-{{.Name}}"
+  segment-gap: 10m
+  filter:
+    from: 2017-04-01
+    to: 2017-04-30         # --until on convert
+    bbox: -1,51,1,52
+    within: areas.geojson
+    type: trackpoint
+    where: speed > 5
+  devices:
+    bike:
+      timezone: Europe/Paris
+      device: "12345"      # the tsdb --device serial
+      filter:
+        where: speed > 10
+
+The devices section holds a profile per device, chosen with --profile (or
+$COLUMBUS_V1000_PROFILE, or a top level profile key), whose settings take the
+place of the ones above. Environment variables override the file: each key in
+upper case with . and - as _, after COLUMBUS_V1000_, as in
+COLUMBUS_V1000_TIMEZONE or COLUMBUS_V1000_FILTER_WHERE. Flags given on the
+command line override everything.`,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+  Use:   "show",
+  Short: "Prints the settings in effect and where they come from",
+  Run: func(cmd *cobra.Command, args []string) {
+    if err := writeConfigShow(os.Stdout); err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(configCmd)
+  configCmd.AddCommand(configShowCmd)
+}
+
+// defaultConfigDir is where the config file is looked for without --config
+func defaultConfigDir() string {
+  if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+    return filepath.Join(dir, "columbus-v1000")
+  }
+  home, err := os.UserHomeDir()
+  if err != nil {
+    return ""
+  }
+  return filepath.Join(home, ".config", "columbus-v1000")
+}
+
+// loadConfig reads the config file, if there is one, and merges in the
+// chosen device profile
+func loadConfig() error {
+  config = viper.New()
+  configDevice = nil
+  config.SetEnvPrefix(configEnvPrefix)
+  config.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+  config.AutomaticEnv()
+
+  path := cfgFile
+  if path == "" {
+    path = os.Getenv(configEnvPrefix + "_CONFIG")
+  }
+  if path != "" {
+    config.SetConfigFile(path)
+    if err := config.ReadInConfig(); err != nil {
+      return fmt.Errorf("config file %s: %v", path, err)
+    }
+  } else if dir := defaultConfigDir(); dir != "" {
+    config.SetConfigName("config")
+    config.AddConfigPath(dir)
+    if err := config.ReadInConfig(); err != nil {
+      if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+        return fmt.Errorf("config file: %v", err)
+      }
+    }
+  }
+
+  if profile == "" {
+    profile = config.GetString("profile")
+  }
+  if profile != "" {
+    configDevice = config.Sub("devices." + profile)
+    if configDevice == nil {
+      return fmt.Errorf("no device profile %q in the config file", profile)
+    }
+    if err := config.MergeConfigMap(configDevice.AllSettings()); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// applyConfig sets the flags of cmd that were not given on the command line
+// from the config
+func applyConfig(cmd *cobra.Command) error {
+  for _, s := range configSettings {
+    name := s.flag
+    if name == "to" && cmd.Flags().Lookup("until") != nil {
+      name = "until"
+    }
+    f := cmd.Flags().Lookup(name)
+    if f == nil || f.Changed || !config.IsSet(s.key) {
+      continue
+    }
+    if err := f.Value.Set(configString(s.key)); err != nil {
+      return fmt.Errorf("config %s: %v", s.key, err)
+    }
+  }
+  return nil
+}
+
+// configString is a setting as a flag would take it. YAML reads an unquoted
+// date such as 2017-04-01 as a timestamp, which goes back to the form
+// --from and --to take.
+func configString(key string) string {
+  if t, ok := config.Get(key).(time.Time); ok {
+    if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+      return t.Format("2006-01-02")
+    }
+    return t.Format("2006-01-02 15:04:05")
+  }
+  return config.GetString(key)
+}
+
+// configEnv is the environment variable that overrides a key
+func configEnv(key string) string {
+  return configEnvPrefix + "_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func writeConfigShow(out io.Writer) error {
+  tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+  file := config.ConfigFileUsed()
+  if file == "" {
+    file = "none"
+  }
+  fmt.Fprintf(tw, "config file:\t%s\n", file)
+  var profiles []string
+  for name := range config.GetStringMap("devices") {
+    profiles = append(profiles, name)
+  }
+  sort.Strings(profiles)
+  fmt.Fprintf(tw, "profiles:\t%s\n", strings.Join(profiles, ", "))
+  fmt.Fprintf(tw, "profile:\t%s\n\n", profile)
+
+  for _, s := range configSettings {
+    value, source := s.fallback, "default"
+    if _, ok := os.LookupEnv(configEnv(s.key)); ok {
+      source = "env " + configEnv(s.key)
+    } else if configDevice != nil && configDevice.IsSet(s.key) {
+      source = "profile " + profile
+    } else if config.IsSet(s.key) {
+      source = "config file"
+    }
+    if source != "default" {
+      value = configString(s.key)
+    }
+    fmt.Fprintf(tw, "%s\t%s\t%s\n", s.key, value, source)
+  }
+  return tw.Flush()
+}
@@ -0,0 +1,159 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/spf13/viper"
+)
+
+const testConfig = `timezone: Europe/London
+out-dir: exports
+filter:
+  to: 2017-04-30
+  type: trackpoint
+  where: speed > 0
+devices:
+  bike:
+    timezone: Europe/Paris
+    filter:
+      where: speed > 2
+`
+
+func useTestConfig(t *testing.T, name string) {
+  cfgFile = filepath.Join(t.TempDir(), "config.yaml")
+  if err := ioutil.WriteFile(cfgFile, []byte(testConfig), 0644); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  profile = name
+  if err := loadConfig(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+}
+
+func resetConfig() {
+  cfgFile, profile = "", ""
+  config, configDevice = viper.New(), nil
+}
+
+func Test_applyConfig(t *testing.T) {
+  t.Log("Checking whether the config, profile and environment set unset flags..")
+  defer resetConfig()
+  t.Setenv("COLUMBUS_V1000_FILTER_TYPE", "poi")
+  useTestConfig(t, "bike")
+
+  var zone, dir, until, typ, where string
+  var formats []string
+  cmd := &cobra.Command{Use: "test"}
+  cmd.Flags().StringVar(&zone, "timezone", "", "")
+  cmd.Flags().StringVar(&dir, "out-dir", "", "")
+  cmd.Flags().StringSliceVar(&formats, "to", nil, "")
+  cmd.Flags().StringVar(&until, "until", "", "")
+  cmd.Flags().StringVar(&typ, "type", "", "")
+  cmd.Flags().StringVar(&where, "where", "", "")
+  cmd.Flags().Set("out-dir", "given")
+  if err := applyConfig(cmd); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if zone != "Europe/Paris" || where != "speed > 2" {
+    t.Errorf("Expected the bike profile's timezone and filter, got %s and %s", zone, where)
+  }
+  if typ != "poi" {
+    t.Errorf("Expected the environment to override the file, got %s", typ)
+  }
+  if dir != "given" {
+    t.Errorf("Expected the command line to win, got %s", dir)
+  }
+  if until != "2017-04-30" || len(formats) != 0 {
+    t.Errorf("Expected filter.to to set --until, got %s and %v", until, formats)
+  }
+
+  profile = "car"
+  if err := loadConfig(); err == nil {
+    t.Errorf("Expected an error for an unknown profile")
+  }
+}
+
+func Test_configString(t *testing.T) {
+  t.Log("Checking whether unquoted config dates reach --from and --until as dates..")
+  defer resetConfig()
+  cfgFile = filepath.Join(t.TempDir(), "config.yaml")
+  if err := ioutil.WriteFile(cfgFile, []byte("filter:\n  from: 2017-04-01\n  to: 2017-04-30\n"), 0644); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if err := loadConfig(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  cmd := &cobra.Command{Use: "test"}
+  addFilterFlags(cmd)
+  defer func() { filterFrom, filterTo = "", "" }()
+  if err := applyConfig(cmd); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if filterFrom != "2017-04-01" || filterTo != "2017-04-30" {
+    t.Errorf("Expected 2017-04-01 and 2017-04-30, got %s and %s", filterFrom, filterTo)
+  }
+
+  // YAML decoders that give timestamps as time.Time
+  config.Set("filter.from", time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC))
+  config.Set("filter.to", time.Date(2017, 4, 30, 18, 30, 0, 0, time.UTC))
+  if out := configString("filter.from"); out != "2017-04-01" {
+    t.Errorf("Expected 2017-04-01, got %s", out)
+  }
+  if out := configString("filter.to"); out != "2017-04-30 18:30:00" {
+    t.Errorf("Expected 2017-04-30 18:30:00, got %s", out)
+  }
+}
+
+func Test_writeConfigShow(t *testing.T) {
+  t.Log("Checking whether config show gives each setting's source..")
+  defer resetConfig()
+  t.Setenv("COLUMBUS_V1000_UNITS", "imperial")
+  useTestConfig(t, "bike")
+  var out bytes.Buffer
+  if err := writeConfigShow(&out); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  lines := map[string]string{}
+  for _, line := range strings.Split(out.String(), "\n") {
+    if fields := strings.Fields(line); len(fields) > 0 {
+      lines[fields[0]] = strings.Join(fields[1:], " ")
+    }
+  }
+  expected := map[string]string{
+    "profiles:": "bike",
+    "timezone": "Europe/Paris profile bike",
+    "units": "imperial env COLUMBUS_V1000_UNITS",
+    "out-dir": "exports config file",
+    "segment-gap": "5m0s default",
+    "filter.where": "speed > 2 profile bike",
+  }
+  for key, e := range expected {
+    if lines[key] != e {
+      t.Errorf("Expected %s %q, got %q", key, e, lines[key])
+    }
+  }
+}
@@ -0,0 +1,359 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "text/template"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var convertTo []string
+var outDir string
+var convertFilename string
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+  Use:   "convert [files, globs or directories...]",
+  Short: "Converts to one or more formats in a single pass",
+  Long: `Converts a Columbus V1000 GPS file to one or more formats, decoding the
+input only once. For example:
+
+  columbus-v1000 convert -i trip.gps --to gpx,csv,kml
+
+With a single --to format the output goes to --out-file, or stdout. With
+several, each goes to a file named after the input with the format's usual
+extension, in --out-dir (default: the current directory).
+
+Several inputs can be given as arguments: files, globs and directories,
+which are searched recursively for files matching --include. They are
+converted concurrently by --jobs workers, each output going beside its input,
+or with --out-dir to the same place in a mirror of the input tree. Existing
+outputs are left alone unless --force is given. A summary of each input is
+printed at the end, and the exit status is non-zero if any failed.
+
+Output files are named after the input, or by --filename, a template of
+.Name (the input's name without extension), .Device (the --profile) and
+.Modified (the input's modification time), such as
+'{{.Modified.Format "2006-01-02"}}-{{.Name}}'.
+
+Formats take their default options; use the command of the same name to
+change them. As --to names the formats, the time range filter ends with
+--until. Formats: ` + "{{formats}}",
+  Run: func(cmd *cobra.Command, args []string) {
+    if len(args) == 0 {
+      if err := runConvert(convertTo, segmentGap); err != nil {
+        fmt.Println(err)
+        os.Exit(1)
+      }
+      return
+    }
+
+    if inFile != "" {
+      args = append([]string{inFile}, args...)
+    }
+    if outFile != "" {
+      fmt.Println("error: --out-file takes a single input; use --out-dir for several")
+      os.Exit(1)
+    }
+    formats, err := lookupFormats(convertTo)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    inputs, err := findInputs(args)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    jobs := planJobs(inputs, formats)
+    failed := runBatch(jobs)
+    writeBatchSummary(os.Stdout, jobs)
+    if failed > 0 {
+      os.Exit(1)
+    }
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(convertCmd)
+  convertCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  convertCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  convertCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file, for a single format")
+  convertCmd.Flags().StringVar(&outDir, "out-dir", "", "output directory")
+  convertCmd.Flags().StringVar(&convertFilename, "filename", "", "output file name template, without extension (default: {{.Name}})")
+  convertCmd.Flags().StringSliceVarP(&convertTo, "to", "t", nil, "comma separated output formats (required)")
+  convertCmd.Flags().IntVarP(&batchJobs, "jobs", "j", batchJobs, "number of files to convert at once")
+  convertCmd.Flags().BoolVar(&batchForce, "force", false, "replace existing outputs")
+  convertCmd.Flags().StringVar(&batchInclude, "include", batchInclude, "file name pattern to convert in directories")
+  addSegmentFlags(convertCmd)
+  addFilterFlags(convertCmd)
+
+  registerWriter("csv", ".csv", "CSV, in the device's own layout", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    w, err := newCSVWriter(out, meta)
+    if err != nil {
+      return nil, err
+    }
+    return w, w.writeHeader()
+  })
+  registerWriter("gpx", ".gpx", "GPX 1.0 track", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newGPXWriter(out, meta), nil
+  })
+  registerWriter("fit", ".fit", "Garmin FIT activity", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newFITWriter(out, meta)
+  })
+  registerWriter("tcx", ".tcx", "Training Center XML", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newTCXWriter(out, meta)
+  })
+  registerWriter("nmea", ".nmea", "NMEA 0183 sentences", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newNMEAWriter(out, meta)
+  })
+  registerWriter("igc", ".igc", "IGC flight log", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newIGCWriter(out, meta)
+  })
+  registerWriter("plt", ".plt", "OziExplorer track", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newPLTWriter(out, meta)
+  })
+  registerWriter("wpt", ".wpt", "OziExplorer waypoints", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newWPTWriter(out, meta)
+  })
+  registerWriter("vbo", ".vbo", "Racelogic VBO", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newVBOWriter(out, meta)
+  })
+  registerWriter("racechrono", ".racechrono.csv", "RaceChrono CSV", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newRaceChronoWriter(out, meta)
+  })
+  registerWriter("srt", ".srt", "SRT subtitles", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newSubtitleWriter(out, meta)
+  })
+  registerWriter("wkt", ".wkt", "WKT MULTILINESTRING ZM", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newGeometryWriter(out, meta)
+  })
+  registerWriter("parquet", ".parquet", "GeoParquet", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    return newParquetWriter(out, meta)
+  })
+  registerWriter("influx", ".lp", "InfluxDB line protocol", func(out io.Writer, meta export.Meta) (recordWriter, error) {
+    w, err := newTSDBWriter(meta)
+    if err != nil {
+      return nil, err
+    }
+    w.out = out
+    return w, nil
+  })
+
+  var names []string
+  for _, f := range export.Formats() {
+    names = append(names, f.Name)
+  }
+  convertCmd.Long = strings.Replace(convertCmd.Long, "{{formats}}", strings.Join(names, ", ") + ".", 1)
+}
+
+// recordWriter is implemented by the format writers in this package
+type recordWriter interface {
+  writeRecord(rec *v1000.Record) error
+  flush() error
+}
+
+// writerExporter adapts a recordWriter to export.Exporter. The writers take
+// the input's metadata when created, so creation waits for Begin.
+type writerExporter struct {
+  out io.Writer
+  create func(out io.Writer, meta export.Meta) (recordWriter, error)
+  w recordWriter
+}
+
+func (e *writerExporter) Begin(meta export.Meta) error {
+  w, err := e.create(e.out, meta)
+  e.w = w
+  return err
+}
+
+func (e *writerExporter) WriteRecord(rec *v1000.Record) error {
+  return e.w.writeRecord(rec)
+}
+
+func (e *writerExporter) End() error {
+  if e.w == nil {
+    return nil
+  }
+  return e.w.flush()
+}
+
+// registerWriter registers one of this package's writers as an export
+// format
+func registerWriter(name, ext, description string, create func(out io.Writer, meta export.Meta) (recordWriter, error)) {
+  export.Register(export.Format{
+    Name: name,
+    Extension: ext,
+    Description: description,
+    New: func(out io.Writer) export.Exporter {
+      return &writerExporter{out: out, create: create}
+    },
+  })
+}
+
+// runConvert decodes --in-file once, writing every record to each of the
+// named formats, split into track segments at gap
+func runConvert(names []string, gap time.Duration) error {
+  if inFile == "" {
+    return fmt.Errorf("error: input file required")
+  }
+  if len(names) > 1 && outFile != "" {
+    return fmt.Errorf("error: --out-file takes a single format; use --out-dir for several")
+  }
+  formats, err := lookupFormats(names)
+  if err != nil {
+    return err
+  }
+
+  name, err := outputName(inFile)
+  if err != nil {
+    return err
+  }
+  var files []io.WriteCloser
+  var outputs []io.Writer
+  for _, f := range formats {
+    out, err := convertOutput(f, name, len(formats) > 1)
+    if err != nil {
+      for _, f := range files {
+        f.Close()
+      }
+      return err
+    }
+    files = append(files, out)
+    outputs = append(outputs, out)
+  }
+  err = convertFile(inFile, formats, outputs, gap)
+  for _, f := range files {
+    if cerr := f.Close(); err == nil {
+      err = cerr
+    }
+  }
+  return err
+}
+
+// lookupFormats resolves format names, as given to --to
+func lookupFormats(names []string) ([]export.Format, error) {
+  if len(names) == 0 {
+    return nil, fmt.Errorf("error: output format required")
+  }
+  var formats []export.Format
+  for _, name := range names {
+    f, err := export.Lookup(strings.ToLower(strings.TrimSpace(name)))
+    if err != nil {
+      return nil, err
+    }
+    formats = append(formats, f)
+  }
+  return formats, nil
+}
+
+// buildExport builds the filter from the flags, and the metadata the formats
+// write the input at path with
+func buildExport(path string) (v1000.Filter, export.Meta, error) {
+  loc, err := location()
+  if err != nil {
+    return nil, export.Meta{}, err
+  }
+  filter, env, err := buildFilter()
+  if err != nil {
+    return nil, export.Meta{}, err
+  }
+  meta := export.Meta{
+    Name: filenamePrefix(path),
+    Source: sourceName(path),
+    Location: loc,
+    SegmentGap: segmentGap,
+    Env: env,
+  }
+  return filter, meta, nil
+}
+
+// convertFile decodes the file at path once, writing every record to each
+// format's output, split into track segments at gap
+func convertFile(path string, formats []export.Format, outputs []io.Writer, gap time.Duration) error {
+  filter, meta, err := buildExport(path)
+  if err != nil {
+    return err
+  }
+  meta.SegmentGap = gap
+
+  exporters := make([]export.Exporter, len(formats))
+  for i, f := range formats {
+    exporters[i] = f.New(outputs[i])
+  }
+  e := export.Multi(exporters...)
+  if err := e.Begin(meta); err != nil {
+    return err
+  }
+  err = readFile(path, filter, func(rec *v1000.Record) error {
+    return e.WriteRecord(rec)
+  })
+  if err != nil {
+    return err
+  }
+  return e.End()
+}
+
+// convertOutput creates the output for a format: --out-file if given, else
+// stdout for a single format, and otherwise a file named after the input in
+// --out-dir
+func convertOutput(f export.Format, name string, several bool) (io.WriteCloser, error) {
+  if outFile != "" || (!several && outDir == "") {
+    return createOutput()
+  }
+  return createFile(filepath.Join(outDir, name + f.Extension))
+}
+
+// outputName is the name of the outputs for the input at path, without an
+// extension, per --filename
+func outputName(path string) (string, error) {
+  name := filenamePrefix(path)
+  if convertFilename == "" {
+    return name, nil
+  }
+  tmpl, err := template.New("filename").Parse(convertFilename)
+  if err != nil {
+    return "", fmt.Errorf("--filename: %v", err)
+  }
+  data := struct {
+    Name string
+    Device string
+    Modified time.Time
+  }{Name: name, Device: profile}
+  if info, err := os.Stat(path); err == nil && path != "-" {
+    data.Modified = info.ModTime()
+  }
+  var b strings.Builder
+  if err := tmpl.Execute(&b, data); err != nil {
+    return "", fmt.Errorf("--filename: %v", err)
+  }
+  if b.Len() == 0 || strings.ContainsAny(b.String(), `/\`) {
+    return "", fmt.Errorf("--filename: %q is not a file name", b.String())
+  }
+  return b.String(), nil
+}
@@ -0,0 +1,125 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/export"
+)
+
+// testMeta is the metadata buildExport gives the input at path, in UTC
+func testMeta(path string) export.Meta {
+  return export.Meta{Name: filenamePrefix(path), Source: sourceName(path), Location: time.UTC, SegmentGap: 5 * time.Minute}
+}
+
+// writeDeviceCSV writes a small device CSV log to dir
+func writeDeviceCSV(t *testing.T, dir string) string {
+  path := filepath.Join(dir, "trip.csv")
+  data := "INDEX,TAG,DATE,TIME,LATITUDE N/S,LONGITUDE E/W,HEIGHT,SPEED,HEADING,PRES,TEMP\r\n" +
+    "1,T,170401,123456,34.987654S,99.123456E,10,1.5,180,1000.2,20\r\n" +
+    "2,C,170401,123500,34.987000S,99.124000E,12,5.0,90,1000.1,21\r\n" +
+    "3,T,170401,130000,34.980000S,99.130000E,20,5.0,90,1000.0,21\r\n"
+  if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return path
+}
+
+func Test_runConvert(t *testing.T) {
+  t.Log("Checking whether convert writes several formats in one pass..")
+  defer func() { inFile, outFile, outDir, filterTo = "", "", "", "" }()
+  dir := t.TempDir()
+  inFile = writeDeviceCSV(t, dir)
+  outDir = filepath.Join(dir, "out")
+  os.Mkdir(outDir, 0755)
+  filterTo = "2017-04-01T12:40:00Z"
+
+  if err := runConvert([]string{"gpx", "CSV", "kml"}, segmentGap); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  csv, err := ioutil.ReadFile(filepath.Join(outDir, "trip.csv"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if lines := strings.Split(strings.TrimSpace(string(csv)), "\r\n"); len(lines) != 3 {
+    t.Errorf("Expected a header and two filtered rows, got %q", lines)
+  }
+  gpx, _ := ioutil.ReadFile(filepath.Join(outDir, "trip.gpx"))
+  if strings.Count(string(gpx), "<trkpt") != 2 {
+    t.Errorf("Expected two trackpoints in the GPX, got %s", gpx)
+  }
+  if _, err := os.Stat(filepath.Join(outDir, "trip.kml")); err != nil {
+    t.Errorf("Expected trip.kml, got %v", err)
+  }
+
+  outFile = filepath.Join(dir, "one.gpx")
+  if err := runConvert([]string{"gpx", "csv"}, segmentGap); err == nil {
+    t.Errorf("Expected an error for --out-file with two formats")
+  }
+  if err := runConvert([]string{"gpx"}, segmentGap); err != nil {
+    t.Errorf("Unexpected error: %v", err)
+  }
+  if _, err := os.Stat(outFile); err != nil {
+    t.Errorf("Expected %s, got %v", outFile, err)
+  }
+  if err := runConvert([]string{"docx"}, segmentGap); err == nil {
+    t.Errorf("Expected an error for format docx")
+  }
+}
+
+func Test_convertFlags(t *testing.T) {
+  t.Log("Checking whether convert takes --to for formats and --until for the time range..")
+  if f := convertCmd.Flags().Lookup("to"); f == nil || f.Value.Type() != "stringSlice" {
+    t.Errorf("Expected --to to list formats")
+  }
+  if convertCmd.Flags().Lookup("until") == nil {
+    t.Errorf("Expected --until on convert")
+  }
+  if gpxCmd.Flags().Lookup("until") != nil || gpxCmd.Flags().Lookup("to") == nil {
+    t.Errorf("Expected --to to end the time range on gpx")
+  }
+}
+
+func Test_outputName(t *testing.T) {
+  t.Log("Checking whether --filename names outputs from a template..")
+  defer func() { convertFilename, profile = "", "" }()
+  path := writeDeviceCSV(t, t.TempDir())
+  modified := time.Date(2017, 4, 2, 8, 0, 0, 0, time.Local)
+  os.Chtimes(path, modified, modified)
+
+  if name, err := outputName(path); err != nil || name != "trip" {
+    t.Errorf("Expected trip by default, got %s (%v)", name, err)
+  }
+  convertFilename, profile = `{{.Modified.Format "2006-01-02"}}-{{.Device}}-{{.Name}}`, "bike"
+  if name, err := outputName(path); err != nil || name != "2017-04-02-bike-trip" {
+    t.Errorf("Expected 2017-04-02-bike-trip, got %s (%v)", name, err)
+  }
+  for _, bad := range []string{"{{.Nope}}", "{{.Name}}/x", "{{if"} {
+    convertFilename = bad
+    if _, err := outputName(path); err == nil {
+      t.Errorf("Expected an error for %s", bad)
+    }
+  }
+}
@@ -19,109 +19,323 @@
 package cmd
 
 import (
-  "os"
   "fmt"
   "io"
+  "os"
+  "bufio"
   "strings"
+  "time"
+  "encoding/csv"
 
   "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/expr"
+  "github.com/asnodgrass/columbus-v1000/export"
   "github.com/asnodgrass/columbus-v1000/v1000"
 )
 
+var csvColumns string
+var csvExprColumns []string
+var csvDelimiter = ","
+var csvLineEnding = "crlf"
+var csvQuote = "minimal"
+var csvDateFormat = "device"
+var csvCoordFormat = "suffix"
+var csvNoHeader bool
+var csvBOM bool
+
+// csvDefaultColumns matches the layout the device writes in CSV mode
+var csvDefaultColumns = []string{
+  "index", "tag", "date", "time", "latitude", "longitude",
+  "height", "speed", "heading", "pres", "temp",
+}
+
 // csvCmd represents the csv command
 var csvCmd = &cobra.Command{
   Use:   "csv",
   Short: "Converts to CSV format",
-  Long: `Converts a Columbus V1000 GPS file to CSV format.`,
+  Long: `Converts a Columbus V1000 GPS file to CSV format.
+
+By default the output matches the CSV files written by the device itself.
+Use --columns to select and order fields from: index, tag, date, time,
+timestamp, latitude, longitude, height, speed, heading, pres, temp, plus the
+names of any --column expressions.`,
   Run: func(cmd *cobra.Command, args []string) {
-    if inFile == "" {
-      fmt.Println("error: input file required")
-      return
+    if err := runConvert([]string{"csv"}, segmentGap); err != nil {
+      fmt.Println(err)
+      os.Exit(1)
     }
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(csvCmd)
+  csvCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  csvCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  csvCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  csvCmd.Flags().StringVar(&csvColumns, "columns", "", "comma separated list of columns to write, in order")
+  csvCmd.Flags().StringArrayVar(&csvExprColumns, "column", nil, "add a computed column, as name=expression (repeatable)")
+  csvCmd.Flags().StringVar(&csvDelimiter, "delimiter", csvDelimiter, "field delimiter (a single character, or \"tab\")")
+  csvCmd.Flags().StringVar(&csvLineEnding, "line-ending", csvLineEnding, "line ending: crlf or lf")
+  csvCmd.Flags().StringVar(&csvQuote, "quote", csvQuote, "quoting: minimal (only when needed) or all")
+  csvCmd.Flags().StringVar(&csvDateFormat, "date-format", csvDateFormat, "date format: device, iso8601 or epoch")
+  csvCmd.Flags().StringVar(&csvCoordFormat, "coord-format", csvCoordFormat, "coordinate format: suffix (N/S/E/W) or signed")
+  csvCmd.Flags().BoolVar(&csvNoHeader, "no-header", false, "omit the header row")
+  csvCmd.Flags().BoolVar(&csvBOM, "bom", false, "start the output with a UTF-8 byte order mark (for Excel)")
+  addFilterFlags(csvCmd)
+}
+
+// exprColumn is an extra CSV column computed from an expression
+type exprColumn struct {
+  name string
+  prog *expr.Program
+}
 
-    file, err := os.Open(inFile)
+// parseColumns compiles each name=expression definition
+func parseColumns(defs []string) ([]exprColumn, error) {
+  var columns []exprColumn
+  for _, def := range defs {
+    pos := strings.Index(def, "=")
+    if pos <= 0 || strings.HasPrefix(def[pos:], "==") {
+      return nil, fmt.Errorf("--column %q: expected name=expression", def)
+    }
+    name := strings.TrimSpace(def[:pos])
+    prog, err := expr.Compile(def[pos + 1:])
     if err != nil {
-      fmt.Println(err)
-      return
+      return nil, fmt.Errorf("--column %s: %v", name, err)
     }
-    defer file.Close()
-
-    var out *os.File
-
-    if outFile != "" {
-      out, err = os.Create(outFile)
-      if err != nil {
-        fmt.Println(err)
-        return
-      }
-      defer out.Close()
-    } else {
-      out = os.Stdout
+    columns = append(columns, exprColumn{name, prog})
+  }
+  return columns, nil
+}
+
+// csvColumn is a single output column
+type csvColumn struct {
+  header string
+  value func(rec *v1000.Record) string
+}
+
+// rowWriter is satisfied by csv.Writer and quotingWriter
+type rowWriter interface {
+  Write(record []string) error
+  Flush()
+  Error() error
+}
+
+// csvWriter writes records as delimited text according to the csv flags
+type csvWriter struct {
+  out io.Writer
+  rows rowWriter
+  columns []csvColumn
+}
+
+func newCSVWriter(out io.Writer, meta export.Meta) (*csvWriter, error) {
+  env, loc := meta.Env, meta.Location
+  computed, err := parseColumns(csvExprColumns)
+  if err != nil {
+    return nil, err
+  }
+
+  names := csvDefaultColumns
+  if csvColumns != "" {
+    names = strings.Split(csvColumns, ",")
+  } else if csvDateFormat != "device" {
+    names = []string{"index", "tag", "timestamp", "latitude", "longitude",
+      "height", "speed", "heading", "pres", "temp"}
+  }
+
+  var columns []csvColumn
+  for _, name := range names {
+    col, err := lookupCSVColumn(strings.TrimSpace(name), computed, env, loc)
+    if err != nil {
+      return nil, err
     }
+    columns = append(columns, col)
+  }
+  if csvColumns == "" {
+    for _, c := range computed {
+      columns = append(columns, exprCSVColumn(c, env))
+    }
+  }
 
-    ok, err := v1000.CheckHeader(file)
-    if err != nil || !ok {
-      fmt.Println(err)
-      return
+  comma, err := parseDelimiter(csvDelimiter)
+  if err != nil {
+    return nil, err
+  }
+  var crlf bool
+  switch csvLineEnding {
+  case "crlf":
+    crlf = true
+  case "lf":
+    crlf = false
+  default:
+    return nil, fmt.Errorf("unknown line ending %q (expected crlf or lf)", csvLineEnding)
+  }
+
+  var rows rowWriter
+  switch csvQuote {
+  case "minimal":
+    cw := csv.NewWriter(out)
+    cw.Comma = comma
+    cw.UseCRLF = crlf
+    rows = cw
+  case "all":
+    rows = &quotingWriter{w: bufio.NewWriter(out), comma: comma, crlf: crlf}
+  default:
+    return nil, fmt.Errorf("unknown quoting %q (expected minimal or all)", csvQuote)
+  }
+
+  return &csvWriter{out: out, rows: rows, columns: columns}, nil
+}
+
+// writeHeader writes the byte order mark and header row, as configured
+func (w *csvWriter) writeHeader() error {
+  if csvBOM {
+    if _, err := io.WriteString(w.out, "\xef\xbb\xbf"); err != nil {
+      return err
+    }
+  }
+  if csvNoHeader {
+    return nil
+  }
+  fields := make([]string, len(w.columns))
+  for i, col := range w.columns {
+    fields[i] = col.header
+  }
+  return w.rows.Write(fields)
+}
+
+func (w *csvWriter) writeRecord(rec *v1000.Record) error {
+  fields := make([]string, len(w.columns))
+  for i, col := range w.columns {
+    fields[i] = col.value(rec)
+  }
+  return w.rows.Write(fields)
+}
+
+func (w *csvWriter) flush() error {
+  w.rows.Flush()
+  return w.rows.Error()
+}
+
+// lookupCSVColumn resolves a column name to its header and formatter
+func lookupCSVColumn(name string, computed []exprColumn, env *expr.Env, loc *time.Location) (csvColumn, error) {
+  signed := csvCoordFormat == "signed"
+  if !signed && csvCoordFormat != "suffix" {
+    return csvColumn{}, fmt.Errorf("unknown coordinate format %q (expected suffix or signed)", csvCoordFormat)
+  }
+  var dateFormat func(v1000.Date) string
+  var timeFormat func(v1000.Date) string
+  var stampFormat func(v1000.Date) string
+  switch csvDateFormat {
+  case "device":
+    dateFormat, timeFormat = formatDateCSV, formatTimeCSV
+    stampFormat = func(d v1000.Date) string { return formatDateCSV(d) + formatTimeCSV(d) }
+  case "iso8601", "epoch":
+    dateFormat, timeFormat = formatDateISO, formatTimeISO
+    stampFormat = func(d v1000.Date) string { return d.In(loc).Format(time.RFC3339) }
+    if csvDateFormat == "epoch" {
+      stampFormat = func(d v1000.Date) string { return fmt.Sprintf("%d", d.In(loc).Unix()) }
     }
+  default:
+    return csvColumn{}, fmt.Errorf("unknown date format %q (expected device, iso8601 or epoch)", csvDateFormat)
+  }
 
-    printHeader(out)
-    for {
-      rec, err := v1000.ParseRecord(file)
-      if err == io.EOF {
-        break
-      }
-      if err != nil {
-        fmt.Println(err)
-        return
-      }
-      printRow(&rec, out)
+  switch strings.ToLower(name) {
+  case "index":
+    return csvColumn{"INDEX", func(rec *v1000.Record) string { return fmt.Sprintf("%d", rec.Index) }}, nil
+  case "tag", "type":
+    return csvColumn{"TAG", func(rec *v1000.Record) string { return rec.Type }}, nil
+  case "date":
+    return csvColumn{"DATE", func(rec *v1000.Record) string { return dateFormat(rec.Time) }}, nil
+  case "time":
+    return csvColumn{"TIME", func(rec *v1000.Record) string { return timeFormat(rec.Time) }}, nil
+  case "timestamp":
+    return csvColumn{"TIMESTAMP", func(rec *v1000.Record) string { return stampFormat(rec.Time) }}, nil
+  case "latitude", "lat":
+    if signed {
+      return csvColumn{"LATITUDE", func(rec *v1000.Record) string { return fmt.Sprintf("%.6f", rec.Latitude) }}, nil
     }
-  },
+    return csvColumn{"LATITUDE N/S", func(rec *v1000.Record) string { return formatLatLon(rec.Latitude, true) }}, nil
+  case "longitude", "lon":
+    if signed {
+      return csvColumn{"LONGITUDE", func(rec *v1000.Record) string { return fmt.Sprintf("%.6f", rec.Longitude) }}, nil
+    }
+    return csvColumn{"LONGITUDE E/W", func(rec *v1000.Record) string { return formatLatLon(rec.Longitude, false) }}, nil
+  case "height", "altitude", "alt":
+    return csvColumn{"HEIGHT", func(rec *v1000.Record) string { return fmt.Sprintf("%d", rec.Altitude) }}, nil
+  case "speed":
+    return csvColumn{"SPEED", func(rec *v1000.Record) string { return fmt.Sprintf("%.1f", rec.Speed) }}, nil
+  case "heading":
+    return csvColumn{"HEADING", func(rec *v1000.Record) string { return fmt.Sprintf("%d", rec.Heading) }}, nil
+  case "pres", "pressure":
+    return csvColumn{"PRES", func(rec *v1000.Record) string { return fmt.Sprintf("%.1f", rec.Pressure) }}, nil
+  case "temp", "temperature":
+    return csvColumn{"TEMP", func(rec *v1000.Record) string { return fmt.Sprintf("%d", rec.Temperature) }}, nil
+  }
+  for _, c := range computed {
+    if c.name == name {
+      return exprCSVColumn(c, env), nil
+    }
+  }
+  return csvColumn{}, fmt.Errorf("unknown column %q", name)
 }
 
-func init() {
-  RootCmd.AddCommand(csvCmd)
-  csvCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
-  csvCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+func exprCSVColumn(c exprColumn, env *expr.Env) csvColumn {
+  return csvColumn{c.name, func(rec *v1000.Record) string { return c.prog.Eval(env).String() }}
+}
+
+func parseDelimiter(value string) (rune, error) {
+  switch value {
+  case "tab", "\\t":
+    return '\t', nil
+  }
+  runes := []rune(value)
+  if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+    return 0, fmt.Errorf("invalid delimiter %q", value)
+  }
+  return runes[0], nil
 }
 
-func printHeader(out *os.File) {
-  fields := []string{
-    "INDEX",
-    "TAG",
-    "DATE",
-    "TIME",
-    "LATITUDE N/S",
-    "LONGITUDE E/W",
-    "HEIGHT",
-    "SPEED",
-    "HEADING",
-    "PRES",
-    "TEMP",
-  }
-  hdr := strings.Join(fields, ",")
-  fmt.Fprint(out, hdr)
-  fmt.Fprint(out, "\r\n")
-}
-
-func printRow(rec *v1000.Record, out *os.File) {
-  fields := []string{
-    fmt.Sprintf("%d", rec.Index),
-    rec.Type,
-    formatDateCSV(rec.Time),
-    formatTimeCSV(rec.Time),
-    formatLatLon(rec.Latitude, true),
-    formatLatLon(rec.Longitude, false),
-    fmt.Sprintf("%d", rec.Altitude),
-    fmt.Sprintf("%.1f", rec.Speed),
-    fmt.Sprintf("%d", rec.Heading),
-    fmt.Sprintf("%.1f", rec.Pressure),
-    fmt.Sprintf("%d", rec.Temperature),
-  }
-  row := strings.Join(fields, ",")
-  fmt.Fprint(out, row)
-  fmt.Fprint(out, "\r\n")
+// quotingWriter writes rows with every field quoted, which encoding/csv
+// cannot be asked to do
+type quotingWriter struct {
+  w *bufio.Writer
+  comma rune
+  crlf bool
+  err error
+}
+
+func (q *quotingWriter) Write(record []string) error {
+  if q.err != nil {
+    return q.err
+  }
+  for i, field := range record {
+    if i > 0 {
+      q.w.WriteRune(q.comma)
+    }
+    q.w.WriteByte('"')
+    q.w.WriteString(strings.Replace(field, `"`, `""`, -1))
+    q.w.WriteByte('"')
+  }
+  if q.crlf {
+    q.w.WriteString("\r\n")
+  } else {
+    q.w.WriteByte('\n')
+  }
+  if q.w.Buffered() > 4096 {
+    q.err = q.w.Flush()
+  }
+  return q.err
+}
+
+func (q *quotingWriter) Flush() {
+  if q.err == nil {
+    q.err = q.w.Flush()
+  }
+}
+
+func (q *quotingWriter) Error() error {
+  return q.err
 }
 
 func formatDateCSV(date v1000.Date) string {
@@ -132,6 +346,14 @@ func formatTimeCSV(date v1000.Date) string {
   return fmt.Sprintf("%02d%02d%02d", date.Hour, date.Minute, date.Second)
 }
 
+func formatDateISO(date v1000.Date) string {
+  return fmt.Sprintf("%04d-%02d-%02d", date.Year, date.Month, date.Day)
+}
+
+func formatTimeISO(date v1000.Date) string {
+  return fmt.Sprintf("%02d:%02d:%02d", date.Hour, date.Minute, date.Second)
+}
+
 func formatLatLon(latLon float64, northSouth bool) string {
   x := true
   var dir string
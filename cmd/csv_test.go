@@ -19,8 +19,11 @@
 package cmd
 
 import (
+  "bufio"
+  "bytes"
   "testing"
 
+  "github.com/asnodgrass/columbus-v1000/expr"
   "github.com/asnodgrass/columbus-v1000/v1000"
 )
 
@@ -67,3 +70,146 @@ func Test_formatLatLon(t *testing.T) {
     t.Errorf("Expected '%s', but got '%s' instead", expected, out)
   }
 }
+
+func Test_parseColumns(t *testing.T) {
+  t.Log("Checking whether parseColumns() splits name=expression..")
+  columns, err := parseColumns([]string{"fast=speed > 80", "kmh = speed"})
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if len(columns) != 2 || columns[0].name != "fast" || columns[1].name != "kmh" {
+    t.Errorf("Unexpected columns %v", columns)
+  }
+  for _, bad := range []string{"speed", "=speed", "x==speed", "x=speed >"} {
+    if _, err := parseColumns([]string{bad}); err == nil {
+      t.Errorf("Expected an error for %q", bad)
+    }
+  }
+}
+
+func csvTestRecord() v1000.Record {
+  return v1000.Record{
+    Index: 7,
+    Type: "T",
+    Time: v1000.Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56},
+    Latitude: -34.987654,
+    Longitude: 99.123456,
+    Altitude: 10,
+    Speed: 1.5,
+    Heading: 180,
+    Pressure: 1000.2,
+    Temperature: 20,
+  }
+}
+
+// defaultCSVFlags restores the csv flag defaults
+func defaultCSVFlags() {
+  csvColumns = ""
+  csvExprColumns = nil
+  csvDelimiter = ","
+  csvLineEnding = "crlf"
+  csvQuote = "minimal"
+  csvDateFormat = "device"
+  csvCoordFormat = "suffix"
+  csvNoHeader = false
+  csvBOM = false
+}
+
+// resetCSVFlags restores the csv flag defaults once a test finishes
+func resetCSVFlags(t *testing.T) {
+  t.Cleanup(defaultCSVFlags)
+}
+
+func writeCSVTest(t *testing.T) string {
+  var buf bytes.Buffer
+  meta := testMeta("trip.gps")
+  meta.Env = expr.NewEnv(nil)
+  w, err := newCSVWriter(&buf, meta)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  rec := csvTestRecord()
+  meta.Env.Next(&rec)
+  if err := w.writeHeader(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if err := w.writeRecord(&rec); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return buf.String()
+}
+
+func Test_csvWriterDefault(t *testing.T) {
+  resetCSVFlags(t)
+  expected := "INDEX,TAG,DATE,TIME,LATITUDE N/S,LONGITUDE E/W,HEIGHT,SPEED,HEADING,PRES,TEMP\r\n" +
+    "7,T,170401,123456,34.987654S,99.123456E,10,1.5,180,1000.2,20\r\n"
+  t.Log("Checking whether the default CSV layout matches the device..")
+  if out := writeCSVTest(t); out != expected {
+    t.Errorf("Expected %q, but got %q", expected, out)
+  }
+}
+
+func Test_csvWriterOptions(t *testing.T) {
+  resetCSVFlags(t)
+  csvColumns = "timestamp,lat,lon,kmh"
+  csvExprColumns = []string{"kmh=speed * 2"}
+  csvDelimiter = "tab"
+  csvLineEnding = "lf"
+  csvQuote = "all"
+  csvDateFormat = "epoch"
+  csvCoordFormat = "signed"
+  csvNoHeader = true
+  csvBOM = true
+  expected := "\xef\xbb\xbf\"1491050096\"\t\"-34.987654\"\t\"99.123456\"\t\"3\"\n"
+  t.Log("Checking whether the CSV options are honoured..")
+  if out := writeCSVTest(t); out != expected {
+    t.Errorf("Expected %q, but got %q", expected, out)
+  }
+}
+
+func Test_csvWriterISO(t *testing.T) {
+  resetCSVFlags(t)
+  csvDateFormat = "iso8601"
+  csvDelimiter = ";"
+  expected := "INDEX;TAG;TIMESTAMP;LATITUDE N/S;LONGITUDE E/W;HEIGHT;SPEED;HEADING;PRES;TEMP\r\n" +
+    "7;T;2017-04-01T12:34:56Z;34.987654S;99.123456E;10;1.5;180;1000.2;20\r\n"
+  t.Log("Checking whether ISO 8601 dates replace the device date and time..")
+  if out := writeCSVTest(t); out != expected {
+    t.Errorf("Expected %q, but got %q", expected, out)
+  }
+}
+
+func Test_csvWriterErrors(t *testing.T) {
+  t.Log("Checking whether bad CSV options are rejected..")
+  settings := []func(){
+    func() { csvColumns = "index,bogus" },
+    func() { csvDelimiter = "::" },
+    func() { csvLineEnding = "cr" },
+    func() { csvQuote = "never" },
+    func() { csvDateFormat = "julian" },
+    func() { csvCoordFormat = "dms" },
+  }
+  resetCSVFlags(t)
+  for idx, set := range settings {
+    defaultCSVFlags()
+    set()
+    if _, err := newCSVWriter(&bytes.Buffer{}, testMeta("trip.gps")); err == nil {
+      t.Errorf("Case %d: expected an error", idx)
+    }
+  }
+}
+
+func Test_quotingWriter(t *testing.T) {
+  t.Log("Checking whether quotingWriter escapes embedded quotes..")
+  var buf bytes.Buffer
+  q := &quotingWriter{w: bufio.NewWriter(&buf), comma: ',', crlf: true}
+  q.Write([]string{`say "hi"`, "x"})
+  q.Flush()
+  expected := "\"say \"\"hi\"\"\",\"x\"\r\n"
+  if out := buf.String(); out != expected {
+    t.Errorf("Expected %q, but got %q", expected, out)
+  }
+}
@@ -0,0 +1,388 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "os"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var (
+  dumpRecords string
+  dumpDiff string
+  dumpAll bool
+)
+
+// dumpCmd represents the dump command
+var dumpCmd = &cobra.Command{
+  Use:   "dump",
+  Short: "Prints the raw bytes of each record, field by field",
+  Long: `Prints a binary file's header and each record's 28 bytes, field by field:
+the file offset, the bytes, the raw big endian value and the value as decoded.
+The bits of the flags byte and the components of the packed timestamp are
+listed beneath their fields. Any partial record at the end is shown as
+trailing bytes. This is an aid to working out the parts of the format that are
+still unknown.
+
+--records selects records by position, from 0: 5, 10-20, 10- or -20.
+
+With --diff, a second file is printed beside the first, and only the records
+that differ are shown (all of them with --all), with differing rows marked *.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+    data, err := readDumpFile(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    var other []byte
+    if dumpDiff != "" {
+      if other, err = readDumpFile(dumpDiff); err != nil {
+        fmt.Println(err)
+        os.Exit(1)
+      }
+    }
+    first, last, err := parseRecordRange(dumpRecords)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if dumpDiff != "" {
+      err = writeDumpDiff(out, inFile, data, dumpDiff, other, first, last)
+    } else {
+      err = writeDump(out, data, first, last)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(dumpCmd)
+  dumpCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  dumpCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  dumpCmd.Flags().StringVar(&dumpRecords, "records", "", "records to print, by position from 0 (default: all)")
+  dumpCmd.Flags().StringVar(&dumpDiff, "diff", "", "file to compare against, side by side")
+  dumpCmd.Flags().BoolVar(&dumpAll, "all", false, "with --diff, print records that are the same too")
+}
+
+// dumpRow is a line of a dump: a field, or some bits of the field above it
+type dumpRow struct {
+  offset int64 // -1 for bits
+  label string
+  hex string
+  raw string
+  value string
+}
+
+// dumpSection is the header, a record or the trailing bytes of a file
+type dumpSection struct {
+  title string
+  rows []dumpRow
+}
+
+// flagBits says what is known of each bit of the flags byte
+var flagBits = [8]string{
+  "poi, if bit 1 is clear",
+  "not a poi",
+  "south",
+  "west",
+  "unknown",
+  "unknown",
+  "unknown",
+  "unknown",
+}
+
+func readDumpFile(path string) ([]byte, error) {
+  in, err := openFile(path)
+  if err != nil {
+    return nil, err
+  }
+  defer in.Close()
+  return ioutil.ReadAll(in)
+}
+
+// parseRecordRange parses --records into the first and last positions, with
+// a last of -1 meaning the end of the file
+func parseRecordRange(s string) (int, int, error) {
+  if s == "" {
+    return 0, -1, nil
+  }
+  from, to := s, s
+  if i := strings.Index(s, "-"); i >= 0 {
+    from, to = s[:i], s[i + 1:]
+  }
+  first, last := 0, -1
+  var err error
+  if from != "" {
+    if first, err = strconv.Atoi(from); err != nil || first < 0 {
+      return 0, 0, fmt.Errorf("invalid record range %q", s)
+    }
+  }
+  if to != "" {
+    if last, err = strconv.Atoi(to); err != nil || last < first {
+      return 0, 0, fmt.Errorf("invalid record range %q", s)
+    }
+  }
+  return first, last, nil
+}
+
+// dumpCount returns how many whole records a file has
+func dumpCount(data []byte) int {
+  if len(data) < v1000.HeaderSize {
+    return 0
+  }
+  return (len(data) - v1000.HeaderSize) / v1000.RecordSize
+}
+
+func dumpHeader(data []byte) *dumpSection {
+  if len(data) == 0 {
+    return nil
+  }
+  header := data[:v1000.HeaderSize]
+  if len(data) < v1000.HeaderSize {
+    header = data
+  }
+  row := dumpRow{offset: 0, label: "header", hex: fmt.Sprintf("% x", header), value: "too short"}
+  if len(header) == v1000.HeaderSize {
+    magic := uint16(header[0]) << 8 | uint16(header[1])
+    row.raw = fmt.Sprint(magic)
+    row.value = "ok"
+    if magic != v1000.Magic {
+      row.value = fmt.Sprintf("expected %d", v1000.Magic)
+    }
+  }
+  return &dumpSection{"header", []dumpRow{row}}
+}
+
+func dumpTrailing(data []byte) *dumpSection {
+  start := v1000.HeaderSize + dumpCount(data) * v1000.RecordSize
+  if len(data) <= start {
+    return nil
+  }
+  row := dumpRow{
+    offset: int64(start),
+    label: "trailing",
+    hex: fmt.Sprintf("% x", data[start:]),
+    value: fmt.Sprintf("%d bytes, not a whole record", len(data) - start),
+  }
+  return &dumpSection{"trailing bytes", []dumpRow{row}}
+}
+
+// dumpRecord annotates the record at position i, or returns nil if the file
+// has no such record
+func dumpRecord(data []byte, i int) *dumpSection {
+  if i >= dumpCount(data) {
+    return nil
+  }
+  start := v1000.HeaderSize + i * v1000.RecordSize
+  rec := data[start:start + v1000.RecordSize]
+  decoded, _ := v1000.ParseRecord(bytes.NewReader(rec))
+  section := &dumpSection{title: fmt.Sprintf("record %d", i)}
+  for _, f := range v1000.RecordLayout {
+    raw := f.Raw(rec)
+    row := dumpRow{
+      offset: int64(start + f.Offset),
+      label: f.Name,
+      hex: fmt.Sprintf("% x", f.Bytes(rec)),
+      raw: fmt.Sprint(raw),
+    }
+    switch f.Name {
+    case "index":
+      row.value = fmt.Sprint(decoded.Index)
+    case "flags":
+      row.raw = fmt.Sprintf("%08b", raw)
+      row.value = dumpFlags(decoded)
+    case "time":
+      d := decoded.Time
+      row.value = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d.Year, d.Month, d.Day, d.Hour, d.Minute, d.Second)
+    case "latitude":
+      row.value = fmt.Sprintf("%.6f", decoded.Latitude)
+    case "longitude":
+      row.value = fmt.Sprintf("%.6f", decoded.Longitude)
+    case "altitude":
+      row.value = fmt.Sprintf("%d m", decoded.Altitude)
+    case "speed":
+      row.value = fmt.Sprintf("%.1f km/h", decoded.Speed)
+    case "heading":
+      row.value = fmt.Sprintf("%d°", decoded.Heading)
+    case "pressure":
+      row.value = fmt.Sprintf("%.1f hPa", decoded.Pressure)
+    case "temperature":
+      row.value = fmt.Sprintf("%d °C", decoded.Temperature)
+    }
+    section.rows = append(section.rows, row)
+
+    switch f.Name {
+    case "flags":
+      for bit, meaning := range flagBits {
+        section.rows = append(section.rows, dumpRow{
+          offset: -1,
+          label: fmt.Sprintf("  bit %d", bit),
+          raw: fmt.Sprint(raw >> uint(bit) & 1),
+          value: meaning,
+        })
+      }
+    case "time":
+      for _, d := range v1000.DateLayout {
+        value := d.Value(raw)
+        bits := fmt.Sprintf("  %s %d-%d", d.Name, d.Shift, d.Shift + d.Width - 1)
+        row := dumpRow{offset: -1, label: bits, raw: fmt.Sprintf("%0*b", d.Width, value), value: fmt.Sprint(value)}
+        switch {
+        case d.Name == "year":
+          row.value = fmt.Sprintf("%d (2016 + %d)", value + 2016, value)
+        case d.Name == "month" && value >= 1 && value <= 12:
+          row.value = fmt.Sprintf("%d (%s)", value, time.Month(value))
+        }
+        section.rows = append(section.rows, row)
+      }
+    }
+  }
+  return section
+}
+
+func dumpFlags(rec v1000.Record) string {
+  s := "trackpoint"
+  if rec.Type == "P" {
+    s = "poi"
+  }
+  if rec.South {
+    s += ", south"
+  }
+  if rec.West {
+    s += ", west"
+  }
+  return s
+}
+
+func formatOffset(offset int64) string {
+  if offset < 0 {
+    return ""
+  }
+  return fmt.Sprintf("%06x", offset)
+}
+
+func writeDump(out io.Writer, data []byte, first, last int) error {
+  tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+  write := func(s *dumpSection) {
+    if s == nil {
+      return
+    }
+    fmt.Fprintf(tw, "%s\n", s.title)
+    for _, r := range s.rows {
+      fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", formatOffset(r.offset), r.label, r.hex, r.raw, r.value)
+    }
+  }
+  count := dumpCount(data)
+  if first == 0 {
+    write(dumpHeader(data))
+  }
+  for i := first; i < count && (last < 0 || i <= last); i++ {
+    write(dumpRecord(data, i))
+  }
+  if last < 0 || last >= count - 1 {
+    write(dumpTrailing(data))
+  }
+  return tw.Flush()
+}
+
+// writeDumpDiff prints two files side by side, marking the rows that differ
+func writeDumpDiff(out io.Writer, nameA string, a []byte, nameB string, b []byte, first, last int) error {
+  tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+  fmt.Fprintf(tw, "\t\t%s\t\t\t|\t%s\t\t\t\n", nameA, nameB)
+  var compared, differ int
+  write := func(title string, sa, sb *dumpSection) {
+    if sa == nil && sb == nil {
+      return
+    }
+    compared++
+    rows := 0
+    if sa != nil {
+      rows = len(sa.rows)
+    }
+    if sb != nil && len(sb.rows) > rows {
+      rows = len(sb.rows)
+    }
+    var lines []string
+    same := true
+    for i := 0; i < rows; i++ {
+      ra, rb := dumpRow{offset: -1, hex: "-"}, dumpRow{offset: -1, hex: "-"}
+      if sa != nil && i < len(sa.rows) {
+        ra = sa.rows[i]
+      }
+      if sb != nil && i < len(sb.rows) {
+        rb = sb.rows[i]
+      }
+      label, offset := ra.label, ra.offset
+      if label == "" {
+        label, offset = rb.label, rb.offset
+      }
+      mark := ""
+      if ra.hex != rb.hex || ra.raw != rb.raw {
+        mark = "*"
+        same = false
+      }
+      lines = append(lines, fmt.Sprintf("  %s\t%s\t%s\t%s\t%s\t|\t%s\t%s\t%s\t%s\n",
+        formatOffset(offset), label, ra.hex, ra.raw, ra.value, rb.hex, rb.raw, rb.value, mark))
+    }
+    if !same {
+      differ++
+    }
+    if same && !dumpAll {
+      return
+    }
+    fmt.Fprintf(tw, "%s\t\t\t\t\t|\t\t\t\t\n", title)
+    for _, line := range lines {
+      fmt.Fprint(tw, line)
+    }
+  }
+
+  count := dumpCount(a)
+  if n := dumpCount(b); n > count {
+    count = n
+  }
+  if first == 0 {
+    write("header", dumpHeader(a), dumpHeader(b))
+  }
+  for i := first; i < count && (last < 0 || i <= last); i++ {
+    write(fmt.Sprintf("record %d", i), dumpRecord(a, i), dumpRecord(b, i))
+  }
+  if last < 0 || last >= count - 1 {
+    write("trailing bytes", dumpTrailing(a), dumpTrailing(b))
+  }
+  fmt.Fprintf(tw, "%d of %d sections differ\n", differ, compared)
+  return tw.Flush()
+}
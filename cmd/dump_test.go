@@ -0,0 +1,91 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "io/ioutil"
+  "strings"
+  "testing"
+)
+
+func Test_parseRecordRange(t *testing.T) {
+  t.Log("Checking whether parseRecordRange() reads single records and ranges..")
+  tests := map[string][2]int{"": {0, -1}, "5": {5, 5}, "10-20": {10, 20}, "10-": {10, -1}, "-20": {0, 20}}
+  for in, expected := range tests {
+    first, last, err := parseRecordRange(in)
+    if err != nil || first != expected[0] || last != expected[1] {
+      t.Errorf("Expected %q to give %v, got %d, %d (%v)", in, expected, first, last, err)
+    }
+  }
+  for _, in := range []string{"x", "5-3", "-1-2"} {
+    if _, _, err := parseRecordRange(in); err == nil {
+      t.Errorf("Expected an error for %q", in)
+    }
+  }
+}
+
+func Test_dumpRecord(t *testing.T) {
+  t.Log("Checking whether dumpRecord() annotates fields, flag bits and date bits..")
+  data, err := ioutil.ReadFile(writeBadBinary(t, t.TempDir()))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if dumpRecord(data, 2) != nil {
+    t.Errorf("Expected no third record")
+  }
+  rows := map[string]dumpRow{}
+  for _, r := range dumpRecord(data, 1).rows {
+    rows[strings.TrimSpace(r.label)] = r
+  }
+  expected := map[string]dumpRow{
+    "index": {30, "index", "00 00 05", "5", "5"},
+    "flags": {33, "flags", "0d", "00001101", "poi, south, west"},
+    "bit 2": {-1, "  bit 2", "", "1", "south"},
+    "month 22-25": {-1, "  month 22-25", "", "0100", "4 (April)"},
+    "temperature": {56, "temperature", "00 c8", "200", "20 °C"},
+  }
+  for name, e := range expected {
+    if rows[name] != e {
+      t.Errorf("Expected %+v, got %+v", e, rows[name])
+    }
+  }
+}
+
+func Test_writeDump(t *testing.T) {
+  t.Log("Checking whether dump selects records and diffs two files..")
+  a, err := ioutil.ReadFile(writeBadBinary(t, t.TempDir()))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var out bytes.Buffer
+  writeDump(&out, a, 1, 1)
+  if s := out.String(); strings.Contains(s, "header") || !strings.HasPrefix(s, "record 1\n") || !strings.Contains(s, "trailing") {
+    t.Errorf("Expected record 1 and the trailing bytes, got:\n%s", s)
+  }
+
+  b := append([]byte{}, a[:len(a) - 3]...)
+  b[0], b[1] = 0x07, 0x07
+  out.Reset()
+  writeDumpDiff(&out, "a.gps", a, "b.gps", b, 0, -1)
+  s := out.String()
+  if !strings.Contains(s, "header") || strings.Contains(s, "record 0") || !strings.HasSuffix(s, "2 of 4 sections differ\n") {
+    t.Errorf("Expected the header and trailing bytes to differ, got:\n%s", s)
+  }
+}
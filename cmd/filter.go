@@ -0,0 +1,162 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/expr"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var filterFrom string
+var filterTo string
+var filterBBox string
+var filterWithin string
+var filterType string
+var filterWhere string
+
+// filterTimeLayouts are tried in order when parsing --from and --to
+var filterTimeLayouts = []string{
+  time.RFC3339,
+  "2006-01-02T15:04:05",
+  "2006-01-02 15:04:05",
+  "2006-01-02T15:04",
+  "2006-01-02 15:04",
+  "2006-01-02",
+}
+
+// addFilterFlags registers the record filter flags shared by every export
+// command. Commands that already use --to, as convert does for its output
+// formats, get --until for the end of the time range instead.
+func addFilterFlags(cmd *cobra.Command) {
+  to := "to"
+  if cmd.Flags().Lookup("to") != nil {
+    to = "until"
+  }
+  cmd.Flags().StringVar(&filterFrom, "from", "", "only keep records at or after this time")
+  cmd.Flags().StringVar(&filterTo, to, "", "only keep records at or before this time")
+  cmd.Flags().StringVar(&filterBBox, "bbox", "", "only keep records inside minlon,minlat,maxlon,maxlat")
+  cmd.Flags().StringVar(&filterWithin, "within", "", "only keep records inside the polygons of a GeoJSON file")
+  cmd.Flags().StringVar(&filterType, "type", "", "only keep records of this type (trackpoint or poi)")
+  cmd.Flags().StringVar(&filterWhere, "where", "", "only keep records matching an expression, e.g. 'speed > 80 && temp < 3'")
+}
+
+// buildFilter combines the filter flags into a single filter. With no flags
+// set it matches every record. The returned expression environment is
+// advanced by the filter, so it sees every decoded record in order and is
+// positioned on the current record for computing expression columns.
+func buildFilter() (v1000.Filter, *expr.Env, error) {
+  loc, err := location()
+  if err != nil {
+    return nil, nil, err
+  }
+  env := expr.NewEnv(loc)
+  filters := v1000.All{
+    v1000.FilterFunc(func(rec *v1000.Record) bool {
+      env.Next(rec)
+      return true
+    }),
+  }
+
+  if filterFrom != "" || filterTo != "" {
+    rng := v1000.TimeRange{Location: loc}
+    if rng.From, err = parseFilterTime(filterFrom, loc); err != nil {
+      return nil, nil, err
+    }
+    if rng.To, err = parseFilterEnd(filterTo, loc); err != nil {
+      return nil, nil, err
+    }
+    filters = append(filters, rng)
+  }
+
+  if filterBBox != "" {
+    box, err := v1000.ParseBoundingBox(filterBBox)
+    if err != nil {
+      return nil, nil, err
+    }
+    filters = append(filters, box)
+  }
+
+  if filterWithin != "" {
+    file, err := os.Open(filterWithin)
+    if err != nil {
+      return nil, nil, err
+    }
+    defer file.Close()
+    polygons, err := v1000.ReadGeoJSON(file)
+    if err != nil {
+      return nil, nil, fmt.Errorf("%s: %v", filterWithin, err)
+    }
+    filters = append(filters, polygons)
+  }
+
+  if filterType != "" {
+    typ, err := parseRecordType(filterType)
+    if err != nil {
+      return nil, nil, err
+    }
+    filters = append(filters, typ)
+  }
+
+  if filterWhere != "" {
+    prog, err := expr.CompileBool(filterWhere)
+    if err != nil {
+      return nil, nil, fmt.Errorf("--where: %v", err)
+    }
+    filters = append(filters, expr.Filter(prog, env))
+  }
+
+  return filters, env, nil
+}
+
+func parseFilterTime(value string, loc *time.Location) (time.Time, error) {
+  if value == "" {
+    return time.Time{}, nil
+  }
+  for _, layout := range filterTimeLayouts {
+    if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+      return t, nil
+    }
+  }
+  return time.Time{}, fmt.Errorf("unrecognised time %q", value)
+}
+
+// parseFilterEnd parses the end of the time range, where a date on its own
+// means the end of that day
+func parseFilterEnd(value string, loc *time.Location) (time.Time, error) {
+  if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+    return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+  }
+  return parseFilterTime(value, loc)
+}
+
+func parseRecordType(value string) (v1000.TypeFilter, error) {
+  switch strings.ToLower(value) {
+  case "t", "trackpoint", "trackpoints", "track":
+    return v1000.TypeFilter("T"), nil
+  case "p", "poi", "pois":
+    return v1000.TypeFilter("P"), nil
+  }
+  return "", fmt.Errorf("unknown record type %q (expected trackpoint or poi)", value)
+}
@@ -0,0 +1,84 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_parseFilterTime(t *testing.T) {
+  t.Log("Checking whether parseFilterTime() accepts the supported layouts..")
+  loc := time.FixedZone("UTC+2", 2 * 60 * 60)
+  expected := time.Date(2017, 4, 1, 12, 34, 56, 0, loc)
+  for _, value := range []string{"2017-04-01T12:34:56+02:00", "2017-04-01T12:34:56", "2017-04-01 12:34:56", "2017-04-01T10:34:56Z"} {
+    out, err := parseFilterTime(value, loc)
+    if err != nil {
+      t.Errorf("Unexpected error for %q: %v", value, err)
+    } else if !out.Equal(expected) {
+      t.Errorf("Expected %v for %q, got %v", expected, value, out)
+    }
+  }
+  if _, err := parseFilterTime("yesterday", loc); err == nil {
+    t.Errorf("Expected an error for an unrecognised time")
+  }
+}
+
+func Test_parseFilterEnd(t *testing.T) {
+  t.Log("Checking whether a date on its own ends the time range at the end of that day..")
+  defer func() { filterTo = "" }()
+  expected := time.Date(2017, 4, 1, 23, 59, 59, 999999999, time.UTC)
+  if out, err := parseFilterEnd("2017-04-01", time.UTC); err != nil || !out.Equal(expected) {
+    t.Errorf("Expected %v, got %v (%v)", expected, out, err)
+  }
+  expected = time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  if out, err := parseFilterEnd("2017-04-01 12:00", time.UTC); err != nil || !out.Equal(expected) {
+    t.Errorf("Expected %v, got %v (%v)", expected, out, err)
+  }
+
+  filterTo = "2017-04-01"
+  filter, _, err := buildFilter()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  late := v1000.Record{Type: "T", Time: v1000.DateOf(time.Date(2017, 4, 1, 23, 30, 0, 0, time.UTC))}
+  if !filter.Match(&late) {
+    t.Errorf("Expected --to 2017-04-01 to keep a record at 23:30 that day")
+  }
+  next := v1000.Record{Type: "T", Time: v1000.DateOf(time.Date(2017, 4, 2, 0, 0, 0, 0, time.UTC))}
+  if filter.Match(&next) {
+    t.Errorf("Expected --to 2017-04-01 to drop a record at midnight the next day")
+  }
+}
+
+func Test_parseRecordType(t *testing.T) {
+  t.Log("Checking whether parseRecordType() maps names onto record types..")
+  cases := map[string]string{"trackpoint": "T", "T": "T", "poi": "P", "POI": "P"}
+  for value, expected := range cases {
+    out, err := parseRecordType(value)
+    if err != nil || string(out) != expected {
+      t.Errorf("Expected %s for %q, got %s (%v)", expected, value, out, err)
+    }
+  }
+  if _, err := parseRecordType("waypoint"); err == nil {
+    t.Errorf("Expected an error for an unknown type")
+  }
+}
@@ -0,0 +1,513 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var fitSport = "generic"
+
+// fitSports maps --sport names onto the FIT sport enum
+var fitSports = map[string]uint8{
+  "generic": 0,
+  "running": 1,
+  "cycling": 2,
+  "walking": 11,
+  "mountaineering": 16,
+  "hiking": 17,
+  "paddling": 19,
+  "flying": 20,
+  "e_biking": 21,
+  "motorcycling": 22,
+  "boating": 23,
+  "driving": 24,
+  "hang_gliding": 26,
+  "sailing": 32,
+  "sky_diving": 34,
+  "kayaking": 41,
+}
+
+// fitCmd represents the fit command
+var fitCmd = &cobra.Command{
+  Use:   "fit",
+  Short: "Converts to Garmin FIT activity format",
+  Long: `Converts a Columbus V1000 GPS file to a Garmin FIT activity.
+
+Each record carries its position, altitude, speed, cumulative distance and
+temperature, with the heading as a developer field. Every track segment (see
+--segment-gap) becomes a lap, followed by a session and activity summary.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newFITWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(fitCmd)
+  fitCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  fitCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  fitCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  fitCmd.Flags().StringVar(&fitSport, "sport", fitSport, "activity sport: " + fitSportNames())
+  addSegmentFlags(fitCmd)
+  addFilterFlags(fitCmd)
+}
+
+func fitSportNames() string {
+  names := make([]string, 0, len(fitSports))
+  for name := range fitSports {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return strings.Join(names, ", ")
+}
+
+// FIT base types
+const (
+  fitEnum byte = 0x00
+  fitSint8 byte = 0x01
+  fitUint8 byte = 0x02
+  fitString byte = 0x07
+  fitByte byte = 0x0d
+  fitSint16 byte = 0x83
+  fitUint16 byte = 0x84
+  fitSint32 byte = 0x85
+  fitUint32 byte = 0x86
+  fitUint32z byte = 0x8c
+)
+
+// FIT event and event_type values
+const (
+  fitEventTimer uint8 = 0
+  fitEventSession uint8 = 8
+  fitEventLap uint8 = 9
+  fitEventActivity uint8 = 26
+  fitEventTypeStart uint8 = 0
+  fitEventTypeStop uint8 = 1
+  fitEventTypeStopAll uint8 = 4
+)
+
+// fitEpoch is the FIT time origin, 1989-12-31T00:00:00Z
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// fitApplicationID identifies columbus-v1000's developer fields
+var fitApplicationID = []byte{
+  0x5c, 0x0e, 0x7a, 0x31, 0x9d, 0x42, 0x4b, 0x8e,
+  0xa1, 0x6f, 0x10, 0x00, 0xc0, 0x1b, 0x05, 0x17,
+}
+
+type fitField struct {
+  num byte
+  size byte
+  base byte
+}
+
+// fitMessage is a message definition bound to a local message type.
+// Developer fields all belong to developer data index 0.
+type fitMessage struct {
+  local byte
+  global uint16
+  fields []fitField
+  devFields []fitField
+}
+
+var (
+  fitFileID = &fitMessage{local: 0, global: 0, fields: []fitField{
+    {0, 1, fitEnum},     // type
+    {1, 2, fitUint16},   // manufacturer
+    {2, 2, fitUint16},   // product
+    {3, 4, fitUint32z},  // serial_number
+    {4, 4, fitUint32},   // time_created
+  }}
+  fitDeveloperDataID = &fitMessage{local: 1, global: 207, fields: []fitField{
+    {1, 16, fitByte},    // application_id
+    {3, 1, fitUint8},    // developer_data_index
+  }}
+  fitFieldDescription = &fitMessage{local: 2, global: 206, fields: []fitField{
+    {0, 1, fitUint8},    // developer_data_index
+    {1, 1, fitUint8},    // field_definition_number
+    {2, 1, fitUint8},    // fit_base_type_id
+    {3, 16, fitString},  // field_name
+    {8, 8, fitString},   // units
+  }}
+  fitEvent = &fitMessage{local: 3, global: 21, fields: []fitField{
+    {253, 4, fitUint32}, // timestamp
+    {0, 1, fitEnum},     // event
+    {1, 1, fitEnum},     // event_type
+  }}
+  fitRecord = &fitMessage{local: 4, global: 20, fields: []fitField{
+    {253, 4, fitUint32}, // timestamp
+    {0, 4, fitSint32},   // position_lat
+    {1, 4, fitSint32},   // position_long
+    {2, 2, fitUint16},   // altitude
+    {5, 4, fitUint32},   // distance
+    {6, 2, fitUint16},   // speed
+    {13, 1, fitSint8},   // temperature
+  }, devFields: []fitField{
+    {0, 2, fitUint16},   // heading
+  }}
+  fitLap = &fitMessage{local: 5, global: 19, fields: []fitField{
+    {253, 4, fitUint32}, // timestamp
+    {254, 2, fitUint16}, // message_index
+    {0, 1, fitEnum},     // event
+    {1, 1, fitEnum},     // event_type
+    {2, 4, fitUint32},   // start_time
+    {3, 4, fitSint32},   // start_position_lat
+    {4, 4, fitSint32},   // start_position_long
+    {5, 4, fitSint32},   // end_position_lat
+    {6, 4, fitSint32},   // end_position_long
+    {7, 4, fitUint32},   // total_elapsed_time
+    {8, 4, fitUint32},   // total_timer_time
+    {9, 4, fitUint32},   // total_distance
+    {13, 2, fitUint16},  // avg_speed
+    {14, 2, fitUint16},  // max_speed
+    {21, 2, fitUint16},  // total_ascent
+    {22, 2, fitUint16},  // total_descent
+    {25, 1, fitEnum},    // sport
+    {43, 2, fitUint16},  // max_altitude
+    {50, 1, fitSint8},   // avg_temperature
+    {51, 1, fitSint8},   // max_temperature
+    {62, 2, fitUint16},  // min_altitude
+  }}
+  fitSession = &fitMessage{local: 6, global: 18, fields: []fitField{
+    {253, 4, fitUint32}, // timestamp
+    {254, 2, fitUint16}, // message_index
+    {0, 1, fitEnum},     // event
+    {1, 1, fitEnum},     // event_type
+    {2, 4, fitUint32},   // start_time
+    {3, 4, fitSint32},   // start_position_lat
+    {4, 4, fitSint32},   // start_position_long
+    {5, 1, fitEnum},     // sport
+    {6, 1, fitEnum},     // sub_sport
+    {7, 4, fitUint32},   // total_elapsed_time
+    {8, 4, fitUint32},   // total_timer_time
+    {9, 4, fitUint32},   // total_distance
+    {14, 2, fitUint16},  // avg_speed
+    {15, 2, fitUint16},  // max_speed
+    {22, 2, fitUint16},  // total_ascent
+    {23, 2, fitUint16},  // total_descent
+    {25, 2, fitUint16},  // first_lap_index
+    {26, 2, fitUint16},  // num_laps
+    {29, 4, fitSint32},  // nec_lat
+    {30, 4, fitSint32},  // nec_long
+    {31, 4, fitSint32},  // swc_lat
+    {32, 4, fitSint32},  // swc_long
+    {50, 2, fitUint16},  // max_altitude
+    {57, 1, fitSint8},   // avg_temperature
+    {58, 1, fitSint8},   // max_temperature
+    {71, 2, fitUint16},  // min_altitude
+  }}
+  fitActivity = &fitMessage{local: 7, global: 34, fields: []fitField{
+    {253, 4, fitUint32}, // timestamp
+    {0, 4, fitUint32},   // total_timer_time
+    {1, 2, fitUint16},   // num_sessions
+    {2, 1, fitEnum},     // type
+    {3, 1, fitEnum},     // event
+    {4, 1, fitEnum},     // event_type
+    {5, 4, fitUint32},   // local_timestamp
+  }}
+)
+
+// fitEncoder accumulates the definition and data messages of a FIT file
+type fitEncoder struct {
+  data bytes.Buffer
+}
+
+func (e *fitEncoder) define(m *fitMessage) {
+  header := 0x40 | m.local
+  if len(m.devFields) > 0 {
+    header |= 0x20
+  }
+  e.data.WriteByte(header)
+  e.data.WriteByte(0) // reserved
+  e.data.WriteByte(0) // little endian
+  binary.Write(&e.data, binary.LittleEndian, m.global)
+  e.data.WriteByte(byte(len(m.fields)))
+  for _, f := range m.fields {
+    e.data.Write([]byte{f.num, f.size, f.base})
+  }
+  if len(m.devFields) > 0 {
+    e.data.WriteByte(byte(len(m.devFields)))
+    for _, f := range m.devFields {
+      e.data.Write([]byte{f.num, f.size, 0})
+    }
+  }
+}
+
+// write appends a data message. There must be one value per field, then per
+// developer field, each of a Go type matching the field's size; strings and
+// byte slices are padded or truncated to fit.
+func (e *fitEncoder) write(m *fitMessage, values ...interface{}) {
+  if len(values) != len(m.fields) + len(m.devFields) {
+    panic(fmt.Sprintf("fit: message %d takes %d values, got %d",
+      m.global, len(m.fields) + len(m.devFields), len(values)))
+  }
+  e.data.WriteByte(m.local)
+  for i, v := range values {
+    var f fitField
+    if i < len(m.fields) {
+      f = m.fields[i]
+    } else {
+      f = m.devFields[i - len(m.fields)]
+    }
+    switch x := v.(type) {
+    case string:
+      // leave room for the terminating NUL
+      b := make([]byte, f.size)
+      copy(b[:f.size - 1], x)
+      e.data.Write(b)
+    case []byte:
+      b := make([]byte, f.size)
+      copy(b, x)
+      e.data.Write(b)
+    default:
+      binary.Write(&e.data, binary.LittleEndian, v)
+    }
+  }
+}
+
+// bytes returns the complete file: header, messages and trailing CRC
+func (e *fitEncoder) bytes() []byte {
+  header := make([]byte, 14)
+  header[0] = 14
+  header[1] = 0x20 // protocol 2.0
+  binary.LittleEndian.PutUint16(header[2:], 2132) // profile 21.32
+  binary.LittleEndian.PutUint32(header[4:], uint32(e.data.Len()))
+  copy(header[8:], ".FIT")
+  binary.LittleEndian.PutUint16(header[12:], fitCRC(0, header[:12]))
+  out := append(header, e.data.Bytes()...)
+  crc := make([]byte, 2)
+  binary.LittleEndian.PutUint16(crc, fitCRC(0, out))
+  return append(out, crc...)
+}
+
+var fitCRCTable = [16]uint16{
+  0x0000, 0xcc01, 0xd801, 0x1400, 0xf001, 0x3c00, 0x2800, 0xe401,
+  0xa001, 0x6c00, 0x7800, 0xb401, 0x5000, 0x9c01, 0x8801, 0x4400,
+}
+
+// fitCRC continues a FIT CRC-16 over data
+func fitCRC(crc uint16, data []byte) uint16 {
+  for _, b := range data {
+    tmp := fitCRCTable[crc & 0xf]
+    crc = (crc >> 4) & 0x0fff
+    crc = crc ^ tmp ^ fitCRCTable[b & 0xf]
+    tmp = fitCRCTable[crc & 0xf]
+    crc = (crc >> 4) & 0x0fff
+    crc = crc ^ tmp ^ fitCRCTable[(b >> 4) & 0xf]
+  }
+  return crc
+}
+
+// fitWriter collects records and writes them as a FIT activity
+type fitWriter struct {
+  out io.Writer
+  loc *time.Location
+  gap time.Duration
+  sport uint8
+  recs []v1000.Record
+}
+
+func newFITWriter(out io.Writer, meta export.Meta) (*fitWriter, error) {
+  sport, ok := fitSports[fitSport]
+  if !ok {
+    return nil, fmt.Errorf("unknown sport %q (expected one of %s)", fitSport, fitSportNames())
+  }
+  return &fitWriter{out: out, loc: meta.Location, gap: meta.SegmentGap, sport: sport}, nil
+}
+
+func (w *fitWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *fitWriter) flush() error {
+  if len(w.recs) == 0 {
+    return fmt.Errorf("no records to write")
+  }
+  segments := v1000.SplitSegments(w.recs, w.gap, w.loc)
+
+  var e fitEncoder
+  start := fitTime(w.recs[0].Time, w.loc)
+  end := fitTime(w.recs[len(w.recs) - 1].Time, w.loc)
+
+  e.define(fitFileID)
+  e.write(fitFileID, uint8(4), uint16(255), uint16(1000), uint32(1), start)
+  e.define(fitDeveloperDataID)
+  e.write(fitDeveloperDataID, fitApplicationID, uint8(0))
+  e.define(fitFieldDescription)
+  e.write(fitFieldDescription, uint8(0), uint8(0), fitUint16, "heading", "degrees")
+  e.define(fitEvent)
+  e.write(fitEvent, start, fitEventTimer, fitEventTypeStart)
+
+  var distance float64
+  var timer uint32
+  e.define(fitRecord)
+  e.define(fitLap)
+  for i, segment := range segments {
+    for j := range segment {
+      rec := &segment[j]
+      if j > 0 {
+        distance += v1000.Distance(&segment[j - 1], rec)
+      }
+      e.write(fitRecord,
+        fitTime(rec.Time, w.loc),
+        fitSemicircles(rec.Latitude),
+        fitSemicircles(rec.Longitude),
+        fitAltitude(float64(rec.Altitude)),
+        uint32(math.Round(distance * 100)),
+        fitSpeed(rec.Speed),
+        fitTemperature(float64(rec.Temperature)),
+        rec.Heading % 360)
+    }
+
+    stats := v1000.Summarize(segment, w.loc)
+    first, last := &segment[0], &segment[len(segment) - 1]
+    elapsed := fitDuration(stats.Duration())
+    timer += elapsed
+    e.write(fitLap,
+      fitTime(last.Time, w.loc),
+      uint16(i),
+      fitEventLap,
+      fitEventTypeStop,
+      fitTime(first.Time, w.loc),
+      fitSemicircles(first.Latitude),
+      fitSemicircles(first.Longitude),
+      fitSemicircles(last.Latitude),
+      fitSemicircles(last.Longitude),
+      elapsed,
+      elapsed,
+      uint32(math.Round(stats.Distance * 100)),
+      fitSpeed(stats.AvgSpeed()),
+      fitSpeed(stats.MaxSpeed),
+      uint16(math.Round(stats.Ascent)),
+      uint16(math.Round(stats.Descent)),
+      w.sport,
+      fitAltitude(stats.MaxAltitude),
+      fitTemperature(stats.AvgTemperature),
+      fitTemperature(stats.MaxTemperature),
+      fitAltitude(stats.MinAltitude))
+  }
+
+  e.write(fitEvent, end, fitEventTimer, fitEventTypeStopAll)
+
+  stats := v1000.Summarize(w.recs, w.loc)
+  e.define(fitSession)
+  e.write(fitSession,
+    end,
+    uint16(0),
+    fitEventSession,
+    fitEventTypeStop,
+    start,
+    fitSemicircles(w.recs[0].Latitude),
+    fitSemicircles(w.recs[0].Longitude),
+    w.sport,
+    uint8(0),
+    fitDuration(stats.Duration()),
+    timer,
+    uint32(math.Round(distance * 100)),
+    fitSpeed(distance / math.Max(float64(timer) / 1000, 1) * 3.6),
+    fitSpeed(stats.MaxSpeed),
+    uint16(math.Round(stats.Ascent)),
+    uint16(math.Round(stats.Descent)),
+    uint16(0),
+    uint16(len(segments)),
+    fitSemicircles(stats.Bounds.MaxLat),
+    fitSemicircles(stats.Bounds.MaxLon),
+    fitSemicircles(stats.Bounds.MinLat),
+    fitSemicircles(stats.Bounds.MinLon),
+    fitAltitude(stats.MaxAltitude),
+    fitTemperature(stats.AvgTemperature),
+    fitTemperature(stats.MaxTemperature),
+    fitAltitude(stats.MinAltitude))
+
+  _, offset := stats.End.Zone()
+  e.define(fitActivity)
+  e.write(fitActivity,
+    end,
+    timer,
+    uint16(1),
+    uint8(0), // manual
+    fitEventActivity,
+    fitEventTypeStop,
+    end + uint32(offset))
+
+  _, err := w.out.Write(e.bytes())
+  return err
+}
+
+// fitTime converts a record time to seconds since the FIT epoch
+func fitTime(date v1000.Date, loc *time.Location) uint32 {
+  return uint32(date.In(loc).Sub(fitEpoch) / time.Second)
+}
+
+// fitDuration converts to milliseconds, FIT's scale for elapsed times
+func fitDuration(d time.Duration) uint32 {
+  return uint32(d / time.Millisecond)
+}
+
+// fitSemicircles converts degrees to FIT's 2^31 / 180 semicircles
+func fitSemicircles(degrees float64) int32 {
+  return int32(math.Round(degrees * (1 << 31) / 180))
+}
+
+// fitAltitude applies FIT's altitude scale of 5 and offset of 500m
+func fitAltitude(metres float64) uint16 {
+  return uint16(math.Max(0, math.Min(65534, math.Round((metres + 500) * 5))))
+}
+
+// fitSpeed converts km/h to FIT's mm/s
+func fitSpeed(kmh float64) uint16 {
+  return uint16(math.Max(0, math.Min(65534, math.Round(kmh / 3.6 * 1000))))
+}
+
+func fitTemperature(celsius float64) int8 {
+  return int8(math.Max(-127, math.Min(127, math.Round(celsius))))
+}
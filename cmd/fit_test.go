@@ -0,0 +1,206 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/binary"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// fitDecoded is a decoded FIT data message: field number to raw value, with
+// developer fields keyed by their number plus 1000
+type fitDecoded struct {
+  global uint16
+  fields map[int]uint64
+}
+
+// decodeFIT is a minimal FIT decoder for little endian, fixed-size fields
+func decodeFIT(t *testing.T, data []byte) []fitDecoded {
+  if len(data) < 16 || data[0] != 14 || string(data[8:12]) != ".FIT" {
+    t.Fatalf("Expected a 14 byte FIT header")
+  }
+  if crc := binary.LittleEndian.Uint16(data[12:]); crc != fitCRC(0, data[:12]) {
+    t.Errorf("Expected header CRC %04x, got %04x", fitCRC(0, data[:12]), crc)
+  }
+  size := int(binary.LittleEndian.Uint32(data[4:]))
+  if len(data) != 14 + size + 2 {
+    t.Fatalf("Expected %d bytes, got %d", 14 + size + 2, len(data))
+  }
+  if crc := binary.LittleEndian.Uint16(data[14 + size:]); crc != fitCRC(0, data[:14 + size]) {
+    t.Errorf("Expected file CRC %04x, got %04x", fitCRC(0, data[:14 + size]), crc)
+  }
+
+  type definition struct {
+    global uint16
+    fields []fitField
+  }
+  defs := map[byte]definition{}
+  var msgs []fitDecoded
+  r := bytes.NewReader(data[14:14 + size])
+  for r.Len() > 0 {
+    header, _ := r.ReadByte()
+    local := header & 0x0f
+    if header & 0x40 != 0 {
+      var hdr [5]byte
+      r.Read(hdr[:])
+      def := definition{global: binary.LittleEndian.Uint16(hdr[2:])}
+      for i := 0; i < int(hdr[4]); i++ {
+        var f [3]byte
+        r.Read(f[:])
+        def.fields = append(def.fields, fitField{f[0], f[1], f[2]})
+      }
+      if header & 0x20 != 0 {
+        n, _ := r.ReadByte()
+        for i := 0; i < int(n); i++ {
+          var f [3]byte
+          r.Read(f[:])
+          def.fields = append(def.fields, fitField{f[0], f[1], 0xff})
+        }
+      }
+      defs[local] = def
+      continue
+    }
+    def, ok := defs[local]
+    if !ok {
+      t.Fatalf("Data message for undefined local type %d", local)
+    }
+    msg := fitDecoded{global: def.global, fields: map[int]uint64{}}
+    for _, f := range def.fields {
+      b := make([]byte, f.size)
+      r.Read(b)
+      num := int(f.num)
+      if f.base == 0xff {
+        num += 1000
+      }
+      if f.size <= 8 {
+        var v [8]byte
+        copy(v[:], b)
+        msg.fields[num] = binary.LittleEndian.Uint64(v[:])
+      }
+    }
+    msgs = append(msgs, msg)
+  }
+  return msgs
+}
+
+func Test_fitCRC(t *testing.T) {
+  t.Log("Checking whether fitCRC() matches CRC-16/ARC..")
+  if crc := fitCRC(0, []byte("123456789")); crc != 0xbb3d {
+    t.Errorf("Expected bb3d, got %04x", crc)
+  }
+}
+
+func Test_fitWriter(t *testing.T) {
+  t.Log("Checking whether the FIT writer round-trips records and laps..")
+  defer func() { fitSport = "generic" }()
+  fitSport = "cycling"
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  var buf bytes.Buffer
+  meta := testMeta("trip.gps")
+  meta.SegmentGap = time.Minute
+  w, err := newFITWriter(&buf, meta)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for i := 0; i < 20; i++ {
+    offset := time.Duration(i) * 10 * time.Second
+    if i >= 10 {
+      offset += 10 * time.Minute
+    }
+    w.writeRecord(&v1000.Record{Type: "T", Latitude: 51.5 + float64(i) * 0.001, Longitude: -0.1,
+      Altitude: 100, Speed: 36, Heading: 90, Temperature: 15,
+      Time: v1000.DateOf(start.Add(offset))})
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  counts := map[uint16]int{}
+  var records, laps, sessions []fitDecoded
+  for _, msg := range decodeFIT(t, buf.Bytes()) {
+    counts[msg.global]++
+    switch msg.global {
+    case 20:
+      records = append(records, msg)
+    case 19:
+      laps = append(laps, msg)
+    case 18:
+      sessions = append(sessions, msg)
+    }
+  }
+  if counts[0] != 1 || counts[34] != 1 || counts[21] != 2 {
+    t.Errorf("Expected file_id, activity and two events, got %v", counts)
+  }
+  if len(records) != 20 || len(laps) != 2 || len(sessions) != 1 {
+    t.Fatalf("Expected 20 records, 2 laps and a session, got %v", counts)
+  }
+
+  rec := records[0].fields
+  if ts := uint32(rec[253]); ts != uint32(start.Unix() - 631065600) {
+    t.Errorf("Expected timestamp %d, got %d", start.Unix() - 631065600, ts)
+  }
+  if lat := float64(int32(rec[0])) * 180 / (1 << 31); lat < 51.4999999 || lat > 51.5000001 {
+    t.Errorf("Expected latitude 51.5, got %f", lat)
+  }
+  if alt := uint16(rec[2]); alt != 3000 {
+    t.Errorf("Expected altitude 3000 ((100 + 500) * 5), got %d", alt)
+  }
+  if speed := uint16(rec[6]); speed != 10000 {
+    t.Errorf("Expected speed 10000 mm/s, got %d", speed)
+  }
+  if temp := int8(rec[13]); temp != 15 {
+    t.Errorf("Expected temperature 15, got %d", temp)
+  }
+  if heading := uint16(rec[1000]); heading != 90 {
+    t.Errorf("Expected developer heading 90, got %d", heading)
+  }
+  if dist := uint32(records[19].fields[5]); dist < 195000 || dist > 205000 {
+    t.Errorf("Expected about 2km cumulative distance within the laps, got %d cm", dist)
+  }
+
+  if elapsed := uint32(laps[1].fields[7]); elapsed != 90000 {
+    t.Errorf("Expected a 90s second lap, got %d ms", elapsed)
+  }
+  if idx := uint16(laps[1].fields[254]); idx != 1 {
+    t.Errorf("Expected lap message_index 1, got %d", idx)
+  }
+  session := sessions[0].fields
+  if n := uint16(session[26]); n != 2 {
+    t.Errorf("Expected num_laps 2, got %d", n)
+  }
+  if sport := uint8(session[5]); sport != 2 {
+    t.Errorf("Expected sport cycling (2), got %d", sport)
+  }
+  if timer := uint32(session[8]); timer != 180000 {
+    t.Errorf("Expected 180s timer time, got %d ms", timer)
+  }
+}
+
+func Test_newFITWriter(t *testing.T) {
+  t.Log("Checking whether newFITWriter() rejects unknown sports..")
+  defer func() { fitSport = "generic" }()
+  fitSport = "curling"
+  if _, err := newFITWriter(&bytes.Buffer{}, testMeta("trip.gps")); err == nil {
+    t.Errorf("Expected an error for sport curling")
+  }
+}
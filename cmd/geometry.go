@@ -0,0 +1,143 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "encoding/hex"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var geometryFormat = "wkt"
+var geometryPrecision = 5
+var geometrySRID uint32 = 4326
+var geometryHex = true
+
+// geometryCmd represents the geometry command
+var geometryCmd = &cobra.Command{
+  Use:   "geometry",
+  Short: "Converts to an encoded polyline or WKT/WKB geometry",
+  Long: `Converts a Columbus V1000 GPS file to a single geometry for embedding in
+web apps and SQL queries.
+
+The wkt, wkb and ewkb formats write a LINESTRING ZM, with altitude as Z and
+Unix time as M, or a MULTILINESTRING ZM when the track has several segments
+(see --segment-gap). The polyline format writes one Google encoded polyline
+per segment, one per line.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newGeometryWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(geometryCmd)
+  geometryCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  geometryCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  geometryCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  geometryCmd.Flags().StringVar(&geometryFormat, "format", geometryFormat, "geometry format: polyline, wkt, wkb or ewkb")
+  geometryCmd.Flags().IntVar(&geometryPrecision, "precision", geometryPrecision, "polyline precision in decimal places")
+  geometryCmd.Flags().Uint32Var(&geometrySRID, "srid", geometrySRID, "spatial reference ID for ewkb")
+  geometryCmd.Flags().BoolVar(&geometryHex, "hex", geometryHex, "write wkb and ewkb as hex rather than raw bytes")
+  addSegmentFlags(geometryCmd)
+  addFilterFlags(geometryCmd)
+}
+
+// geometryWriter collects trackpoints and writes them as one geometry
+type geometryWriter struct {
+  out io.Writer
+  format string
+  loc *time.Location
+  gap time.Duration
+  recs []v1000.Record
+}
+
+func newGeometryWriter(out io.Writer, meta export.Meta) (*geometryWriter, error) {
+  format := strings.ToLower(geometryFormat)
+  switch format {
+  case "polyline", "wkt", "wkb", "ewkb":
+  default:
+    return nil, fmt.Errorf("unknown geometry format %q (expected polyline, wkt, wkb or ewkb)", geometryFormat)
+  }
+  if geometryPrecision < 0 || geometryPrecision > 10 {
+    return nil, fmt.Errorf("invalid --precision %d (expected 0-10)", geometryPrecision)
+  }
+  return &geometryWriter{out: out, format: format, loc: meta.Location, gap: meta.SegmentGap}, nil
+}
+
+func (w *geometryWriter) writeRecord(rec *v1000.Record) error {
+  if rec.Type == "T" {
+    w.recs = append(w.recs, *rec)
+  }
+  return nil
+}
+
+func (w *geometryWriter) flush() error {
+  segments := v1000.SplitSegments(w.recs, w.gap, w.loc)
+  var wkb []byte
+  switch w.format {
+  case "polyline":
+    for _, segment := range segments {
+      if _, err := fmt.Fprintln(w.out, v1000.EncodePolyline(segment, geometryPrecision)); err != nil {
+        return err
+      }
+    }
+    return nil
+  case "wkt":
+    _, err := fmt.Fprintln(w.out, v1000.WKT(segments, w.loc))
+    return err
+  case "wkb":
+    wkb = v1000.WKB(segments, w.loc)
+  case "ewkb":
+    wkb = v1000.EWKB(segments, w.loc, geometrySRID)
+  }
+  var err error
+  if geometryHex {
+    _, err = fmt.Fprintln(w.out, strings.ToUpper(hex.EncodeToString(wkb)))
+  } else {
+    _, err = w.out.Write(wkb)
+  }
+  return err
+}
@@ -0,0 +1,79 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func writeGeometry(t *testing.T) string {
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  var buf bytes.Buffer
+  w, err := newGeometryWriter(&buf, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  // two lines and an isolated fix
+  for i, offset := range []time.Duration{0, time.Second, 10 * time.Minute, 10 * time.Minute + time.Second, 30 * time.Minute} {
+    w.writeRecord(&v1000.Record{Type: "T", Latitude: 38.5 + float64(i), Longitude: -120.2, Altitude: 10,
+      Time: v1000.DateOf(start.Add(offset))})
+  }
+  w.writeRecord(&v1000.Record{Type: "P", Time: v1000.DateOf(start)})
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return buf.String()
+}
+
+func Test_geometryWriter(t *testing.T) {
+  t.Log("Checking whether the geometry writer splits segments per format..")
+  defer func() { geometryFormat = "wkt"; geometryHex = true }()
+
+  geometryFormat = "wkt"
+  expected := "MULTILINESTRING ZM ((-120.2 38.5 10 1491048000, -120.2 39.5 10 1491048001), " +
+    "(-120.2 40.5 10 1491048600, -120.2 41.5 10 1491048601))\n"
+  if out := writeGeometry(t); out != expected {
+    t.Errorf("Expected %s, got %s", expected, out)
+  }
+
+  geometryFormat = "polyline"
+  if out := writeGeometry(t); out != "_p~iF~ps|U_ibE?\n_devF~ps|U_ibE?\n_xkbG~ps|U\n" {
+    t.Errorf("Expected three polylines, got %q", out)
+  }
+
+  geometryFormat = "ewkb"
+  if out := writeGeometry(t); out[:18] != "01050000E0E6100000" {
+    t.Errorf("Expected hex EWKB with SRID 4326, got %s", out)
+  }
+
+  geometryFormat = "wkb"
+  geometryHex = false
+  if out := writeGeometry(t); out[:5] != "\x01\xbd\x0b\x00\x00" {
+    t.Errorf("Expected raw WKB MULTILINESTRING ZM, got %q", out[:5])
+  }
+
+  geometryFormat = "geojson"
+  if _, err := newGeometryWriter(&bytes.Buffer{}, testMeta("trip.gps")); err == nil {
+    t.Errorf("Expected an error for format geojson")
+  }
+}
@@ -0,0 +1,477 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+//go:build cgo
+// +build cgo
+
+package cmd
+
+import (
+  "bytes"
+  "database/sql"
+  "encoding/binary"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+  _ "github.com/mattn/go-sqlite3"
+)
+
+// gpkgCmd represents the gpkg command
+var gpkgCmd = &cobra.Command{
+  Use:   "gpkg",
+  Short: "Converts to OGC GeoPackage format",
+  Long: `Converts a Columbus V1000 GPS file to an OGC GeoPackage, for QGIS and
+other GIS software. The GeoPackage has three layers, each with an R-tree
+spatial index:
+
+  tracks        a line per track segment (see --segment-gap), with summary
+                statistics
+  track_points  every trackpoint, with all of its attributes
+  pois          the points of interest
+
+Geometries are WGS 84 (EPSG:4326) with altitude as Z and Unix time as M. An
+existing output file is replaced.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+    if outFile == "" {
+      fmt.Println("error: output file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    w, err := newGPKGWriter(outFile, meta)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if err := readRecords(filter, w.writeRecord); err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if err := w.flush(); err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(gpkgCmd)
+  gpkgCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  gpkgCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  gpkgCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file, ending .gpkg (required)")
+  addSegmentFlags(gpkgCmd)
+  addFilterFlags(gpkgCmd)
+}
+
+// gpkgSRSID is WGS 84, which GeoPackages always define
+const gpkgSRSID = 4326
+
+const gpkgWGS84 = `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]`
+
+// gpkgSchema creates the required GeoPackage tables, as given in the 1.2
+// specification
+var gpkgSchema = []string{
+  `PRAGMA application_id = 1196444487`, // "GPKG"
+  `PRAGMA user_version = 10200`,
+  `CREATE TABLE gpkg_spatial_ref_sys (
+    srs_name TEXT NOT NULL,
+    srs_id INTEGER NOT NULL PRIMARY KEY,
+    organization TEXT NOT NULL,
+    organization_coordsys_id INTEGER NOT NULL,
+    definition TEXT NOT NULL,
+    description TEXT
+  )`,
+  `CREATE TABLE gpkg_contents (
+    table_name TEXT NOT NULL PRIMARY KEY,
+    data_type TEXT NOT NULL,
+    identifier TEXT UNIQUE,
+    description TEXT DEFAULT '',
+    last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    min_x DOUBLE,
+    min_y DOUBLE,
+    max_x DOUBLE,
+    max_y DOUBLE,
+    srs_id INTEGER,
+    CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id)
+  )`,
+  `CREATE TABLE gpkg_geometry_columns (
+    table_name TEXT NOT NULL,
+    column_name TEXT NOT NULL,
+    geometry_type_name TEXT NOT NULL,
+    srs_id INTEGER NOT NULL,
+    z TINYINT NOT NULL,
+    m TINYINT NOT NULL,
+    CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name),
+    CONSTRAINT uk_gc_table_name UNIQUE (table_name),
+    CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name),
+    CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+  )`,
+  `CREATE TABLE gpkg_extensions (
+    table_name TEXT,
+    column_name TEXT,
+    extension_name TEXT NOT NULL,
+    definition TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    CONSTRAINT ge_tce UNIQUE (table_name, column_name, extension_name)
+  )`,
+  `INSERT INTO gpkg_spatial_ref_sys VALUES
+    ('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+    ('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+    ('WGS 84 geodetic', 4326, 'EPSG', 4326, '` + gpkgWGS84 + `', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid')`,
+}
+
+// gpkgLayer is a feature table
+type gpkgLayer struct {
+  name string
+  geometryType string
+  description string
+  columns string
+}
+
+var gpkgTracks = gpkgLayer{"tracks", "LINESTRING", "Track segments", `
+    segment INTEGER NOT NULL,
+    start_time DATETIME,
+    end_time DATETIME,
+    duration_s REAL,
+    points INTEGER,
+    distance_m REAL,
+    avg_speed_kmh REAL,
+    max_speed_kmh REAL,
+    min_altitude_m REAL,
+    max_altitude_m REAL,
+    ascent_m REAL,
+    descent_m REAL,
+    avg_temperature_c REAL`}
+
+var gpkgRecordColumns = `
+    record_index INTEGER NOT NULL,
+    time DATETIME,
+    latitude REAL,
+    longitude REAL,
+    altitude_m INTEGER,
+    speed_kmh REAL,
+    heading INTEGER,
+    pressure_hpa REAL,
+    temperature_c INTEGER`
+
+var gpkgTrackPoints = gpkgLayer{"track_points", "POINT", "Trackpoints",
+  "\n    segment INTEGER NOT NULL," + gpkgRecordColumns}
+
+var gpkgPOIs = gpkgLayer{"pois", "POINT", "Points of interest", gpkgRecordColumns}
+
+// gpkgWriter collects records and writes them to a GeoPackage
+type gpkgWriter struct {
+  path string
+  name string
+  loc *time.Location
+  gap time.Duration
+  recs []v1000.Record
+  index map[string][]gpkgEntry
+}
+
+// gpkgEntry is a feature's R-tree entry
+type gpkgEntry struct {
+  fid int64
+  bounds v1000.BoundingBox
+}
+
+func newGPKGWriter(path string, meta export.Meta) (*gpkgWriter, error) {
+  if strings.ToLower(filepath.Ext(path)) != ".gpkg" {
+    return nil, fmt.Errorf("%s: GeoPackage file names must end .gpkg", path)
+  }
+  return &gpkgWriter{path: path, name: meta.Name, loc: meta.Location, gap: meta.SegmentGap, index: map[string][]gpkgEntry{}}, nil
+}
+
+func (w *gpkgWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *gpkgWriter) flush() error {
+  var track, pois []v1000.Record
+  for _, rec := range w.recs {
+    if rec.Type == "P" {
+      pois = append(pois, rec)
+    } else {
+      track = append(track, rec)
+    }
+  }
+  segments := v1000.SplitSegments(track, w.gap, w.loc)
+
+  if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+    return err
+  }
+  db, err := sql.Open("sqlite3", w.path)
+  if err != nil {
+    return err
+  }
+  defer db.Close()
+  tx, err := db.Begin()
+  if err != nil {
+    return err
+  }
+  defer tx.Rollback()
+
+  for _, stmt := range gpkgSchema {
+    if _, err := tx.Exec(stmt); err != nil {
+      return err
+    }
+  }
+
+  // a line needs two points
+  var lines []v1000.Record
+  for _, segment := range segments {
+    if len(segment) >= 2 {
+      lines = append(lines, segment...)
+    }
+  }
+  if err := w.createLayer(tx, gpkgTracks, lines); err != nil {
+    return err
+  }
+  for i, segment := range segments {
+    if len(segment) < 2 {
+      continue
+    }
+    s := v1000.Summarize(segment, w.loc)
+    err := w.insertFeature(tx, gpkgTracks, v1000.WKB([][]v1000.Record{segment}, w.loc), s.Bounds,
+      "segment, start_time, end_time, duration_s, points, distance_m, avg_speed_kmh, max_speed_kmh, " +
+      "min_altitude_m, max_altitude_m, ascent_m, descent_m, avg_temperature_c",
+      i + 1, formatGPKGTime(s.Start), formatGPKGTime(s.End), s.Duration().Seconds(), s.Count,
+      s.Distance, s.AvgSpeed(), s.MaxSpeed, s.MinAltitude, s.MaxAltitude, s.Ascent, s.Descent,
+      s.AvgTemperature)
+    if err != nil {
+      return err
+    }
+  }
+
+  if err := w.createLayer(tx, gpkgTrackPoints, track); err != nil {
+    return err
+  }
+  for i, segment := range segments {
+    for j := range segment {
+      if err := w.insertRecord(tx, gpkgTrackPoints, &segment[j], i + 1); err != nil {
+        return err
+      }
+    }
+  }
+
+  if err := w.createLayer(tx, gpkgPOIs, pois); err != nil {
+    return err
+  }
+  for i := range pois {
+    if err := w.insertRecord(tx, gpkgPOIs, &pois[i], 0); err != nil {
+      return err
+    }
+  }
+
+  for _, layer := range []gpkgLayer{gpkgTracks, gpkgTrackPoints, gpkgPOIs} {
+    if err := createGPKGRTree(tx, layer.name, "geom", "fid", w.index[layer.name]); err != nil {
+      return err
+    }
+  }
+  return tx.Commit()
+}
+
+// createLayer creates a feature table and registers it, with the bounds of
+// recs
+func (w *gpkgWriter) createLayer(tx *sql.Tx, layer gpkgLayer, recs []v1000.Record) error {
+  stmt := fmt.Sprintf("CREATE TABLE %s (\n    fid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,\n    geom %s,%s\n  )",
+    layer.name, layer.geometryType, layer.columns)
+  if _, err := tx.Exec(stmt); err != nil {
+    return err
+  }
+  var bounds []interface{}
+  if len(recs) > 0 {
+    b := v1000.Summarize(recs, w.loc).Bounds
+    bounds = []interface{}{b.MinLon, b.MinLat, b.MaxLon, b.MaxLat}
+  } else {
+    bounds = []interface{}{nil, nil, nil, nil}
+  }
+  identifier := layer.name
+  if w.name != "" {
+    identifier = w.name + " " + layer.name
+  }
+  args := append([]interface{}{layer.name, identifier, layer.description}, bounds...)
+  _, err := tx.Exec(`INSERT INTO gpkg_contents (table_name, data_type, identifier, description,
+    min_x, min_y, max_x, max_y, srs_id) VALUES (?, 'features', ?, ?, ?, ?, ?, ?, 4326)`, args...)
+  if err != nil {
+    return err
+  }
+  _, err = tx.Exec(`INSERT INTO gpkg_geometry_columns VALUES (?, 'geom', ?, 4326, 1, 1)`,
+    layer.name, layer.geometryType)
+  return err
+}
+
+func (w *gpkgWriter) insertRecord(tx *sql.Tx, layer gpkgLayer, rec *v1000.Record, segment int) error {
+  columns := "record_index, time, latitude, longitude, altitude_m, speed_kmh, heading, pressure_hpa, temperature_c"
+  args := []interface{}{
+    rec.Index, formatGPKGTime(rec.Time.In(w.loc)), rec.Latitude, rec.Longitude, rec.Altitude,
+    rec.Speed, rec.Heading, rec.Pressure, rec.Temperature,
+  }
+  if layer.name == gpkgTrackPoints.name {
+    columns += ", segment"
+    args = append(args, segment)
+  }
+  bounds := v1000.BoundingBox{MinLon: rec.Longitude, MinLat: rec.Latitude, MaxLon: rec.Longitude, MaxLat: rec.Latitude}
+  return w.insertFeature(tx, layer, v1000.PointWKB(rec, w.loc), bounds, columns, args...)
+}
+
+// insertFeature inserts a row with a geometry and notes it for the R-tree
+func (w *gpkgWriter) insertFeature(tx *sql.Tx, layer gpkgLayer, wkb []byte, bounds v1000.BoundingBox, columns string, args ...interface{}) error {
+  // points need no envelope
+  envelope := bounds
+  if layer.geometryType == "POINT" {
+    envelope = v1000.BoundingBox{}
+  }
+  args = append([]interface{}{gpkgGeometry(wkb, envelope)}, args...)
+  placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+  res, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (geom, %s) VALUES (%s)", layer.name, columns, placeholders), args...)
+  if err != nil {
+    return err
+  }
+  fid, err := res.LastInsertId()
+  if err != nil {
+    return err
+  }
+  w.index[layer.name] = append(w.index[layer.name], gpkgEntry{fid, bounds})
+  return nil
+}
+
+// gpkgGeometry wraps WKB in a GeoPackage geometry header, with an XY
+// envelope unless bounds is zero, as it may be for points
+func gpkgGeometry(wkb []byte, bounds v1000.BoundingBox) []byte {
+  var b bytes.Buffer
+  b.WriteString("GP")
+  b.WriteByte(0) // version 1
+  flags := byte(0x01) // little endian
+  if bounds != (v1000.BoundingBox{}) {
+    flags |= 1 << 1
+  }
+  b.WriteByte(flags)
+  binary.Write(&b, binary.LittleEndian, int32(gpkgSRSID))
+  if bounds != (v1000.BoundingBox{}) {
+    binary.Write(&b, binary.LittleEndian, [4]float64{bounds.MinLon, bounds.MaxLon, bounds.MinLat, bounds.MaxLat})
+  }
+  b.Write(wkb)
+  return b.Bytes()
+}
+
+// createGPKGRTree adds the gpkg_rtree_index extension to a table: the R-tree,
+// filled from entries, and the triggers that keep it up to date. The
+// triggers use functions that only GeoPackage-aware readers provide, so
+// they are created after the features are written.
+func createGPKGRTree(tx *sql.Tx, table, column, id string, entries []gpkgEntry) error {
+  _, err := tx.Exec(`INSERT INTO gpkg_extensions VALUES (?, ?, 'gpkg_rtree_index',
+    'http://www.geopackage.org/spec120/#extension_rtree', 'write-only')`, table, column)
+  if err != nil {
+    return err
+  }
+  rtree := fmt.Sprintf("rtree_%s_%s", table, column)
+  if _, err := tx.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING rtree(id, minx, maxx, miny, maxy)", rtree)); err != nil {
+    return err
+  }
+  for _, e := range entries {
+    // R-tree boxes are float32, rounded outwards by SQLite
+    _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?, ?)", rtree), e.fid,
+      e.bounds.MinLon, e.bounds.MaxLon, e.bounds.MinLat, e.bounds.MaxLat)
+    if err != nil {
+      return err
+    }
+  }
+
+  replacer := strings.NewReplacer("<t>", table, "<c>", column, "<i>", id)
+  for _, trigger := range gpkgRTreeTriggers {
+    if _, err := tx.Exec(replacer.Replace(trigger)); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// gpkgRTreeTriggers are the R-tree triggers from the GeoPackage 1.2
+// specification, with <t>, <c> and <i> standing for the table, geometry
+// column and primary key
+var gpkgRTreeTriggers = []string{
+  `CREATE TRIGGER rtree_<t>_<c>_insert AFTER INSERT ON <t>
+  WHEN (new.<c> NOT NULL AND NOT ST_IsEmpty(NEW.<c>))
+BEGIN
+  INSERT OR REPLACE INTO rtree_<t>_<c> VALUES (
+    NEW.<i>,
+    ST_MinX(NEW.<c>), ST_MaxX(NEW.<c>),
+    ST_MinY(NEW.<c>), ST_MaxY(NEW.<c>)
+  );
+END`,
+  `CREATE TRIGGER rtree_<t>_<c>_update1 AFTER UPDATE OF <c> ON <t>
+  WHEN OLD.<i> = NEW.<i> AND
+       (NEW.<c> NOTNULL AND NOT ST_IsEmpty(NEW.<c>))
+BEGIN
+  INSERT OR REPLACE INTO rtree_<t>_<c> VALUES (
+    NEW.<i>,
+    ST_MinX(NEW.<c>), ST_MaxX(NEW.<c>),
+    ST_MinY(NEW.<c>), ST_MaxY(NEW.<c>)
+  );
+END`,
+  `CREATE TRIGGER rtree_<t>_<c>_update2 AFTER UPDATE OF <c> ON <t>
+  WHEN OLD.<i> = NEW.<i> AND
+       (NEW.<c> ISNULL OR ST_IsEmpty(NEW.<c>))
+BEGIN
+  DELETE FROM rtree_<t>_<c> WHERE id = OLD.<i>;
+END`,
+  `CREATE TRIGGER rtree_<t>_<c>_update3 AFTER UPDATE ON <t>
+  WHEN OLD.<i> != NEW.<i> AND
+       (NEW.<c> NOTNULL AND NOT ST_IsEmpty(NEW.<c>))
+BEGIN
+  DELETE FROM rtree_<t>_<c> WHERE id = OLD.<i>;
+  INSERT OR REPLACE INTO rtree_<t>_<c> VALUES (
+    NEW.<i>,
+    ST_MinX(NEW.<c>), ST_MaxX(NEW.<c>),
+    ST_MinY(NEW.<c>), ST_MaxY(NEW.<c>)
+  );
+END`,
+  `CREATE TRIGGER rtree_<t>_<c>_update4 AFTER UPDATE ON <t>
+  WHEN OLD.<i> != NEW.<i> AND
+       (NEW.<c> ISNULL OR ST_IsEmpty(NEW.<c>))
+BEGIN
+  DELETE FROM rtree_<t>_<c> WHERE id IN (OLD.<i>, NEW.<i>);
+END`,
+  `CREATE TRIGGER rtree_<t>_<c>_delete AFTER DELETE ON <t>
+  WHEN old.<c> NOT NULL
+BEGIN
+  DELETE FROM rtree_<t>_<c> WHERE id = OLD.<i>;
+END`,
+}
+
+// formatGPKGTime formats a time as GeoPackage DATETIME, in UTC
+func formatGPKGTime(t time.Time) string {
+  return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
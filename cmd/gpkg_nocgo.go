@@ -0,0 +1,52 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+//go:build !cgo
+// +build !cgo
+
+package cmd
+
+import (
+  "fmt"
+  "os"
+
+  "github.com/spf13/cobra"
+)
+
+// gpkgCmd stands in for the gpkg command in builds without cgo, which
+// SQLite needs
+var gpkgCmd = &cobra.Command{
+  Use:   "gpkg",
+  Short: "Converts to OGC GeoPackage format (needs a cgo build)",
+  Long: `Converts a Columbus V1000 GPS file to an OGC GeoPackage. GeoPackages are
+SQLite databases, and SQLite support needs cgo, which this build was made
+without. Build with CGO_ENABLED=1 and a C compiler to use this command.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    fmt.Println("error: this build has no GeoPackage support; rebuild with CGO_ENABLED=1")
+    os.Exit(1)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(gpkgCmd)
+  gpkgCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  gpkgCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  gpkgCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file, ending .gpkg (required)")
+  addSegmentFlags(gpkgCmd)
+  addFilterFlags(gpkgCmd)
+}
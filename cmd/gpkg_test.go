@@ -0,0 +1,129 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+//go:build cgo
+// +build cgo
+
+package cmd
+
+import (
+  "database/sql"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_gpkgGeometry(t *testing.T) {
+  t.Log("Checking whether gpkgGeometry() writes the GP header..")
+  wkb := []byte{1, 0xb9, 0x0b, 0, 0}
+  out := gpkgGeometry(wkb, v1000.BoundingBox{})
+  if string(out[:8]) != "GP\x00\x01\xe6\x10\x00\x00" || len(out) != 8 + len(wkb) {
+    t.Errorf("Expected a header without envelope, got %q", out)
+  }
+  out = gpkgGeometry(wkb, v1000.BoundingBox{MinLon: 1, MinLat: 2, MaxLon: 3, MaxLat: 4})
+  if out[3] != 0x03 || len(out) != 8 + 32 + len(wkb) {
+    t.Errorf("Expected an XY envelope, got %q", out)
+  }
+}
+
+func Test_gpkgWriter(t *testing.T) {
+  t.Log("Checking whether the GeoPackage has its layers, metadata and indexes..")
+  meta := testMeta("trip.gps")
+  meta.SegmentGap = time.Minute
+  path := filepath.Join(t.TempDir(), "trip.gpkg")
+  if _, err := newGPKGWriter(filepath.Join(t.TempDir(), "trip.sqlite"), meta); err == nil {
+    t.Errorf("Expected an error for a .sqlite file name")
+  }
+  w, err := newGPKGWriter(path, meta)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  for i, offset := range []time.Duration{0, time.Second, 10 * time.Minute, 10 * time.Minute + time.Second} {
+    w.writeRecord(&v1000.Record{Index: uint32(i + 1), Type: "T", Latitude: 51.5 + float64(i) * 0.001,
+      Longitude: -0.1, Altitude: 100, Speed: 36, Heading: 90, Pressure: 1000, Temperature: 15,
+      Time: v1000.DateOf(start.Add(offset))})
+  }
+  // a lone fix, which is not a line
+  w.writeRecord(&v1000.Record{Index: 5, Type: "T", Latitude: 51.6, Longitude: -0.1,
+    Time: v1000.DateOf(start.Add(30 * time.Minute))})
+  w.writeRecord(&v1000.Record{Index: 6, Type: "P", Latitude: 51.6, Longitude: -0.2,
+    Time: v1000.DateOf(start.Add(time.Hour))})
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  db, err := sql.Open("sqlite3", path)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  defer db.Close()
+  var appID, version int
+  db.QueryRow("PRAGMA application_id").Scan(&appID)
+  db.QueryRow("PRAGMA user_version").Scan(&version)
+  if appID != 0x47504b47 || version != 10200 {
+    t.Errorf("Expected application_id GPKG and version 10200, got %x and %d", appID, version)
+  }
+  var integrity string
+  db.QueryRow("PRAGMA integrity_check").Scan(&integrity)
+  if integrity != "ok" {
+    t.Errorf("Expected integrity_check ok, got %s", integrity)
+  }
+  if rows, err := db.Query("PRAGMA foreign_key_check"); err != nil || rows.Next() {
+    t.Errorf("Expected no foreign key violations (%v)", err)
+  }
+
+  counts := map[string]int{
+    "tracks": 2, "track_points": 5, "pois": 1,
+    "rtree_tracks_geom": 2, "rtree_track_points_geom": 5, "rtree_pois_geom": 1,
+    "gpkg_contents": 3, "gpkg_geometry_columns": 3, "gpkg_extensions": 3, "gpkg_spatial_ref_sys": 3,
+  }
+  for table, expected := range counts {
+    var n int
+    if err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil || n != expected {
+      t.Errorf("Expected %d rows in %s, got %d (%v)", expected, table, n, err)
+    }
+  }
+  var triggers int
+  db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'trigger'").Scan(&triggers)
+  if triggers != 18 {
+    t.Errorf("Expected 6 R-tree triggers per layer, got %d", triggers)
+  }
+
+  var geometryType string
+  var minY, maxY float64
+  db.QueryRow(`SELECT g.geometry_type_name, c.min_y, c.max_y FROM gpkg_contents c
+    JOIN gpkg_geometry_columns g USING (table_name) WHERE table_name = 'tracks'`).Scan(&geometryType, &minY, &maxY)
+  if geometryType != "LINESTRING" || minY != 51.5 || maxY != 51.503 {
+    t.Errorf("Expected a LINESTRING layer from 51.5 to 51.503, got %s from %f to %f", geometryType, minY, maxY)
+  }
+  var points int
+  var distance float64
+  var startTime string
+  db.QueryRow("SELECT points, distance_m, start_time || '' FROM tracks WHERE segment = 2").Scan(&points, &distance, &startTime)
+  if points != 2 || distance < 110 || distance > 112 || startTime != "2017-04-01T12:10:00.000Z" {
+    t.Errorf("Expected segment 2 to be 2 points and 111m from 12:10, got %d, %f and %s", points, distance, startTime)
+  }
+  var fid int
+  db.QueryRow("SELECT id FROM rtree_track_points_geom WHERE minx <= -0.1 AND maxx >= -0.1 AND miny <= 51.502 AND maxy >= 51.502").Scan(&fid)
+  if fid != 3 {
+    t.Errorf("Expected the R-tree to find point 3, got %d", fid)
+  }
+}
@@ -19,64 +19,33 @@
 package cmd
 
 import (
-  "os"
   "fmt"
   "log"
   "path"
   "strings"
   "time"
   "io"
-  "io/ioutil"
+  "os"
   "encoding/xml"
 
   "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
   "github.com/asnodgrass/columbus-v1000/v1000"
 )
 
+// gpxSegmentGap is the gpx command's own --segment-gap. Unlike convert's, it
+// defaults to a single track segment, which is what gpx has always written
+var gpxSegmentGap time.Duration
+
 // gpxCmd represents the gpx command
 var gpxCmd = &cobra.Command{
   Use:   "gpx",
   Short: "Converts to GPX format",
   Long: `Converts a Columbus V1000 GPS file to GPX format.`,
   Run: func(cmd *cobra.Command, args []string) {
-    var trkpts []trackPoint
-
-    if inFile == "" {
-      fmt.Println("error: input file required")
-      return
-    }
-
-    file, err := os.Open(inFile)
-    if err != nil {
+    if err := runConvert([]string{"gpx"}, gpxSegmentGap); err != nil {
       fmt.Println(err)
-      return
-    }
-    defer file.Close()
-
-    ok, err := v1000.CheckHeader(file)
-    if err != nil || !ok {
-      fmt.Println(err)
-      return
-    }
-
-    for {
-      rec, err := v1000.ParseRecord(file)
-      if err == io.EOF {
-        break
-      }
-      if err != nil {
-        fmt.Println(err)
-        return
-      }
-      trkpts = append(trkpts, recordToTrackPoint(rec))
-    }
-
-    name := filenamePrefix(inFile)
-    gpxData := generateGPX(trkpts, name)
-    if outFile != "" {
-      ioutil.WriteFile(outFile, gpxData, 0644)
-    } else {
-      fmt.Println(string(gpxData[:]))
+      os.Exit(1)
     }
   },
 }
@@ -84,7 +53,10 @@ var gpxCmd = &cobra.Command{
 func init() {
   RootCmd.AddCommand(gpxCmd)
   gpxCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  gpxCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
   gpxCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  gpxCmd.Flags().DurationVar(&gpxSegmentGap, "segment-gap", 0, "start a new track segment after a gap this long (default: never split, as gpx always has; convert --to gpx splits after 5m)")
+  addFilterFlags(gpxCmd)
 }
 
 type trackPoint struct {
@@ -138,6 +110,11 @@ func (value latLong) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
   return xml.Attr{Name: name, Value: formatted}, nil
 }
 
+// MarshalXML ...
+func (value latLong) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+  return e.EncodeElement(fmt.Sprintf("%.9f", value), start)
+}
+
 // MarshalXML ...
 func (value other) MarshalXML(e *xml.Encoder, start xml.StartElement) (error) {
   formatted := fmt.Sprintf("%.6f", value)
@@ -145,21 +122,55 @@ func (value other) MarshalXML(e *xml.Encoder, start xml.StartElement) (error) {
   return nil
 }
 
-func recordToTrackPoint(rec v1000.Record) (trackPoint) {
+// gpxWriter collects records and writes them as a GPX track
+type gpxWriter struct {
+  out io.Writer
+  name string
+  loc *time.Location
+  gap time.Duration
+  recs []v1000.Record
+}
+
+func newGPXWriter(out io.Writer, meta export.Meta) *gpxWriter {
+  return &gpxWriter{out: out, name: meta.Name, loc: meta.Location, gap: meta.SegmentGap}
+}
+
+func (w *gpxWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *gpxWriter) flush() error {
+  segments := v1000.SplitSegments(w.recs, w.gap, w.loc)
+  trksegs := make([][]trackPoint, len(segments))
+  for i, segment := range segments {
+    for _, rec := range segment {
+      trksegs[i] = append(trksegs[i], recordToTrackPoint(rec, w.loc.String()))
+    }
+  }
+  _, err := fmt.Fprintln(w.out, string(generateGPX(trksegs, w.name)))
+  return err
+}
+
+func recordToTrackPoint(rec v1000.Record, zone string) (trackPoint) {
   tp := trackPoint{
     Latitude: latLong(rec.Latitude),
     Longitude: latLong(rec.Longitude),
     Altitude: other(rec.Altitude),
     Speed: other(rec.Speed),
     Heading: other(rec.Heading),
-    Time: formatDateRFC3339(rec.Time, timeZone),
+    Time: formatDateRFC3339(rec.Time, zone),
   }
   return tp
 }
 
-func generateGPX(trkpts []trackPoint, name string) []byte {
-  trksegs := make([]trackSegment, 1)
-  trksegs[0].TrackPoints = trkpts
+func generateGPX(segments [][]trackPoint, name string) []byte {
+  var trkpts []trackPoint
+  trksegs := make([]trackSegment, len(segments))
+  for i, segment := range segments {
+    trksegs[i].TrackPoints = segment
+    trkpts = append(trkpts, segment...)
+  }
 
   trk := track{
     TrackSegments: trksegs,
@@ -248,8 +259,13 @@ func maximumLongitude(trkpts []trackPoint) latLong {
   return high
 }
 
+// filenamePrefix is the file name without directories or extension, and
+// without a compression extension either, so trip.gps.gz gives trip
 func filenamePrefix(filename string) string {
-  f := path.Base(filename)
+  if filename == "-" {
+    return "stdin"
+  }
+  f := path.Base(trimCompressionExt(filename))
   pos := strings.LastIndex(f, ".")
   if pos == -1 {
     return f
@@ -19,7 +19,12 @@
 package cmd
 
 import (
+  "bytes"
+  "io/ioutil"
+  "path/filepath"
+  "strings"
   "testing"
+  "time"
 
   "github.com/asnodgrass/columbus-v1000/v1000"
 )
@@ -56,7 +61,7 @@ func Test_recordToTrackPoint(t *testing.T) {
     Time: "2017-04-01T12:34:56Z",
   }
 
-  out := recordToTrackPoint(data)
+  out := recordToTrackPoint(data, "UTC")
   if out.Latitude != expected.Latitude {
     t.Errorf("Expected Latitude %.6f, got %.6f instead", expected.Latitude, out.Latitude)
   }
@@ -149,3 +154,49 @@ func Test_filenamePrefix(t *testing.T) {
     t.Errorf("Expected %s, but got %s", expected, out)
   }
 }
+
+func Test_gpxSegmentGap(t *testing.T) {
+  t.Log("Checking whether gpx keeps a single track segment unless given --segment-gap..")
+  if f := gpxCmd.Flags().Lookup("segment-gap"); f == nil || f.DefValue != "0s" {
+    t.Errorf("Expected gpx --segment-gap to default to 0s")
+  }
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  for gap, expected := range map[time.Duration]int{0: 1, 5 * time.Minute: 2} {
+    meta := testMeta("trip.gps")
+    meta.SegmentGap = gap
+    var buf bytes.Buffer
+    w := newGPXWriter(&buf, meta)
+    for _, offset := range []time.Duration{0, time.Second, 10 * time.Minute} {
+      w.writeRecord(&v1000.Record{Type: "T", Time: v1000.DateOf(start.Add(offset))})
+    }
+    if err := w.flush(); err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    if n := strings.Count(buf.String(), "<trkseg>"); n != expected {
+      t.Errorf("Expected %d segments with a gap of %s, got %d", expected, gap, n)
+    }
+  }
+}
+
+func Test_runConvertSegmentGap(t *testing.T) {
+  t.Log("Checking whether runConvert splits at the gap it is given, leaving --segment-gap alone..")
+  defer func() { inFile, outFile = "", "" }()
+  dir := t.TempDir()
+  inFile = writeDeviceCSV(t, dir)
+  outFile = filepath.Join(dir, "trip.gpx")
+  for gap, expected := range map[time.Duration]int{0: 1, 5 * time.Minute: 2} {
+    if err := runConvert([]string{"gpx"}, gap); err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    data, err := ioutil.ReadFile(outFile)
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    if n := strings.Count(string(data), "<trkseg>"); n != expected {
+      t.Errorf("Expected %d segments with a gap of %s, got %d", expected, gap, n)
+    }
+  }
+  if segmentGap != 5*time.Minute {
+    t.Errorf("Expected --segment-gap to stay 5m0s, got %s", segmentGap)
+  }
+}
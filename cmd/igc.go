@@ -0,0 +1,217 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var igcPilot string
+var igcGliderType string
+var igcGliderID string
+var igcWholeFile bool
+var igcTakeoffSpeed = v1000.DefaultFlightDetector.MinSpeed
+var igcTakeoffClimb = v1000.DefaultFlightDetector.MinClimb
+var igcTakeoffTime = v1000.DefaultFlightDetector.MinDuration
+
+// igcCmd represents the igc command
+var igcCmd = &cobra.Command{
+  Use:   "igc",
+  Short: "Converts to IGC flight log format",
+  Long: `Converts a Columbus V1000 GPS file to an IGC flight log.
+
+B records carry the pressure altitude, derived from the barometer using the
+International Standard Atmosphere, and the GNSS altitude, along with the
+ground speed and true track as I record extensions. POIs are written as pilot
+events. Only the flight itself is written: it starts at takeoff, the first
+time the logger has been moving or climbing for --takeoff-time, and ends at
+the matching landing. Use --whole-file to keep every record.
+
+The file has no G record, so scoring software will treat it as unsigned.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newIGCWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(igcCmd)
+  igcCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  igcCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  igcCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  igcCmd.Flags().StringVar(&igcPilot, "pilot", "", "pilot name for the H record")
+  igcCmd.Flags().StringVar(&igcGliderType, "glider-type", "", "glider type for the H record")
+  igcCmd.Flags().StringVar(&igcGliderID, "glider-id", "", "glider registration for the H record")
+  igcCmd.Flags().BoolVar(&igcWholeFile, "whole-file", false, "write every record instead of only the flight")
+  igcCmd.Flags().Float64Var(&igcTakeoffSpeed, "takeoff-speed", igcTakeoffSpeed, "ground speed in km/h above which the logger is flying")
+  igcCmd.Flags().Float64Var(&igcTakeoffClimb, "takeoff-climb", igcTakeoffClimb, "climb or sink rate in m/s above which the logger is flying")
+  igcCmd.Flags().DurationVar(&igcTakeoffTime, "takeoff-time", igcTakeoffTime, "how long the logger must be flying to detect takeoff or landing")
+  addFilterFlags(igcCmd)
+}
+
+// igcWriter collects track records and writes them as an IGC file once the
+// flight has been found
+type igcWriter struct {
+  out *bufio.Writer
+  loc *time.Location
+  recs []v1000.Record
+}
+
+func newIGCWriter(out io.Writer, meta export.Meta) (*igcWriter, error) {
+  return &igcWriter{out: bufio.NewWriter(out), loc: meta.Location}, nil
+}
+
+func (w *igcWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *igcWriter) flush() error {
+  recs := w.recs
+  if !igcWholeFile {
+    detector := v1000.FlightDetector{
+      MinSpeed: igcTakeoffSpeed,
+      MinClimb: igcTakeoffClimb,
+      MinDuration: igcTakeoffTime,
+      Location: w.loc,
+    }
+    start, end, ok := detector.Detect(recs)
+    if !ok {
+      return fmt.Errorf("no flight found (use --whole-file to convert anyway)")
+    }
+    recs = recs[start:end]
+  }
+  if len(recs) == 0 {
+    return fmt.Errorf("no records to write")
+  }
+
+  first := recs[0].Time.In(w.loc).UTC()
+  lines := []string{
+    "AXXXCV1 columbus-v1000",
+    "HFDTEDATE:" + first.Format("020106") + ",01",
+    "HFPLTPILOTINCHARGE:" + igcPilot,
+    "HFGTYGLIDERTYPE:" + igcGliderType,
+    "HFGIDGLIDERID:" + igcGliderID,
+    "HFDTMGPSDATUM:WGS84",
+    "HFRFWFIRMWAREVERSION:",
+    "HFRHWHARDWAREVERSION:V1000",
+    "HFFTYFRTYPE:Columbus,V1000",
+    "HFGPSRECEIVER:Columbus,V1000",
+    "HFPRSPRESSALTSENSOR:Columbus,V1000",
+    "HFALGALTGPS:GEO",
+    "HFALPALTPRESSURE:ISA",
+    "HFTZNTIMEZONE:0",
+    // ground speed in bytes 36-38 and true track in 39-41 of each B record
+    "I023638GSP3941TRT",
+  }
+  for _, line := range lines {
+    if _, err := w.out.WriteString(line + "\r\n"); err != nil {
+      return err
+    }
+  }
+  for i := range recs {
+    rec := &recs[i]
+    t := rec.Time.In(w.loc).UTC()
+    if rec.Type == "P" {
+      if _, err := fmt.Fprintf(w.out, "E%sPEV\r\n", t.Format("150405")); err != nil {
+        return err
+      }
+    }
+    if _, err := w.out.WriteString(formatIGCFix(rec, t) + "\r\n"); err != nil {
+      return err
+    }
+  }
+  return w.out.Flush()
+}
+
+// formatIGCFix renders a B record
+func formatIGCFix(rec *v1000.Record, t time.Time) string {
+  validity := "A"
+  if rec.Latitude == 0 && rec.Longitude == 0 {
+    validity = "V"
+  }
+  var pressureAlt float64
+  if rec.Pressure > 0 {
+    pressureAlt = v1000.PressureAltitude(rec.Pressure)
+  }
+  return fmt.Sprintf("B%s%s%s%s%s%s%03d%03d",
+    t.Format("150405"),
+    formatIGCCoord(rec.Latitude, 2, "N", "S"),
+    formatIGCCoord(rec.Longitude, 3, "E", "W"),
+    validity,
+    formatIGCAltitude(pressureAlt),
+    formatIGCAltitude(float64(rec.Altitude)),
+    int(math.Min(math.Round(rec.Speed), 999)),
+    rec.Heading % 360)
+}
+
+// formatIGCCoord renders degrees, minutes and thousandths of a minute
+func formatIGCCoord(value float64, degreeDigits int, positive string, negative string) string {
+  hemisphere := positive
+  if value < 0 {
+    value = -value
+    hemisphere = negative
+  }
+  thousandths := int(math.Round(value * 60000))
+  return fmt.Sprintf("%0*d%05d%s", degreeDigits, thousandths / 60000, thousandths % 60000, hemisphere)
+}
+
+// formatIGCAltitude renders five characters of metres, with a leading minus
+// sign below sea level
+func formatIGCAltitude(metres float64) string {
+  m := int(math.Round(metres))
+  switch {
+  case m < -9999:
+    m = -9999
+  case m > 99999:
+    m = 99999
+  }
+  if m < 0 {
+    return fmt.Sprintf("-%04d", -m)
+  }
+  return fmt.Sprintf("%05d", m)
+}
@@ -0,0 +1,114 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_formatIGCCoord(t *testing.T) {
+  t.Log("Checking whether formatIGCCoord() writes DDMMmmm..")
+  if out := formatIGCCoord(51.5, 2, "N", "S"); out != "5130000N" {
+    t.Errorf("Expected 5130000N, got %s", out)
+  }
+  if out := formatIGCCoord(-0.1234567, 3, "E", "W"); out != "00007407W" {
+    t.Errorf("Expected 00007407W, got %s", out)
+  }
+}
+
+func Test_formatIGCAltitude(t *testing.T) {
+  t.Log("Checking whether formatIGCAltitude() pads to five characters..")
+  cases := map[float64]string{0: "00000", 1234.4: "01234", -12: "-0012", 123456: "99999"}
+  for metres, expected := range cases {
+    if out := formatIGCAltitude(metres); out != expected {
+      t.Errorf("Expected %s for %.1f, got %s", expected, metres, out)
+    }
+  }
+}
+
+func Test_formatIGCFix(t *testing.T) {
+  expected := "B1234565130000N00007407WA0011100500036010"
+  t.Logf("Checking whether formatIGCFix() builds a B record.. (expected: %s)", expected)
+  rec := v1000.Record{
+    Latitude: 51.5,
+    Longitude: -0.1234567,
+    Altitude: 500,
+    Speed: 36.4,
+    Heading: 10,
+    Pressure: barometerPressure(111),
+  }
+  out := formatIGCFix(&rec, time.Date(2017, 4, 1, 12, 34, 56, 0, time.UTC))
+  if out != expected {
+    t.Errorf("Expected %s, but got %s", expected, out)
+  }
+}
+
+// barometerPressure is the ISA pressure at an altitude, rounded to the
+// barometer's resolution
+func barometerPressure(metres float64) float64 {
+  return float64(int(v1000.AltitudePressure(metres) * 10 + 0.5)) / 10
+}
+
+func Test_igcWriter(t *testing.T) {
+  t.Log("Checking whether the IGC writer trims to the flight..")
+  defer func() { igcWholeFile = false }()
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  var buf bytes.Buffer
+  w, err := newIGCWriter(&buf, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for i := 0; i < 120; i++ {
+    rec := v1000.Record{Type: "T", Latitude: 51.5, Longitude: -0.1, Altitude: 500, Pressure: 950,
+      Time: v1000.DateOf(start.Add(time.Duration(i) * time.Second))}
+    if i >= 30 && i < 90 {
+      rec.Speed = 30
+    }
+    if i == 60 {
+      rec.Type = "P"
+    }
+    w.writeRecord(&rec)
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  out := buf.String()
+  if !strings.HasPrefix(out, "AXXX") || !strings.Contains(out, "HFDTEDATE:010417,01\r\n") {
+    t.Errorf("Expected A and H records, got:\n%s", out)
+  }
+  if n := strings.Count(out, "\r\nB"); n != 60 {
+    t.Errorf("Expected 60 B records, got %d", n)
+  }
+  if !strings.Contains(out, "\r\nB120030") || !strings.Contains(out, "\r\nE120100PEV\r\n") {
+    t.Errorf("Expected the flight to start at 12:00:30 with an event at 12:01:00")
+  }
+
+  buf.Reset()
+  igcWholeFile = true
+  w.out.Reset(&buf)
+  w.flush()
+  if n := strings.Count(buf.String(), "\r\nB"); n != 120 {
+    t.Errorf("Expected 120 B records with --whole-file, got %d", n)
+  }
+}
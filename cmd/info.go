@@ -0,0 +1,223 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "text/tabwriter"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var (
+  infoJSON bool
+  infoUnits = "metric"
+)
+
+// infoMaxGaps is how many index gaps the text report lists
+const infoMaxGaps = 10
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+  Use:   "info",
+  Short: "Summarizes a file without converting it",
+  Long: `Prints a summary of a Columbus V1000 GPS file, or any other supported input:
+its format, the header magic and record count implied by the size of a
+binary file, any trailing partial record, the first and last timestamps, the
+range of record indexes and any breaks in it, the number of trackpoints and
+points of interest, and the bounding box.
+
+Distances are given in --units metric or imperial. Use --json for a
+machine-readable report, which is always in SI units.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+    if infoUnits != "metric" && infoUnits != "imperial" {
+      fmt.Printf("error: unknown units %q (expected metric or imperial)\n", infoUnits)
+      os.Exit(1)
+    }
+    report, err := inspectFile(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if infoJSON {
+      err = writeInfoJSON(os.Stdout, report)
+    } else {
+      err = writeInfoText(os.Stdout, report)
+    }
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(infoCmd)
+  infoCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  infoCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  infoCmd.Flags().BoolVar(&infoJSON, "json", false, "print the report as JSON")
+  infoCmd.Flags().StringVar(&infoUnits, "units", infoUnits, "units for the text report: metric or imperial")
+}
+
+// infoReport is the report, as written with --json
+type infoReport struct {
+  File string `json:"file"`
+  Format string `json:"format"`
+  Size int64 `json:"size"`
+  Magic *string `json:"magic,omitempty"`
+  MagicOK *bool `json:"magic_ok,omitempty"`
+  ExpectedRecords *int64 `json:"expected_records,omitempty"`
+  TrailingBytes *int64 `json:"trailing_bytes,omitempty"`
+  Records int `json:"records"`
+  Trackpoints int `json:"trackpoints"`
+  POIs int `json:"pois"`
+  First *time.Time `json:"first,omitempty"`
+  Last *time.Time `json:"last,omitempty"`
+  DurationSeconds float64 `json:"duration_s"`
+  DistanceMetres float64 `json:"distance_m"`
+  IndexMin uint32 `json:"index_min"`
+  IndexMax uint32 `json:"index_max"`
+  IndexGaps []infoGap `json:"index_gaps"`
+  BBox []float64 `json:"bbox,omitempty"`
+}
+
+type infoGap struct {
+  Record int `json:"record"`
+  Prev uint32 `json:"prev"`
+  Next uint32 `json:"next"`
+}
+
+func inspectFile(path string) (infoReport, error) {
+  loc, err := location()
+  if err != nil {
+    return infoReport{}, err
+  }
+  format, err := v1000.ParseFormat(inFormat)
+  if err != nil {
+    return infoReport{}, err
+  }
+  in, err := openFile(path)
+  if err != nil {
+    return infoReport{}, err
+  }
+  defer in.Close()
+  s, err := v1000.Inspect(in, format, loc)
+  if err != nil {
+    return infoReport{}, err
+  }
+
+  report := infoReport{
+    File: sourceName(path),
+    Format: string(s.Format),
+    Size: s.Size,
+    Records: s.Records,
+    Trackpoints: s.Trackpoints,
+    POIs: s.POIs,
+    DurationSeconds: s.Stats.Duration().Seconds(),
+    DistanceMetres: s.Stats.Distance,
+    IndexMin: s.MinIndex,
+    IndexMax: s.MaxIndex,
+    IndexGaps: []infoGap{},
+  }
+  if s.Format == v1000.FormatUnknown {
+    report.Format = "unknown"
+  }
+  if s.Format == v1000.FormatBinary {
+    magic := fmt.Sprintf("0x%04x", s.Magic)
+    ok := s.Magic == v1000.Magic
+    report.Magic, report.MagicOK = &magic, &ok
+    report.ExpectedRecords, report.TrailingBytes = &s.ExpectedRecords, &s.TrailingBytes
+  }
+  if s.Records > 0 {
+    report.First, report.Last = &s.Stats.Start, &s.Stats.End
+    b := s.Stats.Bounds
+    report.BBox = []float64{b.MinLon, b.MinLat, b.MaxLon, b.MaxLat}
+  }
+  for _, g := range s.Gaps {
+    report.IndexGaps = append(report.IndexGaps, infoGap{g.Record, g.Prev, g.Next})
+  }
+  return report, nil
+}
+
+func writeInfoJSON(out io.Writer, report infoReport) error {
+  enc := json.NewEncoder(out)
+  enc.SetIndent("", "  ")
+  return enc.Encode(report)
+}
+
+func writeInfoText(out io.Writer, r infoReport) error {
+  tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+  line := func(name, format string, args ...interface{}) {
+    fmt.Fprintf(tw, "%s:\t%s\n", name, fmt.Sprintf(format, args...))
+  }
+  line("File", "%s", r.File)
+  line("Format", "%s", r.Format)
+  line("Size", "%d bytes", r.Size)
+  if r.Magic != nil {
+    if *r.MagicOK {
+      line("Header", "%s (ok)", *r.Magic)
+    } else {
+      line("Header", "%s (expected 0x%04x)", *r.Magic, v1000.Magic)
+    }
+    line("Records", "%d decoded, %d by size", r.Records, *r.ExpectedRecords)
+    if *r.TrailingBytes > 0 {
+      line("Trailing bytes", "%d (a partial record)", *r.TrailingBytes)
+    } else {
+      line("Trailing bytes", "none")
+    }
+  } else {
+    line("Records", "%d", r.Records)
+  }
+  line("Trackpoints", "%d", r.Trackpoints)
+  line("POIs", "%d", r.POIs)
+  if r.First != nil {
+    line("First", "%s", r.First.Format(time.RFC3339))
+    line("Last", "%s", r.Last.Format(time.RFC3339))
+    line("Duration", "%s", time.Duration(r.DurationSeconds) * time.Second)
+    if infoUnits == "imperial" {
+      line("Distance", "%.2f mi", r.DistanceMetres / 1609.344)
+    } else {
+      line("Distance", "%.2f km", r.DistanceMetres / 1000)
+    }
+    line("Index range", "%d-%d", r.IndexMin, r.IndexMax)
+    line("Bounds", "%.6f,%.6f,%.6f,%.6f", r.BBox[0], r.BBox[1], r.BBox[2], r.BBox[3])
+  }
+  if len(r.IndexGaps) == 0 {
+    line("Index gaps", "none")
+  } else {
+    line("Index gaps", "%d", len(r.IndexGaps))
+    for i, g := range r.IndexGaps {
+      if i == infoMaxGaps {
+        fmt.Fprintf(tw, "\t... and %d more\n", len(r.IndexGaps) - infoMaxGaps)
+        break
+      }
+      fmt.Fprintf(tw, "\t%d followed by %d, at record %d\n", g.Prev, g.Next, g.Record)
+    }
+  }
+  return tw.Flush()
+}
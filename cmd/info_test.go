@@ -0,0 +1,73 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/json"
+  "strings"
+  "testing"
+)
+
+func Test_inspectFile(t *testing.T) {
+  t.Log("Checking whether info reports on a file in text and JSON..")
+  report, err := inspectFile(writeDeviceCSV(t, t.TempDir()))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if report.File != "trip.csv" || report.Format != "csv" || report.Records != 3 || report.POIs != 1 {
+    t.Errorf("Expected 3 CSV records with 1 POI from trip.csv, got %+v", report)
+  }
+  if report.Magic != nil || report.IndexMin != 1 || report.IndexMax != 3 || len(report.IndexGaps) != 0 {
+    t.Errorf("Expected indexes 1 to 3 and no header, got %+v", report)
+  }
+
+  var text bytes.Buffer
+  if err := writeInfoText(&text, report); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for _, expected := range []string{"Records:      3\n", "First:        2017-04-01T12:34:56Z\n", "Duration:     25m4s\n", "Index gaps:   none\n"} {
+    if !strings.Contains(text.String(), expected) {
+      t.Errorf("Expected %q in:\n%s", expected, text.String())
+    }
+  }
+
+  defer func() { infoUnits = "metric" }()
+  infoUnits = "imperial"
+  text.Reset()
+  writeInfoText(&text, report)
+  if !strings.Contains(text.String(), "Distance:     0.65 mi\n") {
+    t.Errorf("Expected the distance in miles, got:\n%s", text.String())
+  }
+
+  var out bytes.Buffer
+  if err := writeInfoJSON(&out, report); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var decoded map[string]interface{}
+  if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if decoded["records"] != 3.0 || decoded["duration_s"] != 1504.0 || len(decoded["bbox"].([]interface{})) != 4 {
+    t.Errorf("Expected records, duration and bbox in %s", out.String())
+  }
+  if _, ok := decoded["magic"]; ok {
+    t.Errorf("Expected no magic for a CSV file, got %s", out.String())
+  }
+}
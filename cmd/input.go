@@ -0,0 +1,121 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "errors"
+  "io"
+  "os"
+  "path/filepath"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var inFormat string
+
+// openInput returns a source for the input, in the format named by
+// --in-format or else detected from its contents
+func openInput(in io.Reader) (v1000.Source, error) {
+  format, err := v1000.ParseFormat(inFormat)
+  if err != nil {
+    return nil, err
+  }
+  loc, err := location()
+  if err != nil {
+    return nil, err
+  }
+  return v1000.OpenSource(in, format, loc)
+}
+
+// sourceName is the input file name, as recorded in outputs that note where
+// their data came from
+func sourceName(path string) string {
+  if path == "-" {
+    return "stdin"
+  }
+  return filepath.Base(path)
+}
+
+// openFile opens the named file, or stdin if path is "-". Input compressed
+// with gzip, zstd or xz is decompressed.
+func openFile(path string) (io.ReadCloser, error) {
+  if path == "-" {
+    return decompress(os.Stdin)
+  }
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  in, err := decompress(file)
+  if err != nil {
+    file.Close()
+    return nil, err
+  }
+  return fileReader{in, file}, nil
+}
+
+// fileReader closes its decompressor, then the file beneath it
+type fileReader struct {
+  io.ReadCloser
+  file *os.File
+}
+
+func (f fileReader) Close() error {
+  f.ReadCloser.Close()
+  return f.file.Close()
+}
+
+// readRecords decodes --in-file and hands every record that passes the filter
+// to fn, stopping at the first error
+func readRecords(filter v1000.Filter, fn func(rec *v1000.Record) error) error {
+  if inFile == "" {
+    return errors.New("error: input file required")
+  }
+  return readFile(inFile, filter, fn)
+}
+
+// readFile is readRecords for the named file, or stdin if path is "-"
+func readFile(path string, filter v1000.Filter, fn func(rec *v1000.Record) error) error {
+  in, err := openFile(path)
+  if err != nil {
+    return err
+  }
+  defer in.Close()
+
+  records, err := openInput(in)
+  if err != nil {
+    return err
+  }
+
+  for {
+    rec, err := records.Read()
+    if err == io.EOF {
+      return nil
+    }
+    if err != nil {
+      return err
+    }
+    if !filter.Match(&rec) {
+      continue
+    }
+    if err := fn(&rec); err != nil {
+      return err
+    }
+  }
+}
@@ -0,0 +1,41 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "strings"
+  "testing"
+)
+
+func Test_openInput(t *testing.T) {
+  t.Log("Checking whether openInput() honours --in-format..")
+  defer func() { inFormat = "" }()
+  input := "\x07\x07"
+  if _, err := openInput(strings.NewReader(input)); err != nil {
+    t.Errorf("Unexpected error detecting binary input: %v", err)
+  }
+  inFormat = "csv"
+  if _, err := openInput(strings.NewReader(input)); err == nil {
+    t.Errorf("Expected binary input read as CSV to fail")
+  }
+  inFormat = "shapefile"
+  if _, err := openInput(strings.NewReader(input)); err == nil {
+    t.Errorf("Expected an unknown --in-format to fail")
+  }
+}
@@ -0,0 +1,203 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var lapStartFinish string
+var lapSectors []string
+var lapMinTime = 10 * time.Second
+var lapTableFile string
+
+// addLapFlags registers the lap timing flags for track day exports
+func addLapFlags(cmd *cobra.Command) {
+  cmd.Flags().StringVar(&lapStartFinish, "start-finish", "", "start/finish line as lon1,lat1,lon2,lat2")
+  cmd.Flags().StringArrayVar(&lapSectors, "sector", nil, "sector line as lon1,lat1,lon2,lat2, in lap order (repeatable)")
+  cmd.Flags().DurationVar(&lapMinTime, "min-lap", lapMinTime, "ignore start/finish crossings sooner than this into a lap")
+  cmd.Flags().StringVar(&lapTableFile, "lap-table", "", "also write the lap table to this file")
+}
+
+// newLapTimer builds a lap timer from the flags; ok is false without a
+// start/finish line
+func newLapTimer(loc *time.Location) (lt v1000.LapTimer, ok bool, err error) {
+  if lapStartFinish == "" {
+    if len(lapSectors) > 0 {
+      return lt, false, fmt.Errorf("--sector requires --start-finish")
+    }
+    return lt, false, nil
+  }
+  if lt.Finish, err = v1000.ParseLine(lapStartFinish); err != nil {
+    return lt, false, err
+  }
+  for _, value := range lapSectors {
+    line, err := v1000.ParseLine(value)
+    if err != nil {
+      return lt, false, err
+    }
+    lt.Sectors = append(lt.Sectors, line)
+  }
+  lt.MinLap = lapMinTime
+  lt.Location = loc
+  return lt, true, nil
+}
+
+// lapSession collects records and times laps over them once they are all in
+type lapSession struct {
+  loc *time.Location
+  timer v1000.LapTimer
+  timed bool
+  recs []v1000.Record
+  laps []v1000.Lap
+  summary v1000.LapSummary
+}
+
+func newLapSession(loc *time.Location) (*lapSession, error) {
+  timer, timed, err := newLapTimer(loc)
+  if err != nil {
+    return nil, err
+  }
+  return &lapSession{loc: loc, timer: timer, timed: timed, summary: v1000.LapSummary{Best: -1}}, nil
+}
+
+func (s *lapSession) writeRecord(rec *v1000.Record) error {
+  s.recs = append(s.recs, *rec)
+  return nil
+}
+
+// finish times the laps and writes --lap-table
+func (s *lapSession) finish() error {
+  if len(s.recs) == 0 {
+    return fmt.Errorf("no records to write")
+  }
+  if !s.timed {
+    return nil
+  }
+  s.laps = s.timer.Laps(s.recs)
+  s.summary = v1000.SummarizeLaps(s.laps)
+  if lapTableFile == "" {
+    return nil
+  }
+  f, err := os.Create(lapTableFile)
+  if err != nil {
+    return err
+  }
+  err = writeLapTable(f, s.laps, s.summary, "")
+  if cerr := f.Close(); err == nil {
+    err = cerr
+  }
+  return err
+}
+
+// lapNumbers gives the lap each record falls in, or 0 outside a lap, and the
+// timing line name crossed just before each record
+func (s *lapSession) lapNumbers() ([]int, []string) {
+  numbers := make([]int, len(s.recs))
+  traps := make([]string, len(s.recs))
+  for _, lap := range s.laps {
+    for i := lap.StartIndex + 1; i <= lap.EndIndex && i < len(s.recs); i++ {
+      numbers[i] = lap.Number
+    }
+  }
+  if s.timed {
+    for _, c := range s.timer.Crossings(s.recs) {
+      if c.Index + 1 < len(traps) {
+        traps[c.Index + 1] = lineName(c.Line)
+      }
+    }
+  }
+  return numbers, traps
+}
+
+func lineName(n int) string {
+  if n == 0 {
+    return "Start/Finish"
+  }
+  return fmt.Sprintf("Sector %d", n)
+}
+
+// writeLapTable writes a plain text table of lap and sector times, the best
+// lap marked with *, with each line starting with prefix
+func writeLapTable(w io.Writer, laps []v1000.Lap, summary v1000.LapSummary, prefix string) error {
+  sectors := 0
+  for _, lap := range laps {
+    if len(lap.Sectors) > sectors {
+      sectors = len(lap.Sectors)
+    }
+  }
+  // without --sector lines each lap is one sector, the whole lap, which
+  // would only repeat the Time column
+  if sectors == 1 {
+    sectors = 0
+  }
+  header := fmt.Sprintf("%-5s %10s", "Lap", "Time")
+  for k := 1; k <= sectors; k++ {
+    header += fmt.Sprintf(" %10s", fmt.Sprintf("S%d", k))
+  }
+  lines := []string{header}
+  for i, lap := range laps {
+    number := fmt.Sprintf("%d", lap.Number)
+    if i == summary.Best {
+      number += "*"
+    }
+    line := fmt.Sprintf("%-5s %10s", number, formatLapTime(lap.Duration()))
+    for k := 0; k < sectors; k++ {
+      split := "-"
+      if k < len(lap.Sectors) {
+        split = formatLapTime(lap.Sectors[k])
+      }
+      line += fmt.Sprintf(" %10s", split)
+    }
+    lines = append(lines, line)
+  }
+  if summary.Best >= 0 {
+    lines = append(lines, "", fmt.Sprintf("Best lap: %d (%s)", laps[summary.Best].Number,
+      formatLapTime(laps[summary.Best].Duration())))
+    if sectors > 0 && summary.BestSectors != nil {
+      splits := make([]string, len(summary.BestSectors))
+      for k, d := range summary.BestSectors {
+        splits[k] = formatLapTime(d)
+      }
+      lines = append(lines, "Best sectors: " + strings.Join(splits, " "),
+        "Theoretical best: " + formatLapTime(summary.TheoreticalBest))
+    }
+  } else {
+    lines = append(lines, "", "No complete laps")
+  }
+  for _, line := range lines {
+    if _, err := fmt.Fprintln(w, strings.TrimRight(prefix + line, " ")); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// formatLapTime formats M:SS.sss
+func formatLapTime(d time.Duration) string {
+  ms := int64(d / time.Millisecond)
+  return fmt.Sprintf("%d:%02d.%03d", ms / 60000, ms / 1000 % 60, ms % 1000)
+}
@@ -0,0 +1,136 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "io/ioutil"
+  "math"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// lapRecords drives anticlockwise round a circle centred on 51.5N 0.1W at a
+// record per second, 6 degrees apart starting 3 degrees past due east, with
+// the first 15 records of lap 2 taking 2s each
+func lapRecords(n int) []v1000.Record {
+  at := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  recs := make([]v1000.Record, n)
+  for k := range recs {
+    a := (3 + 6 * float64(k)) * math.Pi / 180
+    recs[k] = v1000.Record{Type: "T", Latitude: 51.5 + 0.001 * math.Sin(a), Longitude: -0.1 + 0.001 * math.Cos(a),
+      Altitude: 100, Speed: 36, Heading: 90, Time: v1000.DateOf(at)}
+    if k >= 60 && k < 75 {
+      at = at.Add(2 * time.Second)
+    } else {
+      at = at.Add(time.Second)
+    }
+  }
+  return recs
+}
+
+func setLapFlags() func() {
+  lapStartFinish = "-0.0995,51.5,-0.0985,51.5"
+  lapSectors = []string{"-0.1,51.5005,-0.1,51.5015"}
+  return func() {
+    lapStartFinish, lapSectors, lapTableFile = "", nil, ""
+  }
+}
+
+func Test_formatLapTime(t *testing.T) {
+  t.Log("Checking whether formatLapTime() writes M:SS.sss..")
+  if out := formatLapTime(83456 * time.Millisecond); out != "1:23.456" {
+    t.Errorf("Expected 1:23.456, got %s", out)
+  }
+}
+
+func Test_newLapTimer(t *testing.T) {
+  t.Log("Checking whether sector lines need a start/finish line..")
+  defer setLapFlags()()
+  lapStartFinish = ""
+  if _, _, err := newLapTimer(time.UTC); err == nil {
+    t.Errorf("Expected an error for --sector without --start-finish")
+  }
+  lapSectors = nil
+  if _, ok, err := newLapTimer(time.UTC); ok || err != nil {
+    t.Errorf("Expected no lap timing, got %v (%v)", ok, err)
+  }
+}
+
+func Test_writeLapTable(t *testing.T) {
+  t.Log("Checking whether the lap table marks the best lap and theoretical best..")
+  defer setLapFlags()()
+  s, err := newLapSession(time.UTC)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  s.recs = lapRecords(190)
+  if err := s.finish(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var buf bytes.Buffer
+  writeLapTable(&buf, s.laps, s.summary, "# ")
+  expected := strings.Join([]string{
+    "# Lap         Time         S1         S2",
+    "# 1       1:15.000   0:29.500   0:45.500",
+    "# 2*      1:00.000   0:15.000   0:45.000",
+    "#",
+    "# Best lap: 2 (1:00.000)",
+    "# Best sectors: 0:15.000 0:45.000",
+    "# Theoretical best: 1:00.000",
+    "",
+  }, "\n")
+  if buf.String() != expected {
+    t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+  }
+}
+
+func Test_lapTableFile(t *testing.T) {
+  t.Log("Checking whether --lap-table leaves out sector columns without --sector..")
+  defer setLapFlags()()
+  lapSectors = nil
+  lapTableFile = filepath.Join(t.TempDir(), "laps.txt")
+  s, err := newLapSession(time.UTC)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  s.recs = lapRecords(190)
+  if err := s.finish(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  data, err := ioutil.ReadFile(lapTableFile)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := strings.Join([]string{
+    "Lap         Time",
+    "1       1:15.000",
+    "2*      1:00.000",
+    "",
+    "Best lap: 2 (1:00.000)",
+    "",
+  }, "\n")
+  if string(data) != expected {
+    t.Errorf("Expected:\n%s\ngot:\n%s", expected, data)
+  }
+}
@@ -0,0 +1,155 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var nmeaPGRMZ bool
+var nmeaWIMDA bool
+
+// knotsPerKmh converts kilometres per hour to knots
+const knotsPerKmh = 1 / 1.852
+
+// nmeaCmd represents the nmea command
+var nmeaCmd = &cobra.Command{
+  Use:   "nmea",
+  Short: "Converts to NMEA 0183 format",
+  Long: `Converts a Columbus V1000 GPS file to NMEA 0183 sentences.
+
+Each record becomes a GGA, RMC and VTG sentence. Use --pgrmz to add the
+barometric altitude as a Garmin $PGRMZ sentence, and --wimda to add the
+pressure and temperature as a $WIMDA sentence.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newNMEAWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(nmeaCmd)
+  nmeaCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  nmeaCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  nmeaCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  nmeaCmd.Flags().BoolVar(&nmeaPGRMZ, "pgrmz", false, "add $PGRMZ barometric altitude sentences")
+  nmeaCmd.Flags().BoolVar(&nmeaWIMDA, "wimda", false, "add $WIMDA pressure and temperature sentences")
+  addFilterFlags(nmeaCmd)
+}
+
+// nmeaWriter writes records as NMEA sentences
+type nmeaWriter struct {
+  out *bufio.Writer
+  loc *time.Location
+}
+
+func newNMEAWriter(out io.Writer, meta export.Meta) (*nmeaWriter, error) {
+  return &nmeaWriter{out: bufio.NewWriter(out), loc: meta.Location}, nil
+}
+
+func (w *nmeaWriter) writeRecord(rec *v1000.Record) error {
+  t := rec.Time.In(w.loc).UTC()
+  clock := t.Format("150405.00")
+  lat, ns := formatNMEACoord(rec.Latitude, 2, "N", "S")
+  lon, ew := formatNMEACoord(rec.Longitude, 3, "E", "W")
+  knots := fmt.Sprintf("%.1f", rec.Speed * knotsPerKmh)
+  course := fmt.Sprintf("%.1f", float64(rec.Heading))
+
+  sentences := []string{
+    nmeaSentence("GPGGA", clock, lat, ns, lon, ew, "1", "", "",
+      fmt.Sprintf("%.1f", float64(rec.Altitude)), "M", "", "M", "", ""),
+    nmeaSentence("GPRMC", clock, "A", lat, ns, lon, ew, knots, course,
+      t.Format("020106"), "", "", "A"),
+    nmeaSentence("GPVTG", course, "T", "", "M", knots, "N",
+      fmt.Sprintf("%.1f", rec.Speed), "K", "A"),
+  }
+  // inputs without a barometer, such as GPX, have no pressure to report
+  if nmeaPGRMZ && rec.Pressure > 0 {
+    feet := v1000.PressureAltitude(rec.Pressure) / 0.3048
+    sentences = append(sentences, nmeaSentence("PGRMZ", fmt.Sprintf("%.0f", feet), "f", "3"))
+  }
+  if nmeaWIMDA && rec.Pressure > 0 {
+    sentences = append(sentences, nmeaSentence("WIMDA",
+      fmt.Sprintf("%.2f", rec.Pressure * 0.0295300), "I",
+      fmt.Sprintf("%.4f", rec.Pressure / 1000), "B",
+      fmt.Sprintf("%.1f", float64(rec.Temperature)), "C",
+      "", "C", "", "", "", "C", "", "T", "", "M", "", "N", "", "M"))
+  }
+  for _, s := range sentences {
+    if _, err := w.out.WriteString(s); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (w *nmeaWriter) flush() error {
+  return w.out.Flush()
+}
+
+// nmeaSentence joins the fields of a sentence and adds the $ prefix,
+// checksum and line ending
+func nmeaSentence(fields ...string) string {
+  body := strings.Join(fields, ",")
+  return fmt.Sprintf("$%s*%02X\r\n", body, v1000.NMEAChecksum(body))
+}
+
+// formatNMEACoord renders decimal degrees as NMEA's degrees and decimal
+// minutes, with the hemisphere as a separate field
+func formatNMEACoord(value float64, degreeDigits int, positive string, negative string) (string, string) {
+  hemisphere := positive
+  if value < 0 {
+    value = -value
+    hemisphere = negative
+  }
+  // round in minutes so that 59.99999 does not print as 60.0000
+  minutes := math.Round(value * 60 * 10000) / 10000
+  deg := math.Floor(minutes / 60)
+  minutes -= deg * 60
+  return fmt.Sprintf("%0*d%07.4f", degreeDigits, int(deg), minutes), hemisphere
+}
@@ -0,0 +1,104 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "math"
+  "strings"
+  "testing"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_formatNMEACoord(t *testing.T) {
+  t.Log("Checking whether formatNMEACoord() writes degrees and minutes..")
+  cases := []struct {
+    value float64
+    digits int
+    expected string
+    hemisphere string
+  }{
+    {48.1173, 2, "4807.0380", "N"},
+    {-11.516667, 3, "01131.0000", "W"},
+    {-0.9999999, 2, "0100.0000", "S"},
+  }
+  for _, c := range cases {
+    out, hemisphere := formatNMEACoord(c.value, c.digits, "N", "S")
+    if c.digits == 3 {
+      out, hemisphere = formatNMEACoord(c.value, c.digits, "E", "W")
+    }
+    if out != c.expected || hemisphere != c.hemisphere {
+      t.Errorf("Expected %s,%s for %f, got %s,%s", c.expected, c.hemisphere, c.value, out, hemisphere)
+    }
+  }
+}
+
+func Test_nmeaSentence(t *testing.T) {
+  expected := "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n"
+  t.Logf("Checking whether nmeaSentence() adds the checksum.. (expected: %q)", expected)
+  out := nmeaSentence("GPGGA", "123519", "4807.038", "N", "01131.000", "E", "1", "08", "0.9", "545.4", "M", "46.9", "M", "", "")
+  if out != expected {
+    t.Errorf("Expected %q, but got %q", expected, out)
+  }
+}
+
+func Test_nmeaWriter(t *testing.T) {
+  t.Log("Checking whether the NMEA writer's output reads back..")
+  defer func() { nmeaPGRMZ, nmeaWIMDA = false, false }()
+  nmeaPGRMZ, nmeaWIMDA = true, true
+  rec := v1000.Record{
+    Index: 1,
+    Type: "T",
+    Time: v1000.Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56},
+    Latitude: -34.987654,
+    South: true,
+    Longitude: 99.123456,
+    Altitude: 10,
+    Speed: 18.52,
+    Heading: 180,
+    Pressure: 1000.2,
+    Temperature: 20,
+  }
+  var buf bytes.Buffer
+  w, err := newNMEAWriter(&buf, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if err := w.writeRecord(&rec); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  w.flush()
+  for _, prefix := range []string{"$GPGGA,123456.00,", "$GPRMC,", "$GPVTG,180.0,T,,M,10.0,N,18.5,K,A*", "$PGRMZ,", "$WIMDA,29.54,I,1.0002,B,20.0,C,"} {
+    if !strings.Contains(buf.String(), prefix) {
+      t.Errorf("Expected output to contain %q:\n%s", prefix, buf.String())
+    }
+  }
+  out, err := v1000.NewNMEAReader(&buf, nil).Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if out.Time != rec.Time || out.Altitude != rec.Altitude || out.Heading != rec.Heading ||
+    out.Temperature != rec.Temperature || out.Pressure != rec.Pressure {
+    t.Errorf("Expected %+v, got %+v", rec, out)
+  }
+  if math.Abs(out.Latitude - rec.Latitude) > 1e-5 || math.Abs(out.Longitude - rec.Longitude) > 1e-5 {
+    t.Errorf("Expected %f,%f, got %f,%f", rec.Latitude, rec.Longitude, out.Latitude, out.Longitude)
+  }
+}
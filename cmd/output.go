@@ -0,0 +1,65 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "io"
+  "os"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// createOutput creates --out-file, compressed according to its extension, or
+// returns stdout when it is not set or is "-"
+func createOutput() (io.WriteCloser, error) {
+  if outFile == "" || outFile == "-" {
+    return stdout{os.Stdout}, nil
+  }
+  return createFile(outFile)
+}
+
+// stdout is the output when there is no --out-file, which is left open
+type stdout struct {
+  io.Writer
+}
+
+func (stdout) Close() error {
+  return nil
+}
+
+// writeRecords reads the input into w, then flushes it
+func writeRecords(filter v1000.Filter, w recordWriter) error {
+  if err := readRecords(filter, w.writeRecord); err != nil {
+    return err
+  }
+  return w.flush()
+}
+
+// closeOutput closes out, and prints err or the error closing it with a
+// non-zero exit status. A compressed output is only complete once closed.
+func closeOutput(out io.Closer, err error) {
+  if closeErr := out.Close(); err == nil {
+    err = closeErr
+  }
+  if err != nil {
+    fmt.Println(err)
+    os.Exit(1)
+  }
+}
@@ -0,0 +1,55 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "time"
+)
+
+// oziEpoch is day zero of OziExplorer's (and Delphi's) TDateTime
+var oziEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// feetPerMetre converts altitudes, which OziExplorer files give in feet
+const feetPerMetre = 1 / 0.3048
+
+// oziDate converts a time to fractional days since 1899-12-30, in UTC
+func oziDate(t time.Time) float64 {
+  return t.UTC().Sub(oziEpoch).Seconds() / 86400
+}
+
+// oziText makes a name or description safe for OziExplorer's comma
+// separated lines, which have no quoting; Ozi itself stores commas as byte
+// 209 of its ANSI code page
+func oziText(value string) string {
+  out := []byte(value)
+  for i, b := range out {
+    switch b {
+    case ',':
+      out[i] = 209
+    case '\r', '\n':
+      out[i] = ' '
+    }
+  }
+  return string(out)
+}
+
+func formatOziAltitude(metres float64) string {
+  return fmt.Sprintf("%.1f", metres * feetPerMetre)
+}
@@ -0,0 +1,41 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "testing"
+  "time"
+)
+
+func Test_oziDate(t *testing.T) {
+  t.Log("Checking whether oziDate() counts days since 1899-12-30..")
+  if out := oziDate(time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)); out != 42826.5 {
+    t.Errorf("Expected 42826.5, got %f", out)
+  }
+  if out := oziDate(time.Date(2017, 4, 1, 14, 0, 0, 0, time.FixedZone("CEST", 7200))); out != 42826.5 {
+    t.Errorf("Expected 42826.5 in UTC, got %f", out)
+  }
+}
+
+func Test_oziText(t *testing.T) {
+  t.Log("Checking whether oziText() removes commas and line breaks..")
+  if out := oziText("a,b\nc"); out != "a\xd1b c" {
+    t.Errorf("Expected \"a\\xd1b c\", got %q", out)
+  }
+}
@@ -0,0 +1,183 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+  "github.com/asnodgrass/columbus-v1000/parquet"
+)
+
+var parquetRowGroupSize int64 = 128
+var parquetCompression = "snappy"
+
+// parquetCmd represents the parquet command
+var parquetCmd = &cobra.Command{
+  Use:   "parquet",
+  Short: "Converts to GeoParquet format",
+  Long: `Converts a Columbus V1000 GPS file to a GeoParquet file, for loading into
+DuckDB, Spark and other analytics tools.
+
+Each record becomes a row with its index, type, timestamp, position,
+altitude, speed, heading, pressure and temperature, a WKB point geometry, and
+the name of the file it came from, so that merged archives stay traceable.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newParquetWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(parquetCmd)
+  parquetCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  parquetCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  parquetCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  parquetCmd.Flags().Int64Var(&parquetRowGroupSize, "row-group-size", parquetRowGroupSize, "row group size in MiB")
+  parquetCmd.Flags().StringVar(&parquetCompression, "compression", parquetCompression, "compression: snappy, gzip, zstd or none")
+  addFilterFlags(parquetCmd)
+}
+
+// parquetColumns is the Parquet schema
+var parquetColumns = []parquet.Column{
+  {Name: "source_file", Type: parquet.ByteArray, Annotation: parquet.String},
+  {Name: "index", Type: parquet.Int64},
+  {Name: "type", Type: parquet.ByteArray, Annotation: parquet.String},
+  {Name: "timestamp", Type: parquet.Int64, Annotation: parquet.TimestampMillis},
+  {Name: "lat", Type: parquet.Double},
+  {Name: "lon", Type: parquet.Double},
+  {Name: "altitude", Type: parquet.Int32},
+  {Name: "speed", Type: parquet.Double},
+  {Name: "heading", Type: parquet.Int32},
+  {Name: "pressure", Type: parquet.Double},
+  {Name: "temperature", Type: parquet.Int32},
+  {Name: "geometry", Type: parquet.ByteArray},
+}
+
+var parquetCodecs = map[string]parquet.Codec{
+  "snappy": parquet.Snappy,
+  "gzip": parquet.Gzip,
+  "zstd": parquet.Zstd,
+  "none": parquet.Uncompressed,
+}
+
+// parquetWriter writes records as GeoParquet rows
+type parquetWriter struct {
+  pw *parquet.Writer
+  source string
+  loc *time.Location
+  bounds v1000.BoundingBox
+}
+
+func newParquetWriter(out io.Writer, meta export.Meta) (*parquetWriter, error) {
+  codec, ok := parquetCodecs[strings.ToLower(parquetCompression)]
+  if !ok {
+    return nil, fmt.Errorf("unknown compression %q (expected snappy, gzip, zstd or none)", parquetCompression)
+  }
+  if parquetRowGroupSize <= 0 {
+    return nil, fmt.Errorf("invalid --row-group-size %d", parquetRowGroupSize)
+  }
+  pw, err := parquet.NewWriter(out, parquetColumns)
+  if err != nil {
+    return nil, err
+  }
+  pw.RowGroupSize = parquetRowGroupSize * 1024 * 1024
+  pw.Codec = codec
+  return &parquetWriter{
+    pw: pw,
+    source: meta.Source,
+    loc: meta.Location,
+    bounds: v1000.BoundingBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)},
+  }, nil
+}
+
+func (w *parquetWriter) writeRecord(rec *v1000.Record) error {
+  w.bounds.MinLon = math.Min(w.bounds.MinLon, rec.Longitude)
+  w.bounds.MinLat = math.Min(w.bounds.MinLat, rec.Latitude)
+  w.bounds.MaxLon = math.Max(w.bounds.MaxLon, rec.Longitude)
+  w.bounds.MaxLat = math.Max(w.bounds.MaxLat, rec.Latitude)
+  return w.pw.Write(
+    w.source,
+    int64(rec.Index),
+    rec.Type,
+    rec.Time.In(w.loc).UnixNano() / int64(time.Millisecond),
+    rec.Latitude,
+    rec.Longitude,
+    int32(rec.Altitude),
+    rec.Speed,
+    int32(rec.Heading),
+    rec.Pressure,
+    int32(rec.Temperature),
+    v1000.PointXYWKB(rec))
+}
+
+func (w *parquetWriter) flush() error {
+  geo, err := geoParquetMetadata(w.bounds)
+  if err != nil {
+    return err
+  }
+  w.pw.SetMetadata("geo", geo)
+  return w.pw.Close()
+}
+
+// geoParquetMetadata builds the GeoParquet 1.0 "geo" file metadata. Without
+// a crs, readers take coordinates to be OGC:CRS84, longitude/latitude on
+// WGS 84.
+func geoParquetMetadata(bounds v1000.BoundingBox) (string, error) {
+  column := map[string]interface{}{
+    "encoding": "WKB",
+    "geometry_types": []string{"Point"},
+  }
+  if bounds.MinLon <= bounds.MaxLon {
+    column["bbox"] = []float64{bounds.MinLon, bounds.MinLat, bounds.MaxLon, bounds.MaxLat}
+  }
+  b, err := json.Marshal(map[string]interface{}{
+    "version": "1.0.0",
+    "primary_column": "geometry",
+    "columns": map[string]interface{}{"geometry": column},
+  })
+  return string(b), err
+}
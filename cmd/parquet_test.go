@@ -0,0 +1,68 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/json"
+  "testing"
+)
+
+func Test_geoParquetMetadata(t *testing.T) {
+  t.Log("Checking whether GeoParquet metadata carries the bounding box..")
+  w, err := newParquetWriter(&bytes.Buffer{}, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  geo, err := geoParquetMetadata(w.bounds)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if geo != `{"columns":{"geometry":{"encoding":"WKB","geometry_types":["Point"]}},"primary_column":"geometry","version":"1.0.0"}` {
+    t.Errorf("Expected no bbox without records, got %s", geo)
+  }
+
+  w.bounds.MinLon, w.bounds.MinLat, w.bounds.MaxLon, w.bounds.MaxLat = -120.5, 38.5, -120.2, 39
+  geo, _ = geoParquetMetadata(w.bounds)
+  var meta struct {
+    Columns map[string]struct {
+      BBox []float64
+    }
+  }
+  if err := json.Unmarshal([]byte(geo), &meta); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if bbox := meta.Columns["geometry"].BBox; len(bbox) != 4 || bbox[0] != -120.5 || bbox[3] != 39 {
+    t.Errorf("Expected bbox [-120.5 38.5 -120.2 39], got %v", bbox)
+  }
+}
+
+func Test_newParquetWriter(t *testing.T) {
+  t.Log("Checking whether newParquetWriter rejects bad options..")
+  defer func() { parquetCompression = "snappy"; parquetRowGroupSize = 128 }()
+  parquetCompression = "lz4"
+  if _, err := newParquetWriter(&bytes.Buffer{}, testMeta("trip.gps")); err == nil {
+    t.Errorf("Expected an error for compression lz4")
+  }
+  parquetCompression = "ZSTD"
+  parquetRowGroupSize = 0
+  if _, err := newParquetWriter(&bytes.Buffer{}, testMeta("trip.gps")); err == nil {
+    t.Errorf("Expected an error for row group size 0")
+  }
+}
@@ -0,0 +1,118 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// pltCmd represents the plt command
+var pltCmd = &cobra.Command{
+  Use:   "plt",
+  Short: "Converts to OziExplorer track (PLT) format",
+  Long: `Converts the trackpoints of a Columbus V1000 GPS file to an OziExplorer
+track file. A new track segment starts after each gap of --segment-gap.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newPLTWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(pltCmd)
+  pltCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  pltCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  pltCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  addSegmentFlags(pltCmd)
+  addFilterFlags(pltCmd)
+}
+
+// pltWriter collects trackpoints and writes them as an OziExplorer track,
+// which needs the point count up front
+type pltWriter struct {
+  out *bufio.Writer
+  name string
+  loc *time.Location
+  gap time.Duration
+  recs []v1000.Record
+}
+
+func newPLTWriter(out io.Writer, meta export.Meta) (*pltWriter, error) {
+  return &pltWriter{out: bufio.NewWriter(out), name: meta.Name, loc: meta.Location, gap: meta.SegmentGap}, nil
+}
+
+func (w *pltWriter) writeRecord(rec *v1000.Record) error {
+  if rec.Type == "T" {
+    w.recs = append(w.recs, *rec)
+  }
+  return nil
+}
+
+func (w *pltWriter) flush() error {
+  segments := v1000.SplitSegments(w.recs, w.gap, w.loc)
+  fmt.Fprint(w.out, "OziExplorer Track Point File Version 2.1\r\n")
+  fmt.Fprint(w.out, "WGS 84\r\n")
+  fmt.Fprint(w.out, "Altitude is in Feet\r\n")
+  fmt.Fprint(w.out, "Reserved 3\r\n")
+  // width, colour, name, skip, type, fill style and fill colour
+  fmt.Fprintf(w.out, "0,2,255,%s,0,0,2,8421376\r\n", oziText(w.name))
+  fmt.Fprintf(w.out, "%d\r\n", len(w.recs))
+  for _, segment := range segments {
+    for i := range segment {
+      rec := &segment[i]
+      // the break flag starts a new segment
+      brk := 0
+      if i == 0 {
+        brk = 1
+      }
+      t := rec.Time.In(w.loc).UTC()
+      fmt.Fprintf(w.out, "%11.7f,%12.7f,%d,%7s,%.7f,%s,%s\r\n",
+        rec.Latitude, rec.Longitude, brk, formatOziAltitude(float64(rec.Altitude)),
+        oziDate(t), t.Format("02-Jan-06"), t.Format("15:04:05"))
+    }
+  }
+  return w.out.Flush()
+}
@@ -0,0 +1,66 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_pltWriter(t *testing.T) {
+  t.Log("Checking whether the PLT writer flags segment breaks..")
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  var buf bytes.Buffer
+  meta := testMeta("ride.gps")
+  meta.SegmentGap = time.Minute
+  w, err := newPLTWriter(&buf, meta)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for i, offset := range []time.Duration{0, time.Second, 2 * time.Minute, 2 * time.Minute + time.Second} {
+    w.writeRecord(&v1000.Record{Type: "T", Latitude: 51.5, Longitude: -0.1, Altitude: 100,
+      Time: v1000.DateOf(start.Add(offset))})
+    if i == 1 {
+      w.writeRecord(&v1000.Record{Type: "P", Time: v1000.DateOf(start.Add(offset))})
+    }
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  lines := strings.Split(buf.String(), "\r\n")
+  if len(lines) != 11 || lines[0] != "OziExplorer Track Point File Version 2.1" || lines[5] != "4" {
+    t.Fatalf("Expected a header and 4 points, got:\n%s", buf.String())
+  }
+  if lines[4] != "0,2,255,ride,0,0,2,8421376" {
+    t.Errorf("Expected the track name line, got %s", lines[4])
+  }
+  expected := " 51.5000000,  -0.1000000,1,  328.1,42826.5000000,01-Apr-17,12:00:00"
+  if lines[6] != expected {
+    t.Errorf("Expected %q, got %q", expected, lines[6])
+  }
+  for i, brk := range []string{",1,", ",0,", ",1,", ",0,"} {
+    if !strings.Contains(lines[6 + i], brk) {
+      t.Errorf("Expected break flag %s on point %d, got %s", brk, i + 1, lines[6 + i])
+    }
+  }
+}
@@ -0,0 +1,146 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "encoding/csv"
+  "fmt"
+  "io"
+  "os"
+  "strconv"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// racechronoCmd represents the racechrono command
+var racechronoCmd = &cobra.Command{
+  Use:   "racechrono",
+  Short: "Converts to RaceChrono CSV format",
+  Long: `Converts a Columbus V1000 GPS file to CSV that RaceChrono can import.
+
+With --start-finish, and optionally --sector, laps are timed from interpolated
+line crossings; each row carries its lap number, rows just after a timing line
+name it, and the header summarises the best and theoretical best laps.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newRaceChronoWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(racechronoCmd)
+  racechronoCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  racechronoCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  racechronoCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  addLapFlags(racechronoCmd)
+  addFilterFlags(racechronoCmd)
+}
+
+var raceChronoColumns = []string{
+  "Timestamp (s)", "Fragment ID", "Lap #", "Trap name", "Distance (m)",
+  "Latitude (deg)", "Longitude (deg)", "Altitude (m)", "Speed (m/s)", "Bearing (deg)",
+}
+
+// raceChronoWriter collects records and writes them as RaceChrono CSV
+type raceChronoWriter struct {
+  *lapSession
+  out *csv.Writer
+  title string
+}
+
+func newRaceChronoWriter(out io.Writer, meta export.Meta) (*raceChronoWriter, error) {
+  session, err := newLapSession(meta.Location)
+  if err != nil {
+    return nil, err
+  }
+  return &raceChronoWriter{lapSession: session, out: csv.NewWriter(out), title: meta.Name}, nil
+}
+
+func (w *raceChronoWriter) flush() error {
+  if err := w.finish(); err != nil {
+    return err
+  }
+  created := w.recs[0].Time.In(w.loc)
+  preamble := [][]string{
+    {"This file is created using columbus-v1000"},
+    {"Format", "3"},
+    {"Session title", w.title},
+    {"Session type", "Lap timing"},
+    {"Created", created.Format("02/01/2006"), created.Format("15:04")},
+  }
+  if w.summary.Best >= 0 {
+    best := w.laps[w.summary.Best]
+    preamble = append(preamble, []string{"Best lap", strconv.Itoa(best.Number), formatLapTime(best.Duration())})
+    if w.summary.BestSectors != nil && len(w.timer.Sectors) > 0 {
+      preamble = append(preamble, []string{"Theoretical best", formatLapTime(w.summary.TheoreticalBest)})
+    }
+  }
+  preamble = append(preamble, []string{""}, raceChronoColumns)
+  if err := w.out.WriteAll(preamble); err != nil {
+    return err
+  }
+
+  laps, traps := w.lapNumbers()
+  var distance float64
+  for i := range w.recs {
+    rec := &w.recs[i]
+    if i > 0 {
+      distance += v1000.Distance(&w.recs[i - 1], rec)
+    }
+    lap := ""
+    if laps[i] > 0 {
+      lap = strconv.Itoa(laps[i])
+    }
+    w.out.Write([]string{
+      fmt.Sprintf("%d.000", rec.Time.In(w.loc).Unix()),
+      "0",
+      lap,
+      traps[i],
+      fmt.Sprintf("%.2f", distance),
+      fmt.Sprintf("%.7f", rec.Latitude),
+      fmt.Sprintf("%.7f", rec.Longitude),
+      fmt.Sprintf("%d", rec.Altitude),
+      fmt.Sprintf("%.3f", rec.Speed / 3.6),
+      fmt.Sprintf("%d", rec.Heading % 360),
+    })
+  }
+  w.out.Flush()
+  return w.out.Error()
+}
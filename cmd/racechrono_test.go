@@ -0,0 +1,75 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/csv"
+  "strings"
+  "testing"
+)
+
+func Test_raceChronoWriter(t *testing.T) {
+  t.Log("Checking whether RaceChrono rows carry lap numbers and traps..")
+  defer setLapFlags()()
+  var buf bytes.Buffer
+  w, err := newRaceChronoWriter(&buf, testMeta(""))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for _, rec := range lapRecords(190) {
+    w.writeRecord(&rec)
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  out := buf.String()
+  if !strings.Contains(out, "\nBest lap,2,1:00.000\nTheoretical best,1:00.000\n") {
+    t.Errorf("Expected the best lap in the header, got:\n%s", out)
+  }
+
+  header := strings.Index(out, "Timestamp (s),")
+  if header < 0 {
+    t.Fatalf("Expected a column header, got:\n%s", out)
+  }
+  rows, err := csv.NewReader(strings.NewReader(out[header:])).ReadAll()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if len(rows) != 191 {
+    t.Fatalf("Expected 190 rows and a header, got %d", len(rows))
+  }
+  expected := map[int][]string{
+    1: {"1491048000.000", "0", "", "", "0.00", "51.5000523", "-0.0990014", "100", "10.000", "90"},
+    60: {"1491048059.000", "0", "", "", "", "", "", "", "", ""},
+    61: {"1491048060.000", "0", "1", "Start/Finish"},
+    76: {"1491048090.000", "0", "1", "Sector 1"},
+    121: {"1491048135.000", "0", "2", "Start/Finish"},
+  }
+  for i, want := range expected {
+    for j, field := range want {
+      if field != "" && rows[i][j] != field {
+        t.Errorf("Expected row %d column %q to be %s, got %s", i, rows[0][j], field, rows[i][j])
+      }
+    }
+  }
+  if rows[60][2] != "" {
+    t.Errorf("Expected no lap before the first crossing, got %s", rows[60][2])
+  }
+}
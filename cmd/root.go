@@ -21,6 +21,7 @@ package cmd
 import (
   "fmt"
   "os"
+  "time"
 
   "github.com/spf13/cobra"
 )
@@ -35,6 +36,17 @@ var RootCmd = &cobra.Command{
   Use:   "columbus-v1000",
   Short: "A converter for Columbus V1000 GPS files",
   Long: `A converter for Columbus V1000 GPS files.`,
+  // Execute prints the error
+  SilenceErrors: true,
+  PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+    err := loadConfig()
+    if err == nil {
+      err = applyConfig(cmd)
+    }
+    // a bad config is not a usage error
+    cmd.SilenceUsage = err != nil
+    return err
+  },
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
@@ -47,5 +59,12 @@ func Execute() {
 }
 
 func init() {
+  RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ~/.config/columbus-v1000/config.yaml)")
+  RootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "device profile from the config file")
   RootCmd.PersistentFlags().StringVarP(&timeZone, "timezone", "z", "", "Timezone for input file (default: UTC)")
 }
+
+// location returns the time zone that record timestamps are recorded in
+func location() (*time.Location, error) {
+  return time.LoadLocation(timeZone)
+}
@@ -0,0 +1,33 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "time"
+
+  "github.com/spf13/cobra"
+)
+
+var segmentGap = 5 * time.Minute
+
+// addSegmentFlags registers the track segmentation flag for commands whose
+// output has segments, laps or separate lines
+func addSegmentFlags(cmd *cobra.Command) {
+  cmd.Flags().DurationVar(&segmentGap, "segment-gap", segmentGap, "start a new track segment after a gap this long (0 to never split)")
+}
@@ -0,0 +1,229 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "fmt"
+  "os"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/shapefile"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// shpCmd represents the shp command
+var shpCmd = &cobra.Command{
+  Use:   "shp",
+  Short: "Converts to ESRI Shapefile format",
+  Long: `Converts a Columbus V1000 GPS file to ESRI Shapefiles. A Shapefile holds a
+single geometry type, so three layers are written, each as a .shp, .shx, .dbf,
+.prj and .cpg set named after --out-file:
+
+  <out>_tracks  a PolyLineZ per track segment (see --segment-gap), with
+                summary statistics
+  <out>_points  a PointZ per trackpoint, with all of its attributes
+  <out>_pois    a PointZ per point of interest
+
+Coordinates are WGS 84 longitude/latitude, with altitude as Z and Unix time
+as M. Existing files are replaced.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+    if outFile == "" {
+      fmt.Println("error: output file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    w, err := newShpWriter(outFile, meta)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if err := readRecords(filter, w.writeRecord); err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if err := w.flush(); err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(shpCmd)
+  shpCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  shpCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  shpCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file name prefix (required)")
+  addSegmentFlags(shpCmd)
+  addFilterFlags(shpCmd)
+}
+
+// shpTrackFields are the attributes of a track segment
+var shpTrackFields = []shapefile.Field{
+  {Name: "SEGMENT", Type: shapefile.Numeric, Length: 6},
+  {Name: "START", Type: shapefile.Character, Length: 19},
+  {Name: "END", Type: shapefile.Character, Length: 19},
+  {Name: "DURATION_S", Type: shapefile.Numeric, Length: 10},
+  {Name: "POINTS", Type: shapefile.Numeric, Length: 10},
+  {Name: "DIST_M", Type: shapefile.Numeric, Length: 12, Decimals: 1},
+  {Name: "AVG_KMH", Type: shapefile.Numeric, Length: 8, Decimals: 2},
+  {Name: "MAX_KMH", Type: shapefile.Numeric, Length: 8, Decimals: 2},
+  {Name: "MIN_ALT_M", Type: shapefile.Numeric, Length: 8},
+  {Name: "MAX_ALT_M", Type: shapefile.Numeric, Length: 8},
+  {Name: "ASCENT_M", Type: shapefile.Numeric, Length: 8},
+  {Name: "DESCENT_M", Type: shapefile.Numeric, Length: 8},
+  {Name: "AVG_TEMP_C", Type: shapefile.Numeric, Length: 7, Decimals: 1},
+}
+
+// shpRecordFields are the attributes of a trackpoint or POI
+var shpRecordFields = []shapefile.Field{
+  {Name: "INDEX", Type: shapefile.Numeric, Length: 10},
+  {Name: "TYPE", Type: shapefile.Character, Length: 1},
+  {Name: "DATE", Type: shapefile.DateField},
+  {Name: "TIME", Type: shapefile.Character, Length: 8},
+  {Name: "LAT", Type: shapefile.Numeric, Length: 11, Decimals: 6},
+  {Name: "LON", Type: shapefile.Numeric, Length: 11, Decimals: 6},
+  {Name: "ALT_M", Type: shapefile.Numeric, Length: 8},
+  {Name: "SPEED_KMH", Type: shapefile.Numeric, Length: 8, Decimals: 2},
+  {Name: "HEADING", Type: shapefile.Numeric, Length: 3},
+  {Name: "PRESS_HPA", Type: shapefile.Numeric, Length: 7, Decimals: 1},
+  {Name: "TEMP_C", Type: shapefile.Numeric, Length: 5},
+  {Name: "SEGMENT", Type: shapefile.Numeric, Length: 6},
+}
+
+// shpWriter collects records and writes them as Shapefile layers
+type shpWriter struct {
+  base string
+  loc *time.Location
+  gap time.Duration
+  recs []v1000.Record
+}
+
+func newShpWriter(path string, meta export.Meta) (*shpWriter, error) {
+  return &shpWriter{base: strings.TrimSuffix(path, ".shp"), loc: meta.Location, gap: meta.SegmentGap}, nil
+}
+
+func (w *shpWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *shpWriter) flush() error {
+  var track, pois []v1000.Record
+  for _, rec := range w.recs {
+    if rec.Type == "P" {
+      pois = append(pois, rec)
+    } else {
+      track = append(track, rec)
+    }
+  }
+  segments := v1000.SplitSegments(track, w.gap, w.loc)
+
+  tracks, err := shapefile.NewWriter(shapefile.PolyLineZ, shpTrackFields)
+  if err != nil {
+    return err
+  }
+  points, err := shapefile.NewWriter(shapefile.PointZ, shpRecordFields)
+  if err != nil {
+    return err
+  }
+  poiLayer, err := shapefile.NewWriter(shapefile.PointZ, shpRecordFields)
+  if err != nil {
+    return err
+  }
+
+  for i, segment := range segments {
+    for j := range segment {
+      if err := w.addRecord(points, &segment[j], i + 1); err != nil {
+        return err
+      }
+    }
+    // a line needs two points
+    if len(segment) < 2 {
+      continue
+    }
+    line := make([]shapefile.Point, len(segment))
+    for j := range segment {
+      line[j] = w.point(&segment[j])
+    }
+    s := v1000.Summarize(segment, w.loc)
+    err := tracks.Add([][]shapefile.Point{line},
+      i + 1, formatShpTime(s.Start), formatShpTime(s.End), int64(s.Duration().Seconds()), s.Count,
+      s.Distance, s.AvgSpeed(), s.MaxSpeed, s.MinAltitude, s.MaxAltitude, s.Ascent, s.Descent,
+      s.AvgTemperature)
+    if err != nil {
+      return err
+    }
+  }
+  for i := range pois {
+    if err := w.addRecord(poiLayer, &pois[i], 0); err != nil {
+      return err
+    }
+  }
+
+  layers := []struct {
+    suffix string
+    w *shapefile.Writer
+  }{
+    {"_tracks", tracks},
+    {"_points", points},
+    {"_pois", poiLayer},
+  }
+  for _, layer := range layers {
+    if err := layer.w.Create(w.base + layer.suffix, shapefile.WGS84); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// point is a record's vertex, with altitude as Z and Unix time as M
+func (w *shpWriter) point(rec *v1000.Record) shapefile.Point {
+  return shapefile.Point{
+    X: rec.Longitude,
+    Y: rec.Latitude,
+    Z: float64(rec.Altitude),
+    M: float64(rec.Time.In(w.loc).Unix()),
+  }
+}
+
+func (w *shpWriter) addRecord(layer *shapefile.Writer, rec *v1000.Record, segment int) error {
+  t := rec.Time.In(w.loc)
+  return layer.Add([][]shapefile.Point{{w.point(rec)}},
+    int64(rec.Index), rec.Type, t, t.Format("15:04:05"), rec.Latitude, rec.Longitude,
+    rec.Altitude, rec.Speed, rec.Heading, rec.Pressure, rec.Temperature, segment)
+}
+
+// formatShpTime formats a time for a character field, since dBase dates
+// have no time of day
+func formatShpTime(t time.Time) string {
+  return t.Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,83 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "encoding/binary"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_shpWriter(t *testing.T) {
+  t.Log("Checking whether the shp writer writes track, point and POI layers..")
+  dir := t.TempDir()
+  meta := testMeta("trip.gps")
+  meta.SegmentGap = time.Minute
+  w, err := newShpWriter(filepath.Join(dir, "trip.shp"), meta)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  // the last trackpoint is a segment of its own, too short for a line
+  for i, offset := range []time.Duration{0, time.Second, 10 * time.Minute, 10 * time.Minute + time.Second, time.Hour} {
+    w.writeRecord(&v1000.Record{Index: uint32(i + 1), Type: "T", Latitude: 51.5 + float64(i) * 0.001,
+      Longitude: -0.1, Altitude: 100, Speed: 36, Heading: 90, Pressure: 1000, Temperature: 15,
+      Time: v1000.DateOf(start.Add(offset))})
+  }
+  w.writeRecord(&v1000.Record{Index: 6, Type: "P", Latitude: 51.6, Longitude: -0.2,
+    Time: v1000.DateOf(start.Add(2 * time.Hour))})
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  for layer, count := range map[string]uint32{"tracks": 2, "points": 5, "pois": 1} {
+    base := filepath.Join(dir, "trip_" + layer)
+    for _, ext := range []string{".shp", ".shx", ".prj", ".cpg"} {
+      if _, err := os.Stat(base + ext); err != nil {
+        t.Errorf("Expected %s%s, got %v", base, ext, err)
+      }
+    }
+    dbf, err := ioutil.ReadFile(base + ".dbf")
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    if n := binary.LittleEndian.Uint32(dbf[4:]); n != count {
+      t.Errorf("Expected %d %s rows, got %d", count, layer, n)
+    }
+  }
+
+  prj, _ := ioutil.ReadFile(filepath.Join(dir, "trip_points.prj"))
+  if !strings.HasPrefix(string(prj), `GEOGCS["GCS_WGS_1984"`) {
+    t.Errorf("Expected a WGS 84 projection, got %s", prj)
+  }
+  shp, _ := ioutil.ReadFile(filepath.Join(dir, "trip_tracks.shp"))
+  if typ := binary.LittleEndian.Uint32(shp[32:]); typ != 13 {
+    t.Errorf("Expected PolyLineZ tracks, got shape type %d", typ)
+  }
+  dbf, _ := ioutil.ReadFile(filepath.Join(dir, "trip_tracks.dbf"))
+  if !strings.Contains(string(dbf), "2017-04-01T12:00:00") {
+    t.Errorf("Expected the first segment's start time in the track attributes")
+  }
+}
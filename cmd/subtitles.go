@@ -0,0 +1,372 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "text/template"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var subtitleFormat string
+var subtitleVideoStart string
+var subtitleOffset time.Duration
+var subtitleTemplate = `{{printf "%.0f" .Speed}} km/h  {{.Altitude}} m  {{.Heading}}°  {{.Temperature}}°C`
+var assResolution = "1920x1080"
+var assFont = "Arial"
+var assFontSize = 48
+var assColor = "#FFFFFF"
+var assOutlineColor = "#000000"
+var assAlignment = 1
+var assMargin = 40
+var assPosition string
+
+// subtitlesCmd represents the subtitles command
+var subtitlesCmd = &cobra.Command{
+  Use:   "subtitles",
+  Short: "Converts to SRT, ASS or WebVTT subtitles",
+  Long: `Converts a Columbus V1000 GPS file to video subtitles, one cue per record,
+for overlaying telemetry on action camera footage.
+
+Cues are timed relative to --video-start (default: the first record), shifted
+by --offset to correct for clock skew between the camera and the logger. The
+cue text is a Go text/template executed for each record, with the record's
+fields (Speed, Altitude, Heading, Temperature, Pressure, Latitude, Longitude,
+...) plus Time, the record's time, and Elapsed, its offset into the video.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newSubtitleWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(subtitlesCmd)
+  subtitlesCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  subtitlesCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  subtitlesCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  subtitlesCmd.Flags().StringVar(&subtitleFormat, "format", "", "subtitle format: srt, ass or vtt (default: from --out-file, else srt)")
+  subtitlesCmd.Flags().StringVar(&subtitleVideoStart, "video-start", "", "time the video starts, in --timezone (default: first record)")
+  subtitlesCmd.Flags().DurationVar(&subtitleOffset, "offset", 0, "shift every cue by this much, e.g. 2s or -1.5s")
+  subtitlesCmd.Flags().StringVar(&subtitleTemplate, "template", subtitleTemplate, "cue text template")
+  subtitlesCmd.Flags().StringVar(&assResolution, "ass-resolution", assResolution, "ASS video resolution, WIDTHxHEIGHT")
+  subtitlesCmd.Flags().StringVar(&assFont, "ass-font", assFont, "ASS font name")
+  subtitlesCmd.Flags().IntVar(&assFontSize, "ass-font-size", assFontSize, "ASS font size")
+  subtitlesCmd.Flags().StringVar(&assColor, "ass-color", assColor, "ASS text colour, #RRGGBB")
+  subtitlesCmd.Flags().StringVar(&assOutlineColor, "ass-outline-color", assOutlineColor, "ASS outline colour, #RRGGBB")
+  subtitlesCmd.Flags().IntVar(&assAlignment, "ass-alignment", assAlignment, "ASS alignment, 1-9 as on a numeric keypad")
+  subtitlesCmd.Flags().IntVar(&assMargin, "ass-margin", assMargin, "ASS margin from the edges of the video")
+  subtitlesCmd.Flags().StringVar(&assPosition, "ass-position", "", "ASS position X,Y, overriding alignment and margin")
+  addFilterFlags(subtitlesCmd)
+}
+
+// subtitleData is what the cue template is executed with
+type subtitleData struct {
+  *v1000.Record
+  Time time.Time
+  Elapsed time.Duration
+}
+
+// cueWriter writes a subtitle format
+type cueWriter interface {
+  header(out *bufio.Writer) error
+  cue(out *bufio.Writer, n int, start, end time.Duration, text string) error
+}
+
+// subtitleWriter writes one cue per record, each lasting until the next
+type subtitleWriter struct {
+  out *bufio.Writer
+  format cueWriter
+  text *template.Template
+  loc *time.Location
+  start time.Time
+  n int
+  pending *subtitleData
+  lastGap time.Duration
+}
+
+func newSubtitleWriter(out io.Writer, meta export.Meta) (*subtitleWriter, error) {
+  start, err := parseFilterTime(subtitleVideoStart, meta.Location)
+  if err != nil {
+    return nil, err
+  }
+  text, err := template.New("cue").Parse(subtitleTemplate)
+  if err != nil {
+    return nil, err
+  }
+  format, err := newCueWriter(subtitleFormat, outFile)
+  if err != nil {
+    return nil, err
+  }
+  w := &subtitleWriter{
+    out: bufio.NewWriter(out),
+    format: format,
+    text: text,
+    loc: meta.Location,
+    start: start,
+    lastGap: time.Second,
+  }
+  if err := format.header(w.out); err != nil {
+    return nil, err
+  }
+  return w, nil
+}
+
+// newCueWriter picks a format by name, or else by the output file's extension
+func newCueWriter(format, filename string) (cueWriter, error) {
+  if format == "" {
+    format = strings.TrimPrefix(strings.ToLower(filepath.Ext(trimCompressionExt(filename))), ".")
+    if format != "ass" && format != "vtt" {
+      format = "srt"
+    }
+  }
+  switch strings.ToLower(format) {
+  case "srt":
+    return srtWriter{}, nil
+  case "vtt", "webvtt":
+    return vttWriter{}, nil
+  case "ass", "ssa":
+    return newASSWriter()
+  }
+  return nil, fmt.Errorf("unknown subtitle format %q (expected srt, ass or vtt)", format)
+}
+
+func (w *subtitleWriter) writeRecord(rec *v1000.Record) error {
+  r := *rec
+  t := r.Time.In(w.loc)
+  if w.start.IsZero() {
+    w.start = t
+  }
+  data := &subtitleData{Record: &r, Time: t, Elapsed: t.Sub(w.start) + subtitleOffset}
+  if w.pending != nil {
+    if gap := data.Elapsed - w.pending.Elapsed; gap > 0 {
+      w.lastGap = gap
+    }
+    if err := w.writeCue(w.pending, data.Elapsed); err != nil {
+      return err
+    }
+  }
+  w.pending = data
+  return nil
+}
+
+func (w *subtitleWriter) flush() error {
+  if w.pending != nil {
+    if err := w.writeCue(w.pending, w.pending.Elapsed + w.lastGap); err != nil {
+      return err
+    }
+    w.pending = nil
+  }
+  return w.out.Flush()
+}
+
+// writeCue writes data's cue, dropping it if it ends before the video starts
+func (w *subtitleWriter) writeCue(data *subtitleData, end time.Duration) error {
+  start := data.Elapsed
+  if start < 0 {
+    start = 0
+  }
+  if end <= start {
+    return nil
+  }
+  var text bytes.Buffer
+  if err := w.text.Execute(&text, data); err != nil {
+    return err
+  }
+  w.n++
+  return w.format.cue(w.out, w.n, start, end, strings.TrimSpace(text.String()))
+}
+
+// formatCueTime formats an offset as H+:MM:SS followed by sep and fraction
+// digits of the seconds
+func formatCueTime(d time.Duration, sep string, digits int) string {
+  unit := time.Second
+  for i := 0; i < digits; i++ {
+    unit /= 10
+  }
+  n := int64(d / unit)
+  scale := int64(time.Second / unit)
+  frac := n % scale
+  secs := n / scale
+  return fmt.Sprintf("%02d:%02d:%02d%s%0*d", secs / 3600, secs / 60 % 60, secs % 60, sep, digits, frac)
+}
+
+// nonBlankLines drops empty lines, which end a cue in SRT and WebVTT
+func nonBlankLines(text string) []string {
+  var lines []string
+  for _, line := range strings.Split(text, "\n") {
+    if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+      lines = append(lines, line)
+    }
+  }
+  return lines
+}
+
+type srtWriter struct{}
+
+func (srtWriter) header(out *bufio.Writer) error {
+  return nil
+}
+
+func (srtWriter) cue(out *bufio.Writer, n int, start, end time.Duration, text string) error {
+  _, err := fmt.Fprintf(out, "%d\n%s --> %s\n%s\n\n", n,
+    formatCueTime(start, ",", 3), formatCueTime(end, ",", 3),
+    strings.Join(nonBlankLines(text), "\n"))
+  return err
+}
+
+type vttWriter struct{}
+
+func (vttWriter) header(out *bufio.Writer) error {
+  _, err := out.WriteString("WEBVTT\n\n")
+  return err
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (vttWriter) cue(out *bufio.Writer, n int, start, end time.Duration, text string) error {
+  _, err := fmt.Fprintf(out, "%d\n%s --> %s\n%s\n\n", n,
+    formatCueTime(start, ".", 3), formatCueTime(end, ".", 3),
+    vttEscaper.Replace(strings.Join(nonBlankLines(text), "\n")))
+  return err
+}
+
+// assWriter writes Advanced SubStation Alpha, with a single style built from
+// the --ass-* flags
+type assWriter struct {
+  width, height int
+  color, outline string
+  override string
+}
+
+func newASSWriter() (*assWriter, error) {
+  w := &assWriter{}
+  var err error
+  if w.width, w.height, err = parseASSPair(assResolution, "x"); err != nil {
+    return nil, fmt.Errorf("invalid --ass-resolution: %v", err)
+  }
+  if w.color, err = assColour(assColor); err != nil {
+    return nil, fmt.Errorf("invalid --ass-color: %v", err)
+  }
+  if w.outline, err = assColour(assOutlineColor); err != nil {
+    return nil, fmt.Errorf("invalid --ass-outline-color: %v", err)
+  }
+  if assAlignment < 1 || assAlignment > 9 {
+    return nil, fmt.Errorf("invalid --ass-alignment %d (expected 1-9)", assAlignment)
+  }
+  if assPosition != "" {
+    x, y, err := parseASSPair(assPosition, ",")
+    if err != nil {
+      return nil, fmt.Errorf("invalid --ass-position: %v", err)
+    }
+    w.override = fmt.Sprintf(`{\pos(%d,%d)}`, x, y)
+  }
+  return w, nil
+}
+
+func (w *assWriter) header(out *bufio.Writer) error {
+  _, err := fmt.Fprintf(out, `[Script Info]
+ScriptType: v4.00+
+Title: columbus-v1000 telemetry
+PlayResX: %d
+PlayResY: %d
+WrapStyle: 2
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Telemetry,%s,%d,%s,%s,%s,&H80000000,0,0,0,0,100,100,0,0,1,2,0,%d,%d,%d,%d,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`, w.width, w.height, assFont, assFontSize, w.color, w.color, w.outline,
+    assAlignment, assMargin, assMargin, assMargin)
+  return err
+}
+
+var assEscaper = strings.NewReplacer("\r", "", "\n", `\N`, "{", `\{`, "}", `\}`)
+
+func (w *assWriter) cue(out *bufio.Writer, n int, start, end time.Duration, text string) error {
+  _, err := fmt.Fprintf(out, "Dialogue: 0,%s,%s,Telemetry,,0,0,0,,%s%s\n",
+    formatASSTime(start), formatASSTime(end), w.override, assEscaper.Replace(text))
+  return err
+}
+
+// formatASSTime formats H:MM:SS.cc, as ASS uses a single hour digit
+func formatASSTime(d time.Duration) string {
+  return strings.TrimPrefix(formatCueTime(d, ".", 2), "0")
+}
+
+// assColour converts #RRGGBB to ASS's &H00BBGGRR
+func assColour(value string) (string, error) {
+  hex := strings.TrimPrefix(value, "#")
+  if len(hex) != 6 {
+    return "", fmt.Errorf("expected #RRGGBB, got %q", value)
+  }
+  if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+    return "", fmt.Errorf("expected #RRGGBB, got %q", value)
+  }
+  hex = strings.ToUpper(hex)
+  return "&H00" + hex[4:6] + hex[2:4] + hex[0:2], nil
+}
+
+func parseASSPair(value, sep string) (int, int, error) {
+  parts := strings.Split(value, sep)
+  if len(parts) != 2 {
+    return 0, 0, fmt.Errorf("expected two numbers separated by %q, got %q", sep, value)
+  }
+  a, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+  if err != nil {
+    return 0, 0, err
+  }
+  b, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+  if err != nil {
+    return 0, 0, err
+  }
+  return a, b, nil
+}
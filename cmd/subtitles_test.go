@@ -0,0 +1,117 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_formatCueTime(t *testing.T) {
+  t.Log("Checking whether cue times are formatted per format..")
+  d := time.Hour + 2 * time.Minute + 3 * time.Second + 456 * time.Millisecond
+  if out := formatCueTime(d, ",", 3); out != "01:02:03,456" {
+    t.Errorf("Expected 01:02:03,456, got %s", out)
+  }
+  if out := formatASSTime(d); out != "1:02:03.45" {
+    t.Errorf("Expected 1:02:03.45, got %s", out)
+  }
+}
+
+func Test_assColour(t *testing.T) {
+  t.Log("Checking whether assColour() converts #RRGGBB to &H00BBGGRR..")
+  if out, err := assColour("#ff8000"); err != nil || out != "&H000080FF" {
+    t.Errorf("Expected &H000080FF, got %s (%v)", out, err)
+  }
+  if _, err := assColour("red"); err == nil {
+    t.Errorf("Expected an error for red")
+  }
+}
+
+func Test_newCueWriter(t *testing.T) {
+  t.Log("Checking whether the subtitle format follows the output extension..")
+  if w, _ := newCueWriter("", "ride.vtt"); w != (vttWriter{}) {
+    t.Errorf("Expected WebVTT for ride.vtt, got %T", w)
+  }
+  if w, _ := newCueWriter("", "ride.vtt.gz"); w != (vttWriter{}) {
+    t.Errorf("Expected WebVTT for ride.vtt.gz, got %T", w)
+  }
+  if w, _ := newCueWriter("", ""); w != (srtWriter{}) {
+    t.Errorf("Expected SRT by default, got %T", w)
+  }
+  if _, err := newCueWriter("sub", ""); err == nil {
+    t.Errorf("Expected an error for format sub")
+  }
+}
+
+func writeSubtitles(t *testing.T) string {
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  var buf bytes.Buffer
+  w, err := newSubtitleWriter(&buf, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for i := 0; i < 3; i++ {
+    w.writeRecord(&v1000.Record{Speed: 36.4 + float64(i), Altitude: 100, Heading: 90, Temperature: 15,
+      Time: v1000.DateOf(start.Add(time.Duration(i) * 2 * time.Second))})
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return buf.String()
+}
+
+func Test_subtitleWriter(t *testing.T) {
+  t.Log("Checking whether cues are timed from the video start plus offset..")
+  defer func() {
+    subtitleFormat, subtitleVideoStart, subtitleOffset = "", "", 0
+    assPosition = ""
+  }()
+  subtitleVideoStart = "2017-04-01T12:00:01"
+  subtitleOffset = 500 * time.Millisecond
+
+  subtitleFormat = "srt"
+  out := writeSubtitles(t)
+  expected := "1\n00:00:00,000 --> 00:00:01,500\n36 km/h  100 m  90°  15°C\n\n" +
+    "2\n00:00:01,500 --> 00:00:03,500\n37 km/h  100 m  90°  15°C\n\n" +
+    "3\n00:00:03,500 --> 00:00:05,500\n38 km/h  100 m  90°  15°C\n\n"
+  if out != expected {
+    t.Errorf("Expected:\n%s\ngot:\n%s", expected, out)
+  }
+
+  subtitleFormat = "vtt"
+  if out := writeSubtitles(t); !strings.HasPrefix(out, "WEBVTT\n\n1\n00:00:00.000 --> 00:00:01.500\n") {
+    t.Errorf("Expected a WebVTT file, got:\n%s", out)
+  }
+
+  subtitleFormat = "ass"
+  assPosition = "100,200"
+  out = writeSubtitles(t)
+  if !strings.Contains(out, "PlayResX: 1920\nPlayResY: 1080\n") ||
+    !strings.Contains(out, "Style: Telemetry,Arial,48,&H00FFFFFF,") {
+    t.Errorf("Expected script info and style, got:\n%s", out)
+  }
+  if !strings.Contains(out, "Dialogue: 0,0:00:01.50,0:00:03.50,Telemetry,,0,0,0,,{\\pos(100,200)}37 km/h") {
+    t.Errorf("Expected a positioned dialogue line, got:\n%s", out)
+  }
+}
@@ -0,0 +1,220 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/xml"
+  "fmt"
+  "io"
+  "os"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var tcxSport = "other"
+
+// tcxSports maps --sport names onto TCX's Sport_t values
+var tcxSports = map[string]string{
+  "running": "Running",
+  "biking": "Biking",
+  "cycling": "Biking",
+  "other": "Other",
+}
+
+// tcxCmd represents the tcx command
+var tcxCmd = &cobra.Command{
+  Use:   "tcx",
+  Short: "Converts to TCX (Training Center XML) format",
+  Long: `Converts a Columbus V1000 GPS file to a Training Center XML activity.
+
+Each track segment (see --segment-gap) becomes a lap with its own time,
+distance and speed summary. Trackpoints carry the cumulative distance
+computed from the coordinates.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newTCXWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(tcxCmd)
+  tcxCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  tcxCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  tcxCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  tcxCmd.Flags().StringVar(&tcxSport, "sport", tcxSport, "activity sport: running, biking or other")
+  addSegmentFlags(tcxCmd)
+  addFilterFlags(tcxCmd)
+}
+
+type tcxDatabase struct {
+  XMLName xml.Name `xml:"TrainingCenterDatabase"`
+  Namespace string `xml:"xmlns,attr"`
+  Activities []tcxActivity `xml:"Activities>Activity"`
+}
+
+type tcxActivity struct {
+  Sport string `xml:"Sport,attr"`
+  ID string `xml:"Id"`
+  Laps []tcxLap `xml:"Lap"`
+}
+
+type tcxLap struct {
+  StartTime string `xml:"StartTime,attr"`
+  TotalTimeSeconds float64 `xml:"TotalTimeSeconds"`
+  DistanceMeters meters `xml:"DistanceMeters"`
+  MaximumSpeed meters `xml:"MaximumSpeed"`
+  Calories int `xml:"Calories"`
+  Intensity string `xml:"Intensity"`
+  TriggerMethod string `xml:"TriggerMethod"`
+  Trackpoints []tcxTrackpoint `xml:"Track>Trackpoint"`
+  AvgSpeed meters `xml:"Extensions>LX>AvgSpeed"`
+}
+
+type tcxTrackpoint struct {
+  Time string `xml:"Time"`
+  Latitude latLong `xml:"Position>LatitudeDegrees"`
+  Longitude latLong `xml:"Position>LongitudeDegrees"`
+  AltitudeMeters meters `xml:"AltitudeMeters"`
+  DistanceMeters meters `xml:"DistanceMeters"`
+  Speed meters `xml:"Extensions>TPX>Speed"`
+}
+
+// meters ...
+type meters float64
+
+// MarshalXML ...
+func (value meters) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+  return e.EncodeElement(fmt.Sprintf("%.2f", value), start)
+}
+
+// tcxWriter collects records and writes them as a TCX activity
+type tcxWriter struct {
+  out io.Writer
+  loc *time.Location
+  gap time.Duration
+  sport string
+  recs []v1000.Record
+}
+
+func newTCXWriter(out io.Writer, meta export.Meta) (*tcxWriter, error) {
+  sport, ok := tcxSports[tcxSport]
+  if !ok {
+    return nil, fmt.Errorf("unknown sport %q (expected running, biking or other)", tcxSport)
+  }
+  return &tcxWriter{out: out, loc: meta.Location, gap: meta.SegmentGap, sport: sport}, nil
+}
+
+func (w *tcxWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *tcxWriter) flush() error {
+  if len(w.recs) == 0 {
+    return fmt.Errorf("no records to write")
+  }
+  segments := v1000.SplitSegments(w.recs, w.gap, w.loc)
+
+  activity := tcxActivity{
+    Sport: w.sport,
+    ID: formatTCXTime(w.recs[0].Time, w.loc),
+  }
+  var distance float64
+  for _, segment := range segments {
+    stats := v1000.Summarize(segment, w.loc)
+    lap := tcxLap{
+      StartTime: formatTCXTime(segment[0].Time, w.loc),
+      TotalTimeSeconds: stats.Duration().Seconds(),
+      DistanceMeters: meters(stats.Distance),
+      MaximumSpeed: meters(stats.MaxSpeed / 3.6),
+      Intensity: "Active",
+      TriggerMethod: "Manual",
+      AvgSpeed: meters(stats.AvgSpeed() / 3.6),
+    }
+    for i := range segment {
+      rec := &segment[i]
+      if i > 0 {
+        distance += v1000.Distance(&segment[i - 1], rec)
+      }
+      lap.Trackpoints = append(lap.Trackpoints, tcxTrackpoint{
+        Time: formatTCXTime(rec.Time, w.loc),
+        Latitude: latLong(rec.Latitude),
+        Longitude: latLong(rec.Longitude),
+        AltitudeMeters: meters(rec.Altitude),
+        DistanceMeters: meters(distance),
+        Speed: meters(rec.Speed / 3.6),
+      })
+    }
+    activity.Laps = append(activity.Laps, lap)
+  }
+
+  db := tcxDatabase{
+    Namespace: "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2",
+    Activities: []tcxActivity{activity},
+  }
+  body, err := xml.MarshalIndent(db, "", "  ")
+  if err != nil {
+    return err
+  }
+  body = tcxExtensionNamespaces(body)
+  if _, err := io.WriteString(w.out, xml.Header); err != nil {
+    return err
+  }
+  if _, err := w.out.Write(body); err != nil {
+    return err
+  }
+  _, err = io.WriteString(w.out, "\n")
+  return err
+}
+
+// tcxExtensionNamespaces puts the ActivityExtension namespace on the LX and
+// TPX elements, which encoding/xml cannot express through nested tags
+func tcxExtensionNamespaces(body []byte) []byte {
+  ns := ` xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2">`
+  body = bytes.Replace(body, []byte("<LX>"), []byte("<LX" + ns), -1)
+  return bytes.Replace(body, []byte("<TPX>"), []byte("<TPX" + ns), -1)
+}
+
+// formatTCXTime formats a record time in UTC, as TCX consumers expect
+func formatTCXTime(date v1000.Date, loc *time.Location) string {
+  return date.In(loc).UTC().Format(time.RFC3339)
+}
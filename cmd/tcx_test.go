@@ -0,0 +1,105 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/xml"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_formatTCXTime(t *testing.T) {
+  expected := "2017-04-01T10:34:56Z"
+  t.Logf("Checking whether formatTCXTime() converts to UTC.. (expected: %s)", expected)
+  loc := time.FixedZone("CEST", 2 * 3600)
+  data := v1000.Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56}
+  if out := formatTCXTime(data, loc); out != expected {
+    t.Errorf("Expected '%s', but got '%s'", expected, out)
+  }
+}
+
+func Test_tcxWriter(t *testing.T) {
+  t.Log("Checking whether the TCX writer builds a lap per segment..")
+  defer func() { tcxSport = "other" }()
+  tcxSport = "biking"
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  var buf bytes.Buffer
+  meta := testMeta("trip.gps")
+  meta.SegmentGap = time.Minute
+  w, err := newTCXWriter(&buf, meta)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for i := 0; i < 6; i++ {
+    offset := time.Duration(i) * 10 * time.Second
+    if i >= 3 {
+      offset += 10 * time.Minute
+    }
+    w.writeRecord(&v1000.Record{Type: "T", Latitude: 51.5 + float64(i) * 0.001, Longitude: -0.1,
+      Altitude: 100, Speed: 36, Time: v1000.DateOf(start.Add(offset))})
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  out := buf.String()
+  if !strings.Contains(out, `<Activity Sport="Biking">`) {
+    t.Errorf("Expected a Biking activity, got:\n%s", out)
+  }
+  if !strings.Contains(out, `<TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2">`) {
+    t.Errorf("Expected namespaced TPX extensions, got:\n%s", out)
+  }
+
+  var db struct {
+    Laps []struct {
+      TotalTimeSeconds float64
+      DistanceMeters float64
+      MaximumSpeed float64
+      Trackpoints []struct {
+        Time string
+        AltitudeMeters float64
+        DistanceMeters float64
+      } `xml:"Track>Trackpoint"`
+    } `xml:"Activities>Activity>Lap"`
+  }
+  if err := xml.Unmarshal(buf.Bytes(), &db); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if len(db.Laps) != 2 {
+    t.Fatalf("Expected 2 laps, got %d", len(db.Laps))
+  }
+  lap := db.Laps[1]
+  if lap.TotalTimeSeconds != 20 || lap.MaximumSpeed != 10 || len(lap.Trackpoints) != 3 {
+    t.Errorf("Expected a 20s lap at up to 10 m/s with 3 trackpoints, got %+v", lap)
+  }
+  if lap.DistanceMeters < 220 || lap.DistanceMeters > 225 {
+    t.Errorf("Expected about 222m in the lap, got %.2f", lap.DistanceMeters)
+  }
+  last := lap.Trackpoints[2]
+  if last.Time != "2017-04-01T12:10:50Z" || last.AltitudeMeters != 100 {
+    t.Errorf("Expected the last trackpoint at 12:10:50 and 100m, got %+v", last)
+  }
+  if last.DistanceMeters < 440 || last.DistanceMeters > 450 {
+    t.Errorf("Expected about 445m cumulative distance, got %.2f", last.DistanceMeters)
+  }
+}
@@ -0,0 +1,250 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "encoding/json"
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "path/filepath"
+  "text/template"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var templateFile string
+
+// templateCmd represents the template command
+var templateCmd = &cobra.Command{
+  Use:   "template",
+  Short: "Converts using a user-defined Go text/template",
+  Long: `Converts a Columbus V1000 GPS file to any text format, by rendering the
+records through a Go text/template file.
+
+The template file may define three sections, each optional:
+
+  {{define "header"}}...{{end}}  rendered once, before the records
+  {{define "record"}}...{{end}}  rendered for each record
+  {{define "footer"}}...{{end}}  rendered once, after the records
+
+A file that defines none of them is rendered for each record. The header and
+footer get .Name, .Source and .Stats (Count, Start, End, Distance, MaxSpeed,
+Bounds, ...). Each record gets its fields (Index, Type, Latitude, Longitude,
+Altitude, Speed, Heading, Pressure, Temperature) plus .Number, counting from
+1, .Time, .Elapsed since the first record and .Distance, the running
+distance in metres.
+
+Helper functions:
+
+  mph, knots, ms      convert a speed from km/h
+  feet, km, miles     convert a distance from metres
+  fahrenheit          convert a temperature from degrees C
+  inHg                convert a pressure from hPa
+  lat, lon            format a coordinate as the device does, e.g. 51.500000N
+  dms                 format a coordinate as degrees, minutes and seconds
+  round               round to a number of decimal places
+  formatTime          format a time with a Go layout
+  rfc3339, unix       format a time as RFC 3339 or Unix seconds
+  json                quote a value for JSON, e.g. a name
+
+Example templates are in examples/templates.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+    if templateFile == "" {
+      fmt.Println("error: template file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newTemplateWriter(out, templateFile, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(templateCmd)
+  templateCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  templateCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  templateCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  templateCmd.Flags().StringVarP(&templateFile, "template", "t", "", "template file (required)")
+  addFilterFlags(templateCmd)
+}
+
+// templateDoc is what the header and footer are executed with
+type templateDoc struct {
+  Name string
+  Source string
+  Stats v1000.Stats
+}
+
+// templateRecord is what the record section is executed with
+type templateRecord struct {
+  *v1000.Record
+  Number int
+  Time time.Time
+  Elapsed time.Duration
+  Distance float64 // metres from the first record
+}
+
+// templateFuncs are the helper functions available to templates
+var templateFuncs = template.FuncMap{
+  "mph": func(kmh interface{}) float64 { return templateFloat(kmh) / 1.609344 },
+  "knots": func(kmh interface{}) float64 { return templateFloat(kmh) / 1.852 },
+  "ms": func(kmh interface{}) float64 { return templateFloat(kmh) / 3.6 },
+  "feet": func(m interface{}) float64 { return templateFloat(m) * feetPerMetre },
+  "km": func(m interface{}) float64 { return templateFloat(m) / 1000 },
+  "miles": func(m interface{}) float64 { return templateFloat(m) / 1609.344 },
+  "fahrenheit": func(c interface{}) float64 { return templateFloat(c) * 9 / 5 + 32 },
+  "inHg": func(hPa interface{}) float64 { return templateFloat(hPa) / 33.8639 },
+  "lat": func(v float64) string { return formatLatLon(v, true) },
+  "lon": func(v float64) string { return formatLatLon(v, false) },
+  "dms": formatDMS,
+  "round": func(v interface{}, places int) float64 {
+    scale := math.Pow(10, float64(places))
+    return math.Round(templateFloat(v) * scale) / scale
+  },
+  "formatTime": func(layout string, t time.Time) string { return t.Format(layout) },
+  "rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+  "unix": func(t time.Time) int64 { return t.Unix() },
+  "json": func(v interface{}) (string, error) {
+    b, err := json.Marshal(v)
+    return string(b), err
+  },
+}
+
+// templateFloat converts the numeric record fields to float64, so helpers
+// take them whatever their type
+func templateFloat(v interface{}) float64 {
+  switch n := v.(type) {
+  case float64:
+    return n
+  case int:
+    return float64(n)
+  case int64:
+    return float64(n)
+  case uint16:
+    return float64(n)
+  case uint32:
+    return float64(n)
+  }
+  return math.NaN()
+}
+
+// formatDMS formats a coordinate as degrees, minutes and seconds with a
+// hemisphere, e.g. 51°30'00.0"N
+func formatDMS(v float64, northSouth bool) string {
+  hemisphere := map[bool]string{true: "E", false: "W"}
+  if northSouth {
+    hemisphere = map[bool]string{true: "N", false: "S"}
+  }
+  h := hemisphere[v >= 0]
+  v = math.Abs(v)
+  // round to tenths of a second first, so 59.96" does not print as 60.0"
+  tenths := int64(math.Round(v * 36000))
+  return fmt.Sprintf("%d°%02d'%04.1f\"%s", tenths / 36000, tenths % 36000 / 600, float64(tenths % 600) / 10, h)
+}
+
+// templateWriter collects records and renders them through a template
+type templateWriter struct {
+  out *bufio.Writer
+  header, record, footer *template.Template
+  name, source string
+  loc *time.Location
+  recs []v1000.Record
+}
+
+func newTemplateWriter(out io.Writer, path string, meta export.Meta) (*templateWriter, error) {
+  t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+  if err != nil {
+    return nil, err
+  }
+  w := &templateWriter{
+    out: bufio.NewWriter(out),
+    header: t.Lookup("header"),
+    record: t.Lookup("record"),
+    footer: t.Lookup("footer"),
+    name: meta.Name,
+    source: meta.Source,
+    loc: meta.Location,
+  }
+  if w.header == nil && w.record == nil && w.footer == nil {
+    w.record = t
+  }
+  return w, nil
+}
+
+func (w *templateWriter) writeRecord(rec *v1000.Record) error {
+  w.recs = append(w.recs, *rec)
+  return nil
+}
+
+func (w *templateWriter) flush() error {
+  doc := templateDoc{
+    Name: w.name,
+    Source: w.source,
+    Stats: v1000.Summarize(w.recs, w.loc),
+  }
+  if w.header != nil {
+    if err := w.header.Execute(w.out, doc); err != nil {
+      return err
+    }
+  }
+  if w.record != nil {
+    var distance float64
+    for i := range w.recs {
+      rec := &w.recs[i]
+      if i > 0 {
+        distance += v1000.Distance(&w.recs[i - 1], rec)
+      }
+      t := rec.Time.In(w.loc)
+      data := templateRecord{Record: rec, Number: i + 1, Time: t, Elapsed: t.Sub(doc.Stats.Start), Distance: distance}
+      if err := w.record.Execute(w.out, data); err != nil {
+        return err
+      }
+    }
+  }
+  if w.footer != nil {
+    if err := w.footer.Execute(w.out, doc); err != nil {
+      return err
+    }
+  }
+  return w.out.Flush()
+}
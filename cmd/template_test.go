@@ -0,0 +1,153 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/json"
+  "encoding/xml"
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// renderTemplate renders two trackpoints a kilometre apart and a POI
+func renderTemplate(t *testing.T, path, source string) string {
+  var buf bytes.Buffer
+  w, err := newTemplateWriter(&buf, path, testMeta(source))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  w.writeRecord(&v1000.Record{Index: 1, Type: "T", Latitude: 51.5, Longitude: -0.1, Altitude: 100, Speed: 36,
+    Temperature: 15, Time: v1000.DateOf(start)})
+  w.writeRecord(&v1000.Record{Index: 2, Type: "T", Latitude: 51.509, Longitude: -0.1, Altitude: 110, Speed: 36,
+    Temperature: 16, Time: v1000.DateOf(start.Add(100 * time.Second))})
+  w.writeRecord(&v1000.Record{Index: 3, Type: "P", Latitude: 51.509, Longitude: -0.1, Altitude: 110,
+    Time: v1000.DateOf(start.Add(100 * time.Second))})
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return buf.String()
+}
+
+func writeTemplate(t *testing.T, text string) string {
+  path := filepath.Join(t.TempDir(), "test.tmpl")
+  if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return path
+}
+
+func Test_templateWriter(t *testing.T) {
+  t.Log("Checking whether the template writer renders header, records and footer..")
+  path := writeTemplate(t, `{{define "header"}}{{.Stats.Count}} records
+{{end}}{{define "record"}}{{.Number}} {{.Type}} {{formatTime "15:04:05" .Time}} {{.Elapsed}} {{printf "%.0f" .Distance}}
+{{end}}{{define "footer"}}{{printf "%.1f" (km .Stats.Distance)}} km
+{{end}}`)
+  expected := "3 records\n1 T 12:00:00 0s 0\n2 T 12:01:40 1m40s 1001\n3 P 12:01:40 1m40s 1001\n1.0 km\n"
+  if out := renderTemplate(t, path, "trip.gps"); out != expected {
+    t.Errorf("Expected %q, got %q", expected, out)
+  }
+
+  path = writeTemplate(t, `{{.Index}},{{lat .Latitude}},{{lon .Longitude}};`)
+  expected = "1,51.500000N,0.100000W;2,51.509000N,0.100000W;3,51.509000N,0.100000W;"
+  if out := renderTemplate(t, path, "trip.gps"); out != expected {
+    t.Errorf("Expected a plain template to render per record, got %q", out)
+  }
+
+  if _, err := newTemplateWriter(&bytes.Buffer{}, writeTemplate(t, `{{.Speed`), testMeta("trip.gps")); err == nil {
+    t.Errorf("Expected a parse error")
+  }
+}
+
+func Test_templateFuncs(t *testing.T) {
+  t.Log("Checking whether template helpers convert units from any numeric field..")
+  cases := []struct {
+    fn string
+    in interface{}
+    expected float64
+  }{
+    {"mph", 160.9344, 100},
+    {"knots", 1.852, 1},
+    {"ms", 36.0, 10},
+    {"feet", uint32(3048), 10000},
+    {"km", 1500.0, 1.5},
+    {"fahrenheit", uint16(100), 212},
+  }
+  for _, c := range cases {
+    out := templateFuncs[c.fn].(func(interface{}) float64)(c.in)
+    if out < c.expected - 1e-9 || out > c.expected + 1e-9 {
+      t.Errorf("Expected %s(%v) to be %v, got %v", c.fn, c.in, c.expected, out)
+    }
+  }
+  if out := templateFuncs["round"].(func(interface{}, int) float64)(1.2345, 2); out != 1.23 {
+    t.Errorf("Expected 1.23, got %v", out)
+  }
+}
+
+func Test_formatDMS(t *testing.T) {
+  t.Log("Checking whether formatDMS formats degrees, minutes and seconds..")
+  cases := map[float64]string{51.5: `51°30'00.0"N`, -0.1: `0°06'00.0"S`, -33.999999: `34°00'00.0"S`}
+  for in, expected := range cases {
+    if out := formatDMS(in, true); out != expected {
+      t.Errorf("Expected %s, got %s", expected, out)
+    }
+  }
+  if out := formatDMS(-0.1, false); out != `0°06'00.0"W` {
+    t.Errorf("Expected 0°06'00.0\"W, got %s", out)
+  }
+}
+
+func Test_exampleTemplates(t *testing.T) {
+  t.Log("Checking whether the example templates render valid output..")
+  var geojson struct {
+    Name string
+    Features []struct {
+      Properties map[string]interface{}
+    }
+  }
+  out := renderTemplate(t, "../examples/templates/geojson.tmpl", `/logs/"quoted" \ trip.gps`)
+  if err := json.Unmarshal([]byte(out), &geojson); err != nil {
+    t.Errorf("Expected valid JSON, got %v: %s", err, out)
+  } else if len(geojson.Features) != 3 || geojson.Features[1].Properties["distance_m"] != 1000.8 {
+    t.Errorf("Expected 3 features with running distances, got %v", geojson.Features)
+  } else if geojson.Name != `"quoted" \ trip` {
+    t.Errorf("Expected the name \"quoted\" \\ trip, got %s", geojson.Name)
+  }
+
+  var kml struct {
+    Coordinates string `xml:"Document>Placemark>LineString>coordinates"`
+  }
+  out = renderTemplate(t, "../examples/templates/kml.tmpl", "trip.gps")
+  if err := xml.Unmarshal([]byte(out), &kml); err != nil {
+    t.Errorf("Expected valid XML, got %v: %s", err, out)
+  } else if fields := strings.Fields(kml.Coordinates); len(fields) != 2 || fields[1] != "-0.1,51.509,110" {
+    t.Errorf("Expected the two trackpoints, got %v", fields)
+  }
+
+  out = renderTemplate(t, "../examples/templates/report.md.tmpl", "trip.gps")
+  if !strings.Contains(out, `| 12:01:40 | 51°30'32.4"N 0°06'00.0"W | 361 ft | 22 mph | 61 °F | 0.62 mi |`) {
+    t.Errorf("Expected a report row in imperial units, got %s", out)
+  }
+}
@@ -0,0 +1,319 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var tsdbFormat = "influx"
+var tsdbMeasurement = "v1000"
+var tsdbDevice string
+var tsdbMaxFileSize int64
+
+// tsdbCmd represents the tsdb command
+var tsdbCmd = &cobra.Command{
+  Use:   "tsdb",
+  Short: "Converts to time-series database formats",
+  Long: `Converts a Columbus V1000 GPS file for loading into a time-series database.
+
+  influx       InfluxDB line protocol, one line per record with nanosecond
+               timestamps
+  openmetrics  OpenMetrics text format, one gauge per field, for backfilling
+               Prometheus with "promtool tsdb create-blocks-from openmetrics"
+
+Records are tagged with the source file name, the record type and, if given,
+the --device serial. With --max-file-size the output is split into files of
+at most that many MiB, numbered after --out-file (trip.lp becomes
+trip-0001.lp, trip-0002.lp and so on).`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+    if tsdbMaxFileSize > 0 && outFile == "" {
+      fmt.Println("error: output file required with --max-file-size")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    w, err := newTSDBWriter(meta)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    if tsdbMaxFileSize > 0 {
+      w.path, w.maxSize = outFile, tsdbMaxFileSize * 1024 * 1024
+      if err := writeRecords(filter, w); err != nil {
+        fmt.Println(err)
+        os.Exit(1)
+      }
+      return
+    }
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w.out = out
+    closeOutput(out, writeRecords(filter, w))
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(tsdbCmd)
+  tsdbCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  tsdbCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  tsdbCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  tsdbCmd.Flags().StringVar(&tsdbFormat, "format", tsdbFormat, "output format: influx or openmetrics")
+  tsdbCmd.Flags().StringVar(&tsdbMeasurement, "measurement", tsdbMeasurement, "measurement name, or metric name prefix")
+  tsdbCmd.Flags().StringVar(&tsdbDevice, "device", "", "device serial, added as a tag")
+  tsdbCmd.Flags().Int64Var(&tsdbMaxFileSize, "max-file-size", 0, "split output into files of at most this many MiB")
+  addFilterFlags(tsdbCmd)
+}
+
+// tsdbMetric is an OpenMetrics gauge taken from a record field
+type tsdbMetric struct {
+  name string
+  unit string
+  help string
+  value func(rec *v1000.Record) float64
+}
+
+var tsdbMetrics = []tsdbMetric{
+  {"latitude", "degrees", "Latitude, WGS 84", func(rec *v1000.Record) float64 { return rec.Latitude }},
+  {"longitude", "degrees", "Longitude, WGS 84", func(rec *v1000.Record) float64 { return rec.Longitude }},
+  {"altitude", "meters", "GPS altitude", func(rec *v1000.Record) float64 { return float64(rec.Altitude) }},
+  {"speed", "kilometers_per_hour", "Ground speed", func(rec *v1000.Record) float64 { return rec.Speed }},
+  {"heading", "degrees", "Course over ground", func(rec *v1000.Record) float64 { return float64(rec.Heading) }},
+  {"pressure", "hectopascals", "Barometric pressure", func(rec *v1000.Record) float64 { return rec.Pressure }},
+  {"temperature", "celsius", "Temperature", func(rec *v1000.Record) float64 { return float64(rec.Temperature) }},
+}
+
+// tsdbWriter writes records in batches. A batch holds a block of lines per
+// family (one for line protocol, one per metric for OpenMetrics), since
+// OpenMetrics does not allow a metric's samples to be interleaved with
+// another's.
+type tsdbWriter struct {
+  format string
+  measurement string
+  source string
+  device string
+  loc *time.Location
+
+  out io.Writer // when not split into files
+  path string
+  maxSize int64
+  files int
+
+  families []bytes.Buffer
+  size int64
+  count int
+}
+
+var tsdbName = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+func newTSDBWriter(meta export.Meta) (*tsdbWriter, error) {
+  w := &tsdbWriter{format: strings.ToLower(tsdbFormat), source: meta.Source, device: tsdbDevice, loc: meta.Location}
+  switch w.format {
+  case "influx":
+    if tsdbMeasurement == "" {
+      return nil, fmt.Errorf("measurement name required")
+    }
+    w.measurement = tsdbMeasurement
+    w.families = make([]bytes.Buffer, 1)
+  case "openmetrics":
+    w.measurement = tsdbName.ReplaceAllString(tsdbMeasurement, "_")
+    if w.measurement == "" || (w.measurement[0] >= '0' && w.measurement[0] <= '9') {
+      return nil, fmt.Errorf("invalid metric name prefix %q", tsdbMeasurement)
+    }
+    w.families = make([]bytes.Buffer, len(tsdbMetrics))
+  default:
+    return nil, fmt.Errorf("unknown format %q (expected influx or openmetrics)", tsdbFormat)
+  }
+  w.size = w.overhead()
+  return w, nil
+}
+
+func (w *tsdbWriter) writeRecord(rec *v1000.Record) error {
+  lines := w.encode(rec)
+  var n int64
+  for _, line := range lines {
+    n += int64(len(line))
+  }
+  if w.maxSize > 0 && w.count > 0 && w.size + n > w.maxSize {
+    if err := w.flushBatch(); err != nil {
+      return err
+    }
+  }
+  for i, line := range lines {
+    w.families[i].WriteString(line)
+  }
+  w.size += n
+  w.count++
+  return nil
+}
+
+func (w *tsdbWriter) flush() error {
+  if w.count == 0 && w.files > 0 {
+    return nil
+  }
+  return w.flushBatch()
+}
+
+// flushBatch writes the batch to the output, or to the next numbered file
+func (w *tsdbWriter) flushBatch() error {
+  if w.out != nil {
+    return w.writeBatch(w.out)
+  }
+  w.files++
+  f, err := createFile(tsdbBatchPath(w.path, w.files))
+  if err != nil {
+    return err
+  }
+  err = w.writeBatch(f)
+  if closeErr := f.Close(); err == nil {
+    err = closeErr
+  }
+  return err
+}
+
+func (w *tsdbWriter) writeBatch(out io.Writer) error {
+  for i := range w.families {
+    if w.format == "openmetrics" {
+      if _, err := io.WriteString(out, w.metadata(tsdbMetrics[i])); err != nil {
+        return err
+      }
+    }
+    if _, err := w.families[i].WriteTo(out); err != nil {
+      return err
+    }
+  }
+  if w.format == "openmetrics" {
+    if _, err := io.WriteString(out, "# EOF\n"); err != nil {
+      return err
+    }
+  }
+  w.size, w.count = w.overhead(), 0
+  return nil
+}
+
+// overhead is the size of a batch with no records
+func (w *tsdbWriter) overhead() int64 {
+  if w.format != "openmetrics" {
+    return 0
+  }
+  n := int64(len("# EOF\n"))
+  for _, m := range tsdbMetrics {
+    n += int64(len(w.metadata(m)))
+  }
+  return n
+}
+
+// encode returns a record's line for each family
+func (w *tsdbWriter) encode(rec *v1000.Record) []string {
+  t := rec.Time.In(w.loc)
+  if w.format == "openmetrics" {
+    labels := w.labels(rec)
+    lines := make([]string, len(tsdbMetrics))
+    for i, m := range tsdbMetrics {
+      lines[i] = fmt.Sprintf("%s%s %s %d\n", w.metricName(m), labels, formatTSDBFloat(m.value(rec)), t.Unix())
+    }
+    return lines
+  }
+
+  var b strings.Builder
+  b.WriteString(influxEscape(w.measurement, ", "))
+  if w.device != "" {
+    b.WriteString(",device=" + influxEscape(w.device, ",= "))
+  }
+  b.WriteString(",source=" + influxEscape(w.source, ",= "))
+  b.WriteString(",type=" + influxEscape(rec.Type, ",= "))
+  fmt.Fprintf(&b, " index=%di,lat=%s,lon=%s,altitude=%di,speed=%s,heading=%di,pressure=%s,temperature=%di %d\n",
+    rec.Index, formatTSDBFloat(rec.Latitude), formatTSDBFloat(rec.Longitude), rec.Altitude,
+    formatTSDBFloat(rec.Speed), rec.Heading, formatTSDBFloat(rec.Pressure), rec.Temperature, t.UnixNano())
+  return []string{b.String()}
+}
+
+func (w *tsdbWriter) metricName(m tsdbMetric) string {
+  return w.measurement + "_" + m.name + "_" + m.unit
+}
+
+// metadata is the TYPE, UNIT and HELP lines of a metric family
+func (w *tsdbWriter) metadata(m tsdbMetric) string {
+  name := w.metricName(m)
+  return fmt.Sprintf("# TYPE %s gauge\n# UNIT %s %s\n# HELP %s %s\n", name, name, m.unit, name, m.help)
+}
+
+func (w *tsdbWriter) labels(rec *v1000.Record) string {
+  var labels []string
+  if w.device != "" {
+    labels = append(labels, `device="` + openMetricsEscape(w.device) + `"`)
+  }
+  labels = append(labels, `source="` + openMetricsEscape(w.source) + `"`, `type="` + openMetricsEscape(rec.Type) + `"`)
+  return "{" + strings.Join(labels, ",") + "}"
+}
+
+// influxEscape backslash escapes the given characters, as line protocol
+// requires in measurements and tags. Backslashes themselves need no escape.
+func influxEscape(s, chars string) string {
+  var b strings.Builder
+  for _, c := range s {
+    if strings.ContainsRune(chars, c) {
+      b.WriteByte('\\')
+    }
+    b.WriteRune(c)
+  }
+  return b.String()
+}
+
+var openMetricsReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func openMetricsEscape(s string) string {
+  return openMetricsReplacer.Replace(s)
+}
+
+func formatTSDBFloat(v float64) string {
+  return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// tsdbBatchPath numbers path for the nth batch: trip.lp becomes trip-0001.lp,
+// and trip.lp.gz becomes trip-0001.lp.gz
+func tsdbBatchPath(path string, n int) string {
+  base := trimCompressionExt(path)
+  ext := filepath.Ext(base) + path[len(base):]
+  return fmt.Sprintf("%s-%04d%s", strings.TrimSuffix(base, filepath.Ext(base)), n, ext)
+}
@@ -0,0 +1,140 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func tsdbRecord(i int) *v1000.Record {
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  return &v1000.Record{Index: uint32(i), Type: "T", Latitude: 51.5, Longitude: -0.1, Altitude: 100,
+    Speed: 36.5, Heading: 90, Pressure: 1013.2, Temperature: 15,
+    Time: v1000.DateOf(start.Add(time.Duration(i) * time.Second))}
+}
+
+func Test_tsdbWriter_influx(t *testing.T) {
+  t.Log("Checking whether the tsdb writer writes InfluxDB line protocol..")
+  defer func() { tsdbDevice = "" }()
+  tsdbDevice = "SN 1,2"
+  w, err := newTSDBWriter(testMeta("my trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var buf bytes.Buffer
+  w.out = &buf
+  w.writeRecord(tsdbRecord(1))
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := `v1000,device=SN\ 1\,2,source=my\ trip.gps,type=T index=1i,lat=51.5,lon=-0.1,altitude=100i,` +
+    "speed=36.5,heading=90i,pressure=1013.2,temperature=15i 1491048001000000000\n"
+  if buf.String() != expected {
+    t.Errorf("Expected %s, got %s", expected, buf.String())
+  }
+}
+
+func Test_tsdbWriter_openmetrics(t *testing.T) {
+  t.Log("Checking whether the tsdb writer groups OpenMetrics samples by metric..")
+  defer func() { tsdbFormat = "influx"; tsdbMeasurement = "v1000" }()
+  tsdbFormat = "openmetrics"
+  tsdbMeasurement = "gps-logger"
+  w, err := newTSDBWriter(testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var buf bytes.Buffer
+  w.out = &buf
+  w.writeRecord(tsdbRecord(1))
+  w.writeRecord(tsdbRecord(2))
+  w.flush()
+  lines := strings.Split(buf.String(), "\n")
+  expected := []string{
+    "# TYPE gps_logger_latitude_degrees gauge",
+    "# UNIT gps_logger_latitude_degrees degrees",
+    "# HELP gps_logger_latitude_degrees Latitude, WGS 84",
+    `gps_logger_latitude_degrees{source="trip.gps",type="T"} 51.5 1491048001`,
+    `gps_logger_latitude_degrees{source="trip.gps",type="T"} 51.5 1491048002`,
+    "# TYPE gps_logger_longitude_degrees gauge",
+  }
+  for i, line := range expected {
+    if lines[i] != line {
+      t.Errorf("Expected line %d to be %s, got %s", i + 1, line, lines[i])
+    }
+  }
+  if !strings.HasSuffix(buf.String(), "gps_logger_temperature_celsius{source=\"trip.gps\",type=\"T\"} 15 1491048002\n# EOF\n") {
+    t.Errorf("Expected the temperature samples and # EOF last, got %s", buf.String())
+  }
+}
+
+func Test_tsdbWriter_batches(t *testing.T) {
+  t.Log("Checking whether the tsdb writer splits output into bounded files..")
+  defer func() { tsdbFormat = "influx" }()
+  for _, format := range []string{"influx", "openmetrics"} {
+    tsdbFormat = format
+    dir := t.TempDir()
+    w, err := newTSDBWriter(testMeta("trip.gps"))
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    w.path = filepath.Join(dir, "trip.txt")
+    w.maxSize = w.overhead() + int64(len(strings.Join(w.encode(tsdbRecord(1)), ""))) * 2
+    for i := 1; i <= 5; i++ {
+      if err := w.writeRecord(tsdbRecord(i)); err != nil {
+        t.Fatalf("Unexpected error: %v", err)
+      }
+    }
+    if err := w.flush(); err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    files, _ := filepath.Glob(filepath.Join(dir, "*"))
+    if len(files) != 3 || filepath.Base(files[2]) != "trip-0003.txt" {
+      t.Errorf("Expected 3 %s files, got %v", format, files)
+    }
+    for _, f := range files {
+      info, _ := os.Stat(f)
+      if info.Size() > w.maxSize {
+        t.Errorf("Expected %s to be at most %d bytes, got %d", f, w.maxSize, info.Size())
+      }
+    }
+    if format == "openmetrics" {
+      last, _ := ioutil.ReadFile(files[2])
+      if !strings.HasPrefix(string(last), "# TYPE") || !strings.HasSuffix(string(last), "# EOF\n") {
+        t.Errorf("Expected every file to be complete, got %s", last)
+      }
+    }
+  }
+}
+
+func Test_newTSDBWriter(t *testing.T) {
+  t.Log("Checking whether newTSDBWriter rejects unknown formats..")
+  defer func() { tsdbFormat = "influx" }()
+  tsdbFormat = "graphite"
+  if _, err := newTSDBWriter(testMeta("trip.gps")); err == nil {
+    t.Errorf("Expected an error for format graphite")
+  }
+}
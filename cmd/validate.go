@@ -0,0 +1,312 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "encoding/json"
+  "encoding/xml"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+var (
+  validateFormat = "text"
+  validateStrict bool
+)
+
+// Exit statuses of validate
+const (
+  validateOK = 0
+  validateFailed = 1 // errors found, or warnings with --strict
+  validateUnreadable = 2 // a file could not be read
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+  Use:   "validate [files, globs or directories...]",
+  Short: "Checks binary files against the format",
+  Long: `Checks Columbus V1000 binary files against the invariants of the format, and
+reports every issue with its byte offset, severity and rule:
+
+{{rules}}
+Files are given with --in-file (- for stdin) or as arguments: files, globs
+and directories, which are searched recursively for files matching
+--include. The report is written as --format text, json or junit (JUnit XML
+for CI test reports) to --out-file or stdout.
+
+The exit status is 0 if no errors were found, 1 if there were errors (or
+warnings, with --strict), and 2 if a file could not be read.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    os.Exit(runValidate(args))
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(validateCmd)
+  validateCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file")
+  validateCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "report file")
+  validateCmd.Flags().StringVarP(&validateFormat, "format", "f", validateFormat, "report format: text, json or junit")
+  validateCmd.Flags().BoolVar(&validateStrict, "strict", false, "fail on warnings too")
+  validateCmd.Flags().StringVar(&batchInclude, "include", batchInclude, "file name pattern to check in directories")
+
+  var rules strings.Builder
+  for _, r := range v1000.Rules {
+    fmt.Fprintf(&rules, "  %-18s %-8s %s\n", r.ID, r.Severity, r.Description)
+  }
+  validateCmd.Long = strings.Replace(validateCmd.Long, "{{rules}}", rules.String(), 1)
+}
+
+// validateResult is the outcome of checking one file
+type validateResult struct {
+  File string `json:"file"`
+  Issues []v1000.Issue `json:"issues"`
+  Errors int `json:"errors"`
+  Warnings int `json:"warnings"`
+  Failure string `json:"failure,omitempty"` // why the file could not be read
+}
+
+// failed reports whether the result should fail the run
+func (r validateResult) failed() bool {
+  return r.Failure != "" || r.Errors > 0 || (validateStrict && r.Warnings > 0)
+}
+
+func runValidate(args []string) int {
+  var paths []string
+  if inFile == "-" {
+    paths = append(paths, inFile)
+  } else if inFile != "" {
+    args = append([]string{inFile}, args...)
+  }
+  if len(args) > 0 {
+    inputs, err := findInputs(args)
+    if err != nil {
+      fmt.Println(err)
+      return validateUnreadable
+    }
+    for _, in := range inputs {
+      paths = append(paths, in.path)
+    }
+  }
+  if len(paths) == 0 {
+    fmt.Println("error: input file required")
+    return validateUnreadable
+  }
+
+  var write func(io.Writer, []validateResult) error
+  switch strings.ToLower(validateFormat) {
+  case "text":
+    write = writeValidateText
+  case "json":
+    write = writeValidateJSON
+  case "junit":
+    write = writeValidateJUnit
+  default:
+    fmt.Printf("error: unknown report format %q (expected text, json or junit)\n", validateFormat)
+    return validateUnreadable
+  }
+
+  results := make([]validateResult, len(paths))
+  for i, path := range paths {
+    results[i] = validateFile(path)
+  }
+  out, err := createOutput()
+  if err != nil {
+    fmt.Println(err)
+    return validateUnreadable
+  }
+  err = write(out, results)
+  if closeErr := out.Close(); err == nil {
+    err = closeErr
+  }
+  if err != nil {
+    fmt.Println(err)
+    return validateUnreadable
+  }
+  return validateStatus(results)
+}
+
+func validateFile(path string) validateResult {
+  result := validateResult{File: sourceName(path), Issues: []v1000.Issue{}}
+  if path != "-" {
+    result.File = path
+  }
+  in, err := openFile(path)
+  if err != nil {
+    result.Failure = err.Error()
+    return result
+  }
+  defer in.Close()
+  issues, err := v1000.Validate(in)
+  if err != nil {
+    result.Failure = err.Error()
+    return result
+  }
+  result.Issues = issues
+  for _, issue := range issues {
+    if issue.Severity == v1000.Error {
+      result.Errors++
+    } else {
+      result.Warnings++
+    }
+  }
+  return result
+}
+
+func validateStatus(results []validateResult) int {
+  status := validateOK
+  for _, r := range results {
+    if r.Failure != "" {
+      return validateUnreadable
+    }
+    if r.failed() {
+      status = validateFailed
+    }
+  }
+  return status
+}
+
+func writeValidateText(out io.Writer, results []validateResult) error {
+  var errors, warnings int
+  for _, r := range results {
+    if r.Failure != "" {
+      fmt.Fprintf(out, "%s: unreadable: %s\n", r.File, r.Failure)
+      continue
+    }
+    for _, issue := range r.Issues {
+      where := ""
+      if issue.Record >= 0 {
+        where = fmt.Sprintf("record %d: ", issue.Record)
+      }
+      fmt.Fprintf(out, "%s:%d: %s: %s%s (%s)\n", r.File, issue.Offset, issue.Severity, where, issue.Message, issue.Rule)
+    }
+    if len(r.Issues) == 0 {
+      fmt.Fprintf(out, "%s: ok\n", r.File)
+    }
+    errors += r.Errors
+    warnings += r.Warnings
+  }
+  _, err := fmt.Fprintf(out, "%d files: %d errors, %d warnings\n", len(results), errors, warnings)
+  return err
+}
+
+func writeValidateJSON(out io.Writer, results []validateResult) error {
+  report := struct {
+    Files []validateResult `json:"files"`
+    Errors int `json:"errors"`
+    Warnings int `json:"warnings"`
+    Status int `json:"status"`
+  }{Files: results, Status: validateStatus(results)}
+  for _, r := range results {
+    report.Errors += r.Errors
+    report.Warnings += r.Warnings
+  }
+  enc := json.NewEncoder(out)
+  enc.SetIndent("", "  ")
+  return enc.Encode(report)
+}
+
+type junitSuites struct {
+  XMLName xml.Name `xml:"testsuites"`
+  Name string `xml:"name,attr"`
+  Tests int `xml:"tests,attr"`
+  Failures int `xml:"failures,attr"`
+  Errors int `xml:"errors,attr"`
+  Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+  Name string `xml:"name,attr"`
+  Tests int `xml:"tests,attr"`
+  Failures int `xml:"failures,attr"`
+  Errors int `xml:"errors,attr"`
+  Cases []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+  Name string `xml:"name,attr"`
+  Class string `xml:"classname,attr"`
+  Failure *junitMessage `xml:"failure"`
+  Error *junitMessage `xml:"error"`
+  Output string `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+  Message string `xml:"message,attr"`
+  Type string `xml:"type,attr,omitempty"`
+  Text string `xml:",chardata"`
+}
+
+// writeValidateJUnit writes a test suite per file and a test case per rule.
+// A rule fails if it has errors, or warnings with --strict; otherwise its
+// warnings are listed as output. An unreadable file is a single errored
+// test case.
+func writeValidateJUnit(out io.Writer, results []validateResult) error {
+  suites := junitSuites{Name: "columbus-v1000 validate"}
+  for _, r := range results {
+    suite := junitSuite{Name: r.File}
+    if r.Failure != "" {
+      suite.Cases = []junitCase{{Name: "read", Class: r.File, Error: &junitMessage{Message: r.Failure}}}
+      suite.Tests, suite.Errors = 1, 1
+    } else {
+      for _, rule := range v1000.Rules {
+        c := junitCase{Name: rule.ID, Class: r.File}
+        var lines []string
+        for _, issue := range r.Issues {
+          if issue.Rule == rule.ID {
+            lines = append(lines, fmt.Sprintf("offset %d: %s", issue.Offset, issue.Message))
+          }
+        }
+        if len(lines) > 0 {
+          text := strings.Join(lines, "\n")
+          if rule.Severity == v1000.Error || validateStrict {
+            message := fmt.Sprintf("%d issues", len(lines))
+            if len(lines) == 1 {
+              message = lines[0]
+            }
+            c.Failure = &junitMessage{Message: message, Type: rule.Severity.String(), Text: text}
+            suite.Failures++
+          } else {
+            c.Output = text
+          }
+        }
+        suite.Cases = append(suite.Cases, c)
+      }
+      suite.Tests = len(suite.Cases)
+    }
+    suites.Tests += suite.Tests
+    suites.Failures += suite.Failures
+    suites.Errors += suite.Errors
+    suites.Suites = append(suites.Suites, suite)
+  }
+  if _, err := io.WriteString(out, xml.Header); err != nil {
+    return err
+  }
+  enc := xml.NewEncoder(out)
+  enc.Indent("", "  ")
+  if err := enc.Encode(suites); err != nil {
+    return err
+  }
+  _, err := io.WriteString(out, "\n")
+  return err
+}
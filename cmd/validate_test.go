@@ -0,0 +1,130 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "encoding/json"
+  "encoding/xml"
+  "io/ioutil"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// writeBadBinary writes a binary file with a bad header, a break in the
+// indexes after the first record and a trailing partial record
+func writeBadBinary(t *testing.T, dir string) string {
+  record := []byte{
+    0x00, 0x00, 0x01, 0x0d, 0x05, 0x02, 0xc8, 0xb8, 0x02, 0x15, 0xd4, 0x96, 0x05, 0xe8,
+    0x8b, 0x40, 0x00, 0x00, 0x00, 0x64, 0x00, 0x0f, 0x00, 0xb4, 0x27, 0x10, 0x00, 0xc8,
+  }
+  data := append([]byte{0x12, 0x34}, record...)
+  record[2] = 5
+  data = append(append(data, record...), 1, 2, 3)
+  path := filepath.Join(dir, "bad.gps")
+  if err := ioutil.WriteFile(path, data, 0644); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  return path
+}
+
+func Test_validateFile(t *testing.T) {
+  t.Log("Checking whether validate counts issues and picks the exit status..")
+  defer func() { validateStrict = false }()
+  dir := t.TempDir()
+  bad := validateFile(writeBadBinary(t, dir))
+  if bad.Errors != 2 || bad.Warnings != 1 || bad.Failure != "" {
+    t.Errorf("Expected 2 errors and 1 warning, got %+v", bad)
+  }
+  missing := validateFile(filepath.Join(dir, "missing.gps"))
+  if missing.Failure == "" {
+    t.Errorf("Expected a failure for a missing file")
+  }
+
+  warned := validateResult{File: "warned.gps", Warnings: 1}
+  if status := validateStatus([]validateResult{warned}); status != validateOK {
+    t.Errorf("Expected warnings to pass, got %d", status)
+  }
+  if status := validateStatus([]validateResult{warned, bad}); status != validateFailed {
+    t.Errorf("Expected errors to fail, got %d", status)
+  }
+  if status := validateStatus([]validateResult{bad, missing}); status != validateUnreadable {
+    t.Errorf("Expected an unreadable file to give %d, got %d", validateUnreadable, status)
+  }
+  validateStrict = true
+  if status := validateStatus([]validateResult{warned}); status != validateFailed {
+    t.Errorf("Expected warnings to fail with --strict, got %d", status)
+  }
+}
+
+func Test_validateReports(t *testing.T) {
+  t.Log("Checking whether validate writes text, JSON and JUnit reports..")
+  path := writeBadBinary(t, t.TempDir())
+  results := []validateResult{validateFile(path)}
+
+  var text bytes.Buffer
+  writeValidateText(&text, results)
+  for _, expected := range []string{
+    path + ":0: error: header is 4660 (0x1234), expected 1799 (header-magic)\n",
+    path + ":30: warning: record 1: index 5 follows 1 (index-continuity)\n",
+    "1 files: 2 errors, 1 warnings\n",
+  } {
+    if !strings.Contains(text.String(), expected) {
+      t.Errorf("Expected %q in:\n%s", expected, text.String())
+    }
+  }
+
+  var out bytes.Buffer
+  writeValidateJSON(&out, results)
+  var report struct {
+    Files []struct {
+      Issues []struct {
+        Rule string
+        Severity string
+        Offset int64
+      }
+    }
+    Status int
+  }
+  if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if report.Status != validateFailed || len(report.Files[0].Issues) != 3 {
+    t.Fatalf("Expected status 1 and 3 issues, got %s", out.String())
+  }
+  if issue := report.Files[0].Issues[2]; issue.Rule != "record-alignment" || issue.Severity != "error" || issue.Offset != 58 {
+    t.Errorf("Expected record-alignment at 58, got %+v", issue)
+  }
+
+  out.Reset()
+  writeValidateJUnit(&out, results)
+  var suites junitSuites
+  if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if suites.Tests != 12 || suites.Failures != 2 || len(suites.Suites) != 1 {
+    t.Errorf("Expected 12 tests and 2 failures, got %d and %d", suites.Tests, suites.Failures)
+  }
+  for _, c := range suites.Suites[0].Cases {
+    if c.Name == "index-continuity" && (c.Failure != nil || c.Output == "") {
+      t.Errorf("Expected the index warning as output, got %+v", c)
+    }
+  }
+}
@@ -0,0 +1,158 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// vboCmd represents the vbo command
+var vboCmd = &cobra.Command{
+  Use:   "vbo",
+  Short: "Converts to Racelogic VBO format",
+  Long: `Converts a Columbus V1000 GPS file to a Racelogic .vbo file.
+
+With --start-finish, and optionally --sector, laps are timed from interpolated
+line crossings; the lines go in the file's [laptiming] section and the lap
+table in its comments.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newVBOWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(vboCmd)
+  vboCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  vboCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  vboCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  addLapFlags(vboCmd)
+  addFilterFlags(vboCmd)
+}
+
+// vboSatellites stands in for the satellite count, which the V1000 doesn't
+// log; analysis software treats fewer than four as no fix
+const vboSatellites = 8
+
+// vboWriter collects records and writes them as a VBO file
+type vboWriter struct {
+  *lapSession
+  out *bufio.Writer
+}
+
+func newVBOWriter(out io.Writer, meta export.Meta) (*vboWriter, error) {
+  session, err := newLapSession(meta.Location)
+  if err != nil {
+    return nil, err
+  }
+  return &vboWriter{lapSession: session, out: bufio.NewWriter(out)}, nil
+}
+
+func (w *vboWriter) flush() error {
+  if err := w.finish(); err != nil {
+    return err
+  }
+  created := w.recs[0].Time.In(w.loc)
+  w.line("File created on %s at %s", created.Format("02/01/2006"), created.Format("15:04:05"))
+  w.line("")
+  w.line("[header]")
+  for _, name := range []string{"satellites", "time", "latitude", "longitude", "velocity kmh", "heading", "height"} {
+    w.line("%s", name)
+  }
+  w.line("")
+  w.line("[comments]")
+  w.line("Converted by columbus-v1000")
+  if w.timed {
+    var table bytes.Buffer
+    writeLapTable(&table, w.laps, w.summary, "")
+    for _, line := range strings.Split(strings.TrimRight(table.String(), "\n"), "\n") {
+      w.line("%s", line)
+    }
+    w.line("")
+    w.line("[laptiming]")
+    w.line("Start   %s ¬ Start / Finish", formatVBOLine(w.timer.Finish))
+    for k, sector := range w.timer.Sectors {
+      w.line("Split   %s ¬ Split %d", formatVBOLine(sector), k + 1)
+    }
+  }
+  w.line("")
+  w.line("[column names]")
+  w.line("sats time lat long velocity heading height")
+  w.line("")
+  w.line("[data]")
+  for i := range w.recs {
+    rec := &w.recs[i]
+    w.line("%03d %s %s %s %07.3f %06.2f %+09.2f",
+      vboSatellites,
+      rec.Time.In(w.loc).UTC().Format("150405.00"),
+      formatVBOMinutes(rec.Latitude),
+      formatVBOMinutes(-rec.Longitude),
+      rec.Speed,
+      float64(rec.Heading % 360),
+      float64(rec.Altitude))
+  }
+  return w.out.Flush()
+}
+
+func (w *vboWriter) line(format string, args ...interface{}) {
+  fmt.Fprintf(w.out, format + "\r\n", args...)
+}
+
+// formatVBOMinutes formats a coordinate in minutes. VBO longitudes are
+// positive west, so callers negate them.
+func formatVBOMinutes(degrees float64) string {
+  return fmt.Sprintf("%+012.5f", degrees * 60)
+}
+
+// formatVBOLine formats a timing line as longitude and latitude minutes for
+// each end
+func formatVBOLine(line v1000.Line) string {
+  return strings.Join([]string{
+    formatVBOMinutes(-line.A[0]), formatVBOMinutes(line.A[1]),
+    formatVBOMinutes(-line.B[0]), formatVBOMinutes(line.B[1]),
+  }, " ")
+}
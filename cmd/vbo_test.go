@@ -0,0 +1,67 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "strings"
+  "testing"
+)
+
+func Test_formatVBOMinutes(t *testing.T) {
+  t.Log("Checking whether formatVBOMinutes() writes signed minutes..")
+  if out := formatVBOMinutes(51.5); out != "+03090.00000" {
+    t.Errorf("Expected +03090.00000, got %s", out)
+  }
+  if out := formatVBOMinutes(-0.1); out != "-00006.00000" {
+    t.Errorf("Expected -00006.00000, got %s", out)
+  }
+}
+
+func Test_vboWriter(t *testing.T) {
+  t.Log("Checking whether the VBO writer includes lap timing and data..")
+  defer setLapFlags()()
+  var buf bytes.Buffer
+  w, err := newVBOWriter(&buf, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  for _, rec := range lapRecords(190) {
+    w.writeRecord(&rec)
+  }
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  out := buf.String()
+  for _, expected := range []string{
+    "File created on 01/04/2017 at 12:00:00\r\n",
+    "\r\nBest lap: 2 (1:00.000)\r\n",
+    "\r\n[laptiming]\r\nStart   +00005.97000 +03090.00000 +00005.91000 +03090.00000 ¬ Start / Finish\r\n",
+    "\r\nSplit   +00006.00000 +03090.03000 +00006.00000 +03090.09000 ¬ Split 1\r\n",
+    "\r\n[column names]\r\nsats time lat long velocity heading height\r\n\r\n[data]\r\n",
+    "\r\n008 120000.00 +03090.00314 +00005.94008 036.000 090.00 +00100.00\r\n",
+  } {
+    if !strings.Contains(out, expected) {
+      t.Errorf("Expected %q in:\n%s", expected, out)
+    }
+  }
+  if n := strings.Count(out, "\r\n008 "); n != 190 {
+    t.Errorf("Expected 190 data rows, got %d", n)
+  }
+}
@@ -0,0 +1,107 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "os"
+  "time"
+
+  "github.com/spf13/cobra"
+  "github.com/asnodgrass/columbus-v1000/export"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// wptCmd represents the wpt command
+var wptCmd = &cobra.Command{
+  Use:   "wpt",
+  Short: "Converts to OziExplorer waypoint (WPT) format",
+  Long: `Converts the points of interest in a Columbus V1000 GPS file to an
+OziExplorer waypoint file, named POI1, POI2, ... by their record index.`,
+  Run: func(cmd *cobra.Command, args []string) {
+    if inFile == "" {
+      fmt.Println("error: input file required")
+      os.Exit(1)
+    }
+
+    filter, meta, err := buildExport(inFile)
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+
+    out, err := createOutput()
+    if err != nil {
+      fmt.Println(err)
+      os.Exit(1)
+    }
+    w, err := newWPTWriter(out, meta)
+    if err == nil {
+      err = writeRecords(filter, w)
+    }
+    closeOutput(out, err)
+  },
+}
+
+func init() {
+  RootCmd.AddCommand(wptCmd)
+  wptCmd.Flags().StringVarP(&inFile, "in-file", "i", "", "input file (required)")
+  wptCmd.Flags().StringVar(&inFormat, "in-format", "", "input format: binary, csv, gpx or nmea (default: detect)")
+  wptCmd.Flags().StringVarP(&outFile, "out-file", "o", "", "output file")
+  addFilterFlags(wptCmd)
+}
+
+// wptWriter writes points of interest as OziExplorer waypoints
+type wptWriter struct {
+  out *bufio.Writer
+  loc *time.Location
+  n int
+}
+
+func newWPTWriter(out io.Writer, meta export.Meta) (*wptWriter, error) {
+  w := &wptWriter{out: bufio.NewWriter(out), loc: meta.Location}
+  fmt.Fprint(w.out, "OziExplorer Waypoint File Version 1.1\r\n")
+  fmt.Fprint(w.out, "WGS 84\r\n")
+  fmt.Fprint(w.out, "Reserved 2\r\n")
+  fmt.Fprint(w.out, "garmin\r\n")
+  return w, nil
+}
+
+func (w *wptWriter) writeRecord(rec *v1000.Record) error {
+  if rec.Type != "P" {
+    return nil
+  }
+  w.n++
+  t := rec.Time.In(w.loc).UTC()
+  name := fmt.Sprintf("POI%d", rec.Index)
+  desc := oziText(t.Format("2006-01-02 15:04:05Z"))
+  // number, name, lat, lon, date, symbol, status, map display format,
+  // foreground, background, description, pointer direction, Garmin display
+  // format, proximity, altitude, font size, font style and symbol size
+  _, err := fmt.Fprintf(w.out, "%d,%s,%.6f,%.6f,%.7f,0,1,3,0,65535,%s,0,0,0,%.0f,6,0,17\r\n",
+    w.n, oziText(name), rec.Latitude, rec.Longitude, oziDate(t), desc,
+    float64(rec.Altitude) * feetPerMetre)
+  return err
+}
+
+func (w *wptWriter) flush() error {
+  return w.out.Flush()
+}
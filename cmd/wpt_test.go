@@ -0,0 +1,47 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package cmd
+
+import (
+  "bytes"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_wptWriter(t *testing.T) {
+  t.Log("Checking whether the WPT writer writes only points of interest..")
+  at := v1000.DateOf(time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC))
+  var buf bytes.Buffer
+  w, err := newWPTWriter(&buf, testMeta("trip.gps"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  w.writeRecord(&v1000.Record{Index: 1, Type: "T", Time: at})
+  w.writeRecord(&v1000.Record{Index: 2, Type: "P", Latitude: 51.5, Longitude: -0.1, Altitude: 100, Time: at})
+  if err := w.flush(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := "OziExplorer Waypoint File Version 1.1\r\nWGS 84\r\nReserved 2\r\ngarmin\r\n" +
+    "1,POI2,51.500000,-0.100000,42826.5000000,0,1,3,0,65535,2017-04-01 12:00:00Z,0,0,0,328,6,0,17\r\n"
+  if buf.String() != expected {
+    t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+  }
+}
@@ -0,0 +1,126 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package export defines the interface that output formats implement, and a
+// registry of formats by name, so that a single pass over the decoded records
+// can feed any number of them.
+package export
+
+import (
+  "fmt"
+  "io"
+  "sort"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/expr"
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// Meta describes the input being exported
+type Meta struct {
+  Name string // the track name, usually the input file name without extensions
+  Source string // the input file name
+  Location *time.Location // the time zone of record times
+  SegmentGap time.Duration // the gap that starts a new track segment; 0 never splits
+  Env *expr.Env // the state of --where and --column expressions, if any
+}
+
+// Exporter writes records in an output format. Begin is called once before
+// any records, and End once after them, when buffered output is written.
+// Points of interest come to WriteRecord with the rest, with type "P".
+type Exporter interface {
+  Begin(meta Meta) error
+  WriteRecord(rec *v1000.Record) error
+  End() error
+}
+
+// Format is a registered output format
+type Format struct {
+  Name string
+  Extension string // the usual file name extension, with its dot
+  Description string
+  New func(w io.Writer) Exporter
+}
+
+var formats = map[string]Format{}
+
+// Register makes a format available by name. It panics if the name is
+// already taken.
+func Register(f Format) {
+  if _, ok := formats[f.Name]; ok {
+    panic("export: format " + f.Name + " registered twice")
+  }
+  formats[f.Name] = f
+}
+
+// Lookup returns the format registered under name
+func Lookup(name string) (Format, error) {
+  f, ok := formats[name]
+  if !ok {
+    return Format{}, fmt.Errorf("unknown output format %q", name)
+  }
+  return f, nil
+}
+
+// Formats returns every registered format, sorted by name
+func Formats() []Format {
+  list := make([]Format, 0, len(formats))
+  for _, f := range formats {
+    list = append(list, f)
+  }
+  sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+  return list
+}
+
+// multi fans calls out to several exporters in turn
+type multi []Exporter
+
+// Multi returns an exporter that writes to each of exporters, stopping at the
+// first error
+func Multi(exporters ...Exporter) Exporter {
+  return multi(exporters)
+}
+
+func (m multi) Begin(meta Meta) error {
+  for _, e := range m {
+    if err := e.Begin(meta); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (m multi) WriteRecord(rec *v1000.Record) error {
+  for _, e := range m {
+    if err := e.WriteRecord(rec); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// End ends every exporter, even after an error, and returns the first error
+func (m multi) End() error {
+  var first error
+  for _, e := range m {
+    if err := e.End(); err != nil && first == nil {
+      first = err
+    }
+  }
+  return first
+}
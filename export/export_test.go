@@ -0,0 +1,107 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package export
+
+import (
+  "errors"
+  "io"
+  "testing"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// recorder notes the calls made to it
+type recorder struct {
+  calls []string
+  err error
+}
+
+func (r *recorder) Begin(meta Meta) error {
+  r.calls = append(r.calls, "begin " + meta.Name)
+  return r.err
+}
+
+func (r *recorder) WriteRecord(rec *v1000.Record) error {
+  r.calls = append(r.calls, "record " + rec.Type)
+  return r.err
+}
+
+func (r *recorder) End() error {
+  r.calls = append(r.calls, "end")
+  return r.err
+}
+
+func Test_Multi(t *testing.T) {
+  t.Log("Checking whether Multi fans out every call..")
+  a, b := &recorder{}, &recorder{}
+  e := Multi(a, b)
+  e.Begin(Meta{Name: "trip"})
+  e.WriteRecord(&v1000.Record{Type: "T"})
+  e.WriteRecord(&v1000.Record{Type: "P"})
+  e.End()
+  expected := []string{"begin trip", "record T", "record P", "end"}
+  for _, r := range []*recorder{a, b} {
+    if len(r.calls) != len(expected) {
+      t.Fatalf("Expected %v, got %v", expected, r.calls)
+    }
+    for i := range expected {
+      if r.calls[i] != expected[i] {
+        t.Errorf("Expected %v, got %v", expected, r.calls)
+        break
+      }
+    }
+  }
+
+  t.Log("Checking whether Multi stops at the first error but ends every exporter..")
+  failing := &recorder{err: errors.New("full")}
+  c := &recorder{}
+  e = Multi(failing, c)
+  if err := e.WriteRecord(&v1000.Record{}); err == nil || len(c.calls) != 0 {
+    t.Errorf("Expected the error and no further writes, got %v and %v", err, c.calls)
+  }
+  if err := e.End(); err == nil || len(c.calls) != 1 {
+    t.Errorf("Expected the error and every exporter ended, got %v and %v", err, c.calls)
+  }
+}
+
+func Test_Register(t *testing.T) {
+  t.Log("Checking whether registered formats can be looked up and listed..")
+  Register(Format{Name: "test", Extension: ".test", New: func(w io.Writer) Exporter { return &recorder{} }})
+  defer delete(formats, "test")
+  f, err := Lookup("test")
+  if err != nil || f.Extension != ".test" {
+    t.Errorf("Expected the test format, got %v, %v", f, err)
+  }
+  if _, err := Lookup("nope"); err == nil {
+    t.Errorf("Expected an error for an unknown format")
+  }
+  list := Formats()
+  for i := 1; i < len(list); i++ {
+    if list[i - 1].Name >= list[i].Name {
+      t.Errorf("Expected formats sorted by name, got %s before %s", list[i - 1].Name, list[i].Name)
+    }
+  }
+
+  defer func() {
+    if recover() == nil {
+      t.Errorf("Expected a panic registering a name twice")
+    }
+  }()
+  Register(Format{Name: "test"})
+}
@@ -0,0 +1,172 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package export
+
+import (
+  "encoding/xml"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func init() {
+  Register(Format{Name: "kml", Extension: ".kml", Description: "KML for Google Earth", New: NewKML})
+}
+
+// KML writes a KML 2.2 document with a line per track segment and a point per
+// waypoint
+type KML struct {
+  w io.Writer
+  meta Meta
+  track []v1000.Record
+  waypoints []v1000.Record
+}
+
+// NewKML returns a KML exporter writing to w
+func NewKML(w io.Writer) Exporter {
+  return &KML{w: w}
+}
+
+type kmlDocument struct {
+  XMLName xml.Name `xml:"kml"`
+  Namespace string `xml:"xmlns,attr"`
+  Name string `xml:"Document>name"`
+  Style kmlStyle `xml:"Document>Style"`
+  Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+type kmlStyle struct {
+  ID string `xml:"id,attr"`
+  Color string `xml:"LineStyle>color"`
+  Width int `xml:"LineStyle>width"`
+}
+
+type kmlFolder struct {
+  Name string `xml:"name"`
+  Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+  Name string `xml:"name"`
+  Description string `xml:"description,omitempty"`
+  TimeSpan *kmlTimeSpan `xml:"TimeSpan"`
+  TimeStamp *kmlTimeStamp `xml:"TimeStamp"`
+  StyleURL string `xml:"styleUrl,omitempty"`
+  LineString *kmlGeometry `xml:"LineString"`
+  Point *kmlGeometry `xml:"Point"`
+}
+
+type kmlTimeSpan struct {
+  Begin string `xml:"begin"`
+  End string `xml:"end"`
+}
+
+type kmlTimeStamp struct {
+  When string `xml:"when"`
+}
+
+type kmlGeometry struct {
+  Tessellate int `xml:"tessellate,omitempty"`
+  AltitudeMode string `xml:"altitudeMode"`
+  Coordinates string `xml:"coordinates"`
+}
+
+// Begin notes the track name, time zone and segment gap
+func (k *KML) Begin(meta Meta) error {
+  k.meta = meta
+  if k.meta.Location == nil {
+    k.meta.Location = time.UTC
+  }
+  return nil
+}
+
+// WriteRecord buffers a trackpoint, or a point of interest, until End
+func (k *KML) WriteRecord(rec *v1000.Record) error {
+  if rec.Type == "P" {
+    k.waypoints = append(k.waypoints, *rec)
+  } else {
+    k.track = append(k.track, *rec)
+  }
+  return nil
+}
+
+// End writes the document
+func (k *KML) End() error {
+  doc := kmlDocument{
+    Namespace: "http://www.opengis.net/kml/2.2",
+    Name: k.meta.Name,
+    Style: kmlStyle{ID: "track", Color: "ff0000ff", Width: 3},
+  }
+
+  var tracks kmlFolder
+  tracks.Name = "Tracks"
+  for i, segment := range v1000.SplitSegments(k.track, k.meta.SegmentGap, k.meta.Location) {
+    s := v1000.Summarize(segment, k.meta.Location)
+    p := kmlPlacemark{
+      Name: fmt.Sprintf("Segment %d", i + 1),
+      Description: fmt.Sprintf("%d points, %.2f km, %s", s.Count, s.Distance / 1000, s.Duration()),
+      TimeSpan: &kmlTimeSpan{s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339)},
+      StyleURL: "#track",
+    }
+    // a line needs two points
+    if len(segment) > 1 {
+      p.LineString = &kmlGeometry{Tessellate: 1, AltitudeMode: "absolute", Coordinates: kmlCoordinates(segment...)}
+    } else {
+      p.Point = &kmlGeometry{AltitudeMode: "absolute", Coordinates: kmlCoordinates(segment...)}
+    }
+    tracks.Placemarks = append(tracks.Placemarks, p)
+  }
+  if len(tracks.Placemarks) > 0 {
+    doc.Folders = append(doc.Folders, tracks)
+  }
+
+  var pois kmlFolder
+  pois.Name = "Points of interest"
+  for _, rec := range k.waypoints {
+    pois.Placemarks = append(pois.Placemarks, kmlPlacemark{
+      Name: fmt.Sprintf("POI%d", rec.Index),
+      TimeStamp: &kmlTimeStamp{rec.Time.In(k.meta.Location).Format(time.RFC3339)},
+      Point: &kmlGeometry{AltitudeMode: "absolute", Coordinates: kmlCoordinates(rec)},
+    })
+  }
+  if len(pois.Placemarks) > 0 {
+    doc.Folders = append(doc.Folders, pois)
+  }
+
+  body, err := xml.MarshalIndent(doc, "", "  ")
+  if err != nil {
+    return err
+  }
+  _, err = fmt.Fprintf(k.w, "%s%s\n", xml.Header, body)
+  return err
+}
+
+// kmlCoordinates formats records as KML longitude,latitude,altitude tuples
+func kmlCoordinates(recs ...v1000.Record) string {
+  tuples := make([]string, len(recs))
+  for i, rec := range recs {
+    tuples[i] = strconv.FormatFloat(rec.Longitude, 'f', -1, 64) + "," +
+      strconv.FormatFloat(rec.Latitude, 'f', -1, 64) + "," + strconv.FormatUint(uint64(rec.Altitude), 10)
+  }
+  return strings.Join(tuples, " ")
+}
@@ -0,0 +1,76 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package export
+
+import (
+  "bytes"
+  "encoding/xml"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func Test_KML(t *testing.T) {
+  t.Log("Checking whether KML writes a line per segment and a point per waypoint..")
+  var buf bytes.Buffer
+  e := NewKML(&buf)
+  e.Begin(Meta{Name: "trip", Location: time.UTC, SegmentGap: time.Minute})
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  for i, offset := range []time.Duration{0, time.Second, 10 * time.Minute} {
+    e.WriteRecord(&v1000.Record{Index: uint32(i + 1), Type: "T", Latitude: 51.5 + float64(i) * 0.001,
+      Longitude: -0.1, Altitude: 100, Time: v1000.DateOf(start.Add(offset))})
+  }
+  e.WriteRecord(&v1000.Record{Index: 4, Type: "P", Latitude: 51.6, Longitude: -0.2, Altitude: 5,
+    Time: v1000.DateOf(start.Add(time.Hour))})
+  if err := e.End(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  var doc struct {
+    Name string `xml:"Document>name"`
+    Folders []struct {
+      Name string `xml:"name"`
+      Placemarks []struct {
+        Name string `xml:"name"`
+        Begin string `xml:"TimeSpan>begin"`
+        When string `xml:"TimeStamp>when"`
+        Line string `xml:"LineString>coordinates"`
+        Point string `xml:"Point>coordinates"`
+      } `xml:"Placemark"`
+    } `xml:"Document>Folder"`
+  }
+  if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if doc.Name != "trip" || len(doc.Folders) != 2 {
+    t.Fatalf("Expected document trip with two folders, got %+v", doc)
+  }
+  tracks := doc.Folders[0].Placemarks
+  if len(tracks) != 2 || tracks[0].Line != "-0.1,51.5,100 -0.1,51.501,100" || tracks[0].Begin != "2017-04-01T12:00:00Z" {
+    t.Errorf("Expected a two point line first, got %+v", tracks)
+  }
+  if len(tracks) == 2 && tracks[1].Point != "-0.1,51.502,100" {
+    t.Errorf("Expected a lone point as a Point, got %+v", tracks[1])
+  }
+  pois := doc.Folders[1].Placemarks
+  if len(pois) != 1 || pois[0].Name != "POI4" || pois[0].When != "2017-04-01T13:00:00Z" || pois[0].Point != "-0.2,51.6,5" {
+    t.Errorf("Expected POI4, got %+v", pois)
+  }
+}
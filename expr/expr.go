@@ -0,0 +1,449 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package expr is a small, side-effect free expression language evaluated
+// against V1000 records. Expressions are type checked when compiled, so a
+// compiled Program cannot fail at run time.
+package expr
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+// Kind is the static type of an expression
+type Kind int
+
+// Expression types
+const (
+  Number Kind = iota
+  String
+  Bool
+)
+
+func (k Kind) String() string {
+  switch k {
+  case Number:
+    return "number"
+  case String:
+    return "string"
+  }
+  return "bool"
+}
+
+// Value is the result of evaluating an expression
+type Value struct {
+  Kind Kind
+  Num float64
+  Str string
+  Bool bool
+}
+
+// String formats the value for output
+func (v Value) String() string {
+  switch v.Kind {
+  case Number:
+    return strconv.FormatFloat(v.Num, 'f', -1, 64)
+  case String:
+    return v.Str
+  }
+  return strconv.FormatBool(v.Bool)
+}
+
+// Env holds the record being evaluated together with the state needed for
+// derived fields such as the distance from the previous record
+type Env struct {
+  Location *time.Location
+  cur v1000.Record
+  prev v1000.Record
+  first v1000.Record
+  count int
+  odometer float64
+}
+
+// NewEnv returns an environment interpreting record times in loc
+func NewEnv(loc *time.Location) *Env {
+  if loc == nil {
+    loc = time.UTC
+  }
+  return &Env{Location: loc}
+}
+
+// Next makes rec the current record. It must be called for every record in
+// file order so that derived fields refer to the right predecessor.
+func (env *Env) Next(rec *v1000.Record) {
+  env.prev = env.cur
+  env.cur = *rec
+  if env.count == 0 {
+    env.first = *rec
+  } else {
+    env.odometer += v1000.Distance(&env.prev, &env.cur)
+  }
+  env.count++
+}
+
+// distance is metres travelled since the previous record
+func (env *Env) distance() float64 {
+  if env.count < 2 {
+    return 0
+  }
+  return v1000.Distance(&env.prev, &env.cur)
+}
+
+// interval is seconds elapsed since the previous record
+func (env *Env) interval() float64 {
+  if env.count < 2 {
+    return 0
+  }
+  return env.cur.Time.In(env.Location).Sub(env.prev.Time.In(env.Location)).Seconds()
+}
+
+// climb is metres of altitude gained since the previous record
+func (env *Env) climb() float64 {
+  if env.count < 2 {
+    return 0
+  }
+  return float64(env.cur.Altitude) - float64(env.prev.Altitude)
+}
+
+// Program is a compiled expression
+type Program struct {
+  Source string
+  root node
+}
+
+// Compile parses and type checks an expression
+func Compile(src string) (*Program, error) {
+  root, err := parse(src)
+  if err != nil {
+    return nil, err
+  }
+  return &Program{Source: src, root: root}, nil
+}
+
+// CompileBool compiles an expression that must produce true or false, as
+// used for filtering
+func CompileBool(src string) (*Program, error) {
+  p, err := Compile(src)
+  if err != nil {
+    return nil, err
+  }
+  if p.Kind() != Bool {
+    return nil, &SyntaxError{src, 0, fmt.Sprintf("expression is a %s, expected a condition", p.Kind())}
+  }
+  return p, nil
+}
+
+// Kind is the type the program evaluates to
+func (p *Program) Kind() Kind {
+  return p.root.kind()
+}
+
+// Eval evaluates the program against the current record of env
+func (p *Program) Eval(env *Env) Value {
+  return p.root.eval(env)
+}
+
+// Filter adapts a boolean program to v1000.Filter. The environment must
+// already have been advanced to the record being matched.
+func Filter(p *Program, env *Env) v1000.Filter {
+  return v1000.FilterFunc(func(rec *v1000.Record) bool {
+    return p.root.eval(env).Bool
+  })
+}
+
+type node interface {
+  kind() Kind
+  eval(env *Env) Value
+}
+
+type numberLit float64
+
+func (n numberLit) kind() Kind { return Number }
+func (n numberLit) eval(env *Env) Value { return Value{Kind: Number, Num: float64(n)} }
+
+type stringLit string
+
+func (n stringLit) kind() Kind { return String }
+func (n stringLit) eval(env *Env) Value { return Value{Kind: String, Str: string(n)} }
+
+type boolLit bool
+
+func (n boolLit) kind() Kind { return Bool }
+func (n boolLit) eval(env *Env) Value { return Value{Kind: Bool, Bool: bool(n)} }
+
+// field reads a value from the environment
+type field struct {
+  typ Kind
+  get func(env *Env) Value
+}
+
+func (f *field) kind() Kind { return f.typ }
+func (f *field) eval(env *Env) Value { return f.get(env) }
+
+func numberField(get func(env *Env) float64) *field {
+  return &field{Number, func(env *Env) Value {
+    return Value{Kind: Number, Num: get(env)}
+  }}
+}
+
+// fields are the names available to expressions
+var fields = map[string]*field{
+  "index": numberField(func(env *Env) float64 { return float64(env.cur.Index) }),
+  "type": &field{String, func(env *Env) Value { return Value{Kind: String, Str: env.cur.Type} }},
+  "lat": numberField(func(env *Env) float64 { return env.cur.Latitude }),
+  "lon": numberField(func(env *Env) float64 { return env.cur.Longitude }),
+  "alt": numberField(func(env *Env) float64 { return float64(env.cur.Altitude) }),
+  "speed": numberField(func(env *Env) float64 { return env.cur.Speed }),
+  "heading": numberField(func(env *Env) float64 { return float64(env.cur.Heading) }),
+  "pressure": numberField(func(env *Env) float64 { return env.cur.Pressure }),
+  "temp": numberField(func(env *Env) float64 { return float64(env.cur.Temperature) }),
+  "time": numberField(func(env *Env) float64 { return float64(env.cur.Time.In(env.Location).Unix()) }),
+  "year": numberField(func(env *Env) float64 { return float64(env.cur.Time.Year) }),
+  "month": numberField(func(env *Env) float64 { return float64(env.cur.Time.Month) }),
+  "day": numberField(func(env *Env) float64 { return float64(env.cur.Time.Day) }),
+  "hour": numberField(func(env *Env) float64 { return float64(env.cur.Time.Hour) }),
+  "minute": numberField(func(env *Env) float64 { return float64(env.cur.Time.Minute) }),
+  "second": numberField(func(env *Env) float64 { return float64(env.cur.Time.Second) }),
+  "dist": numberField(func(env *Env) float64 { return env.distance() }),
+  "odometer": numberField(func(env *Env) float64 { return env.odometer }),
+  "dt": numberField(func(env *Env) float64 { return env.interval() }),
+  "elapsed": numberField(func(env *Env) float64 {
+    return env.cur.Time.In(env.Location).Sub(env.first.Time.In(env.Location)).Seconds()
+  }),
+  "climb": numberField(func(env *Env) float64 { return env.climb() }),
+  "vspeed": numberField(func(env *Env) float64 {
+    if dt := env.interval(); dt > 0 {
+      return env.climb() / dt
+    }
+    return 0
+  }),
+}
+
+func init() {
+  aliases := map[string]string{
+    "latitude": "lat",
+    "longitude": "lon",
+    "altitude": "alt",
+    "height": "alt",
+    "pres": "pressure",
+    "temperature": "temp",
+  }
+  for alias, name := range aliases {
+    fields[alias] = fields[name]
+  }
+}
+
+// Fields lists the names that may be used in expressions
+func Fields() []string {
+  names := make([]string, 0, len(fields))
+  for name := range fields {
+    names = append(names, name)
+  }
+  return names
+}
+
+type unaryNode struct {
+  op string
+  x node
+}
+
+func newUnary(op string, x node) (node, error) {
+  want := Number
+  if op == "!" {
+    want = Bool
+  }
+  if x.kind() != want {
+    return nil, fmt.Errorf("operator %s needs a %s, not a %s", op, want, x.kind())
+  }
+  return &unaryNode{op, x}, nil
+}
+
+func (n *unaryNode) kind() Kind { return n.x.kind() }
+
+func (n *unaryNode) eval(env *Env) Value {
+  v := n.x.eval(env)
+  if n.op == "!" {
+    return Value{Kind: Bool, Bool: !v.Bool}
+  }
+  return Value{Kind: Number, Num: -v.Num}
+}
+
+type binaryNode struct {
+  op string
+  typ Kind
+  l node
+  r node
+}
+
+func newBinary(tok token, l node, r node) (node, error) {
+  lk, rk := l.kind(), r.kind()
+  n := &binaryNode{op: tok.text, l: l, r: r}
+  switch tok.text {
+  case "&&", "||":
+    if lk != Bool || rk != Bool {
+      return nil, fmt.Errorf("operator %s needs two bools, not %s and %s", tok.text, lk, rk)
+    }
+    n.typ = Bool
+  case "==", "!=":
+    if lk != rk {
+      return nil, fmt.Errorf("cannot compare %s with %s", lk, rk)
+    }
+    n.typ = Bool
+  case "<", "<=", ">", ">=":
+    if lk != rk || lk == Bool {
+      return nil, fmt.Errorf("cannot order %s and %s", lk, rk)
+    }
+    n.typ = Bool
+  case "+":
+    if lk != rk || lk == Bool {
+      return nil, fmt.Errorf("cannot add %s and %s", lk, rk)
+    }
+    n.typ = lk
+  default:
+    if lk != Number || rk != Number {
+      return nil, fmt.Errorf("operator %s needs two numbers, not %s and %s", tok.text, lk, rk)
+    }
+    n.typ = Number
+  }
+  return n, nil
+}
+
+func (n *binaryNode) kind() Kind { return n.typ }
+
+func (n *binaryNode) eval(env *Env) Value {
+  // short circuit the logical operators
+  switch n.op {
+  case "&&":
+    return Value{Kind: Bool, Bool: n.l.eval(env).Bool && n.r.eval(env).Bool}
+  case "||":
+    return Value{Kind: Bool, Bool: n.l.eval(env).Bool || n.r.eval(env).Bool}
+  }
+  l, r := n.l.eval(env), n.r.eval(env)
+  switch n.op {
+  case "==":
+    return Value{Kind: Bool, Bool: l == r}
+  case "!=":
+    return Value{Kind: Bool, Bool: l != r}
+  case "<":
+    return Value{Kind: Bool, Bool: less(l, r)}
+  case "<=":
+    return Value{Kind: Bool, Bool: !less(r, l)}
+  case ">":
+    return Value{Kind: Bool, Bool: less(r, l)}
+  case ">=":
+    return Value{Kind: Bool, Bool: !less(l, r)}
+  case "+":
+    if l.Kind == String {
+      return Value{Kind: String, Str: l.Str + r.Str}
+    }
+    return Value{Kind: Number, Num: l.Num + r.Num}
+  case "-":
+    return Value{Kind: Number, Num: l.Num - r.Num}
+  case "*":
+    return Value{Kind: Number, Num: l.Num * r.Num}
+  case "/":
+    return Value{Kind: Number, Num: l.Num / r.Num}
+  }
+  return Value{Kind: Number, Num: math.Mod(l.Num, r.Num)}
+}
+
+func less(l Value, r Value) bool {
+  if l.Kind == String {
+    return l.Str < r.Str
+  }
+  return l.Num < r.Num
+}
+
+// function is a builtin callable from expressions
+type function struct {
+  min int
+  max int
+  typ Kind
+  call func(args []float64) float64
+}
+
+func (fn *function) check(args []node) error {
+  if len(args) < fn.min || (fn.max >= 0 && len(args) > fn.max) {
+    switch {
+    case fn.max < 0:
+      return fmt.Errorf("expected at least %d arguments, got %d", fn.min, len(args))
+    case fn.min == fn.max:
+      return fmt.Errorf("expected %d arguments, got %d", fn.min, len(args))
+    }
+    return fmt.Errorf("expected %d to %d arguments, got %d", fn.min, fn.max, len(args))
+  }
+  for i, arg := range args {
+    if arg.kind() != Number {
+      return fmt.Errorf("argument %d is a %s, expected a number", i + 1, arg.kind())
+    }
+  }
+  return nil
+}
+
+func unaryFunction(f func(float64) float64) *function {
+  return &function{1, 1, Number, func(args []float64) float64 { return f(args[0]) }}
+}
+
+// functions are the builtins available to expressions
+var functions = map[string]*function{
+  "abs": unaryFunction(math.Abs),
+  "sqrt": unaryFunction(math.Sqrt),
+  "floor": unaryFunction(math.Floor),
+  "ceil": unaryFunction(math.Ceil),
+  "round": &function{1, 2, Number, func(args []float64) float64 {
+    scale := 1.0
+    if len(args) == 2 {
+      scale = math.Pow(10, args[1])
+    }
+    return math.Round(args[0] * scale) / scale
+  }},
+  "min": &function{1, -1, Number, func(args []float64) float64 {
+    m := args[0]
+    for _, a := range args[1:] {
+      m = math.Min(m, a)
+    }
+    return m
+  }},
+  "max": &function{1, -1, Number, func(args []float64) float64 {
+    m := args[0]
+    for _, a := range args[1:] {
+      m = math.Max(m, a)
+    }
+    return m
+  }},
+}
+
+type callNode struct {
+  fn *function
+  args []node
+}
+
+func (n *callNode) kind() Kind { return n.fn.typ }
+
+func (n *callNode) eval(env *Env) Value {
+  args := make([]float64, len(n.args))
+  for i, arg := range n.args {
+    args[i] = arg.eval(env).Num
+  }
+  return Value{Kind: Number, Num: n.fn.call(args)}
+}
@@ -0,0 +1,118 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package expr
+
+import (
+  "math"
+  "testing"
+  "time"
+
+  "github.com/asnodgrass/columbus-v1000/v1000"
+)
+
+func testRecords() []v1000.Record {
+  return []v1000.Record{
+    {Index: 1, Type: "T", Time: v1000.Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 0, Second: 0},
+      Latitude: 0, Longitude: 0, Altitude: 100, Speed: 50, Temperature: 5},
+    {Index: 2, Type: "T", Time: v1000.Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 0, Second: 10},
+      Latitude: 0.001, Longitude: 0, Altitude: 120, Speed: 90, Temperature: 2},
+  }
+}
+
+func Test_Eval(t *testing.T) {
+  t.Log("Checking whether Eval() computes fields, operators and functions..")
+  recs := testRecords()
+  env := NewEnv(time.UTC)
+  env.Next(&recs[0])
+  env.Next(&recs[1])
+  cases := map[string]string{
+    "speed > 80 && temp < 3": "true",
+    "speed > 80 and not (temp < 3)": "false",
+    "type == \"T\"": "true",
+    "1 + 2 * 3 - 4 / 2": "5",
+    "-alt % 7": "-1",
+    "max(speed, alt, 3)": "120",
+    "round(speed / 7, 2)": "12.86",
+    "climb": "20",
+    "dt": "10",
+    "vspeed": "2",
+    "elapsed": "10",
+    "round(dist)": "111",
+    "'id-' + 'x'": "id-x",
+  }
+  for src, expected := range cases {
+    p, err := Compile(src)
+    if err != nil {
+      t.Errorf("Unexpected error compiling %q: %v", src, err)
+      continue
+    }
+    if out := p.Eval(env).String(); out != expected {
+      t.Errorf("Expected %q to give %s, got %s", src, expected, out)
+    }
+  }
+}
+
+func Test_derivedFirstRecord(t *testing.T) {
+  t.Log("Checking whether derived fields are zero for the first record..")
+  recs := testRecords()
+  env := NewEnv(nil)
+  env.Next(&recs[0])
+  for _, src := range []string{"dist", "dt", "vspeed", "climb", "odometer", "elapsed"} {
+    p, _ := Compile(src)
+    if out := p.Eval(env).Num; out != 0 {
+      t.Errorf("Expected %s to be 0, got %f", src, out)
+    }
+  }
+}
+
+func Test_CompileBool(t *testing.T) {
+  t.Log("Checking whether CompileBool() rejects non-boolean expressions..")
+  if _, err := CompileBool("speed * 2"); err == nil {
+    t.Errorf("Expected an error for a numeric expression")
+  }
+  if _, err := CompileBool("speed > 2"); err != nil {
+    t.Errorf("Unexpected error: %v", err)
+  }
+}
+
+func Test_Filter(t *testing.T) {
+  t.Log("Checking whether Filter() matches against the current record..")
+  recs := testRecords()
+  env := NewEnv(time.UTC)
+  p, _ := CompileBool("speed > 80")
+  f := Filter(p, env)
+  var matched []uint32
+  for i := range recs {
+    env.Next(&recs[i])
+    if f.Match(&recs[i]) {
+      matched = append(matched, recs[i].Index)
+    }
+  }
+  if len(matched) != 1 || matched[0] != 2 {
+    t.Errorf("Expected only record 2 to match, got %v", matched)
+  }
+}
+
+func Test_divisionByZero(t *testing.T) {
+  t.Log("Checking whether division by zero does not panic..")
+  p, _ := Compile("1 / 0")
+  if out := p.Eval(NewEnv(nil)).Num; !math.IsInf(out, 1) {
+    t.Errorf("Expected +Inf, got %f", out)
+  }
+}
@@ -0,0 +1,335 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package expr
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+type tokenKind int
+
+const (
+  tokEOF tokenKind = iota
+  tokNumber
+  tokString
+  tokIdent
+  tokOp
+  tokLParen
+  tokRParen
+  tokComma
+)
+
+type token struct {
+  kind tokenKind
+  text string
+  pos int
+}
+
+// SyntaxError reports a problem in an expression along with where it occurred
+type SyntaxError struct {
+  Source string
+  Pos int
+  Msg string
+}
+
+// Error renders the message followed by the expression with a caret under
+// the offending column
+func (e *SyntaxError) Error() string {
+  return fmt.Sprintf("%s at column %d\n  %s\n  %s^", e.Msg, e.Pos + 1,
+    e.Source, strings.Repeat(" ", e.Pos))
+}
+
+// operators lists every operator, longest first so that lexing is greedy
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!"}
+
+func lex(src string) ([]token, error) {
+  var tokens []token
+  i := 0
+  for i < len(src) {
+    c := src[i]
+    switch {
+    case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+      i++
+    case c == '(':
+      tokens = append(tokens, token{tokLParen, "(", i})
+      i++
+    case c == ')':
+      tokens = append(tokens, token{tokRParen, ")", i})
+      i++
+    case c == ',':
+      tokens = append(tokens, token{tokComma, ",", i})
+      i++
+    case isDigit(c) || (c == '.' && i + 1 < len(src) && isDigit(src[i + 1])):
+      start := i
+      for i < len(src) && (isDigit(src[i]) || src[i] == '.') {
+        i++
+      }
+      if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+        i++
+        if i < len(src) && (src[i] == '+' || src[i] == '-') {
+          i++
+        }
+        for i < len(src) && isDigit(src[i]) {
+          i++
+        }
+      }
+      tokens = append(tokens, token{tokNumber, src[start:i], start})
+    case isLetter(c):
+      start := i
+      for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+        i++
+      }
+      tokens = append(tokens, token{tokIdent, src[start:i], start})
+    case c == '"' || c == '\'':
+      start := i
+      i++
+      var sb strings.Builder
+      for i < len(src) && src[i] != c {
+        if src[i] == '\\' && i + 1 < len(src) {
+          i++
+        }
+        sb.WriteByte(src[i])
+        i++
+      }
+      if i >= len(src) {
+        return nil, &SyntaxError{src, start, "unterminated string"}
+      }
+      i++
+      tokens = append(tokens, token{tokString, sb.String(), start})
+    default:
+      op := ""
+      for _, candidate := range operators {
+        if strings.HasPrefix(src[i:], candidate) {
+          op = candidate
+          break
+        }
+      }
+      if op == "" {
+        msg := fmt.Sprintf("unexpected character %q", c)
+        switch c {
+        case '=':
+          msg = "unexpected \"=\" (use == to compare)"
+        case '&':
+          msg = "unexpected \"&\" (use && for logical and)"
+        case '|':
+          msg = "unexpected \"|\" (use || for logical or)"
+        }
+        return nil, &SyntaxError{src, i, msg}
+      }
+      tokens = append(tokens, token{tokOp, op, i})
+      i += len(op)
+    }
+  }
+  tokens = append(tokens, token{tokEOF, "", len(src)})
+  return tokens, nil
+}
+
+func isDigit(c byte) bool {
+  return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+  return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// precedence of each binary operator; higher binds tighter
+var precedence = map[string]int{
+  "||": 1,
+  "&&": 2,
+  "==": 3, "!=": 3,
+  "<": 4, "<=": 4, ">": 4, ">=": 4,
+  "+": 5, "-": 5,
+  "*": 6, "/": 6, "%": 6,
+}
+
+// keywords are spelled-out aliases for the logical operators
+var keywords = map[string]string{
+  "and": "&&",
+  "or": "||",
+  "not": "!",
+}
+
+type parser struct {
+  src string
+  tokens []token
+  pos int
+}
+
+func parse(src string) (node, error) {
+  tokens, err := lex(src)
+  if err != nil {
+    return nil, err
+  }
+  for i := range tokens {
+    if op, ok := keywords[tokens[i].text]; ok && tokens[i].kind == tokIdent {
+      tokens[i].kind = tokOp
+      tokens[i].text = op
+    }
+  }
+  p := &parser{src: src, tokens: tokens}
+  if p.peek().kind == tokEOF {
+    return nil, p.errorf(p.peek(), "empty expression")
+  }
+  n, err := p.binary(1)
+  if err != nil {
+    return nil, err
+  }
+  if tok := p.peek(); tok.kind != tokEOF {
+    return nil, p.errorf(tok, "unexpected %s after expression", describe(tok))
+  }
+  return n, nil
+}
+
+func (p *parser) peek() token {
+  return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+  tok := p.tokens[p.pos]
+  if tok.kind != tokEOF {
+    p.pos++
+  }
+  return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+  return &SyntaxError{p.src, tok.pos, fmt.Sprintf(format, args...)}
+}
+
+// binary parses operators of at least the given precedence, climbing for
+// tighter ones
+func (p *parser) binary(minPrec int) (node, error) {
+  left, err := p.unary()
+  if err != nil {
+    return nil, err
+  }
+  for {
+    tok := p.peek()
+    prec, ok := precedence[tok.text]
+    if tok.kind != tokOp || !ok || prec < minPrec {
+      return left, nil
+    }
+    p.next()
+    right, err := p.binary(prec + 1)
+    if err != nil {
+      return nil, err
+    }
+    left, err = newBinary(tok, left, right)
+    if err != nil {
+      return nil, p.errorf(tok, "%v", err)
+    }
+  }
+}
+
+func (p *parser) unary() (node, error) {
+  tok := p.peek()
+  if tok.kind == tokOp && (tok.text == "!" || tok.text == "-") {
+    p.next()
+    operand, err := p.unary()
+    if err != nil {
+      return nil, err
+    }
+    n, err := newUnary(tok.text, operand)
+    if err != nil {
+      return nil, p.errorf(tok, "%v", err)
+    }
+    return n, nil
+  }
+  return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+  tok := p.next()
+  switch tok.kind {
+  case tokNumber:
+    f, err := strconv.ParseFloat(tok.text, 64)
+    if err != nil {
+      return nil, p.errorf(tok, "malformed number %q", tok.text)
+    }
+    return numberLit(f), nil
+  case tokString:
+    return stringLit(tok.text), nil
+  case tokLParen:
+    n, err := p.binary(1)
+    if err != nil {
+      return nil, err
+    }
+    if closing := p.next(); closing.kind != tokRParen {
+      return nil, p.errorf(closing, "expected \")\", found %s", describe(closing))
+    }
+    return n, nil
+  case tokIdent:
+    if p.peek().kind == tokLParen {
+      return p.call(tok)
+    }
+    switch tok.text {
+    case "true":
+      return boolLit(true), nil
+    case "false":
+      return boolLit(false), nil
+    }
+    f, ok := fields[tok.text]
+    if !ok {
+      return nil, p.errorf(tok, "unknown field %q", tok.text)
+    }
+    return f, nil
+  }
+  return nil, p.errorf(tok, "expected a value, found %s", describe(tok))
+}
+
+func (p *parser) call(name token) (node, error) {
+  fn, ok := functions[name.text]
+  if !ok {
+    return nil, p.errorf(name, "unknown function %q", name.text)
+  }
+  p.next()
+  var args []node
+  if p.peek().kind != tokRParen {
+    for {
+      arg, err := p.binary(1)
+      if err != nil {
+        return nil, err
+      }
+      args = append(args, arg)
+      if p.peek().kind != tokComma {
+        break
+      }
+      p.next()
+    }
+  }
+  if closing := p.next(); closing.kind != tokRParen {
+    return nil, p.errorf(closing, "expected \")\" or \",\", found %s", describe(closing))
+  }
+  if err := fn.check(args); err != nil {
+    return nil, p.errorf(name, "%s: %v", name.text, err)
+  }
+  return &callNode{fn: fn, args: args}, nil
+}
+
+func describe(tok token) string {
+  switch tok.kind {
+  case tokEOF:
+    return "end of expression"
+  case tokString:
+    return fmt.Sprintf("string %q", tok.text)
+  }
+  return fmt.Sprintf("%q", tok.text)
+}
@@ -0,0 +1,85 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package expr
+
+import (
+  "strings"
+  "testing"
+)
+
+func Test_lex(t *testing.T) {
+  t.Log("Checking whether lex() splits an expression into tokens..")
+  tokens, err := lex(`speed>=80.5&&type=="T"`)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := []string{"speed", ">=", "80.5", "&&", "type", "==", "T", ""}
+  if len(tokens) != len(expected) {
+    t.Fatalf("Expected %d tokens, got %d", len(expected), len(tokens))
+  }
+  for idx, tok := range tokens {
+    if tok.text != expected[idx] {
+      t.Errorf("Expected token %d to be %q, got %q", idx, expected[idx], tok.text)
+    }
+  }
+}
+
+func Test_parseErrors(t *testing.T) {
+  t.Log("Checking whether parse() reports errors at the right column..")
+  cases := []struct {
+    src string
+    pos int
+    msg string
+  }{
+    {"speed > ", 8, "expected a value"},
+    {"speed = 80", 6, "use =="},
+    {"sped > 80", 0, "unknown field"},
+    {"speed > 80 temp", 11, "unexpected"},
+    {"(speed > 80", 11, "expected \")\""},
+    {"speed > \"fast\"", 6, "cannot order"},
+    {"speed && temp", 6, "needs two bools"},
+    {"abs(1, 2)", 0, "expected 1 arguments"},
+    {"nope(1)", 0, "unknown function"},
+    {"'open", 0, "unterminated string"},
+    {"", 0, "empty expression"},
+  }
+  for _, c := range cases {
+    _, err := parse(c.src)
+    serr, ok := err.(*SyntaxError)
+    if !ok {
+      t.Errorf("Expected a SyntaxError for %q, got %v", c.src, err)
+      continue
+    }
+    if serr.Pos != c.pos {
+      t.Errorf("Expected %q to fail at %d, got %d", c.src, c.pos, serr.Pos)
+    }
+    if !strings.Contains(serr.Msg, c.msg) {
+      t.Errorf("Expected %q to fail with %q, got %q", c.src, c.msg, serr.Msg)
+    }
+  }
+}
+
+func Test_SyntaxError(t *testing.T) {
+  t.Log("Checking whether SyntaxError points at the offending column..")
+  err := &SyntaxError{Source: "speed = 80", Pos: 6, Msg: "oops"}
+  expected := "oops at column 7\n  speed = 80\n        ^"
+  if out := err.Error(); out != expected {
+    t.Errorf("Expected %q, got %q", expected, out)
+  }
+}
@@ -0,0 +1,118 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package parquet
+
+import (
+  "bytes"
+  "encoding/binary"
+)
+
+// Thrift compact protocol types
+const (
+  thriftI32 byte = 5
+  thriftI64 byte = 6
+  thriftBinary byte = 8
+  thriftList byte = 9
+  thriftStruct byte = 12
+)
+
+// thriftWriter encodes Parquet's metadata structures with the Thrift compact
+// protocol
+type thriftWriter struct {
+  buf bytes.Buffer
+  lastID []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+  var b [binary.MaxVarintLen64]byte
+  n := binary.PutUvarint(b[:], v)
+  t.buf.Write(b[:n])
+}
+
+func zigzag(v int64) uint64 {
+  return uint64(v << 1) ^ uint64(v >> 63)
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+  last := &t.lastID[len(t.lastID) - 1]
+  if delta := id - *last; delta > 0 && delta <= 15 {
+    t.buf.WriteByte(byte(delta) << 4 | typ)
+  } else {
+    t.buf.WriteByte(typ)
+    t.varint(zigzag(int64(id)))
+  }
+  *last = id
+}
+
+// beginStruct starts a struct, as the top level value or after a field or
+// list header
+func (t *thriftWriter) beginStruct() {
+  t.lastID = append(t.lastID, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+  t.buf.WriteByte(0) // stop
+  t.lastID = t.lastID[:len(t.lastID) - 1]
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+  t.field(id, thriftI32)
+  t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+  t.field(id, thriftI64)
+  t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) str(id int16, v string) {
+  t.field(id, thriftBinary)
+  t.varint(uint64(len(v)))
+  t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) structField(id int16) {
+  t.field(id, thriftStruct)
+  t.beginStruct()
+}
+
+func (t *thriftWriter) list(id int16, elem byte, size int) {
+  t.field(id, thriftList)
+  if size < 15 {
+    t.buf.WriteByte(byte(size) << 4 | elem)
+  } else {
+    t.buf.WriteByte(0xf0 | elem)
+    t.varint(uint64(size))
+  }
+}
+
+// listI32 writes the elements of a list of i32s
+func (t *thriftWriter) listI32(values ...int32) {
+  for _, v := range values {
+    t.varint(zigzag(int64(v)))
+  }
+}
+
+// listStr writes the elements of a list of strings
+func (t *thriftWriter) listStr(values ...string) {
+  for _, v := range values {
+    t.varint(uint64(len(v)))
+    t.buf.WriteString(v)
+  }
+}
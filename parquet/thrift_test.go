@@ -0,0 +1,98 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package parquet
+
+import (
+  "bytes"
+  "encoding/binary"
+  "testing"
+)
+
+// thriftReader decodes the Thrift compact protocol into maps of field ID to
+// value, lists and scalars
+type thriftReader struct {
+  r *bytes.Reader
+}
+
+func (t thriftReader) varint() int64 {
+  u, _ := binary.ReadUvarint(t.r)
+  return int64(u >> 1) ^ -int64(u & 1)
+}
+
+func (t thriftReader) value(typ byte) interface{} {
+  switch typ {
+  case 1:
+    return true
+  case 2:
+    return false
+  case 4, thriftI32, thriftI64:
+    return t.varint()
+  case thriftBinary:
+    n, _ := binary.ReadUvarint(t.r)
+    b := make([]byte, n)
+    t.r.Read(b)
+    return string(b)
+  case thriftList:
+    header, _ := t.r.ReadByte()
+    size := int(header >> 4)
+    if size == 15 {
+      n, _ := binary.ReadUvarint(t.r)
+      size = int(n)
+    }
+    list := make([]interface{}, size)
+    for i := range list {
+      list[i] = t.value(header & 0x0f)
+    }
+    return list
+  case thriftStruct:
+    fields := map[int16]interface{}{}
+    var id int16
+    for {
+      header, _ := t.r.ReadByte()
+      if header == 0 {
+        return fields
+      }
+      if delta := header >> 4; delta != 0 {
+        id += int16(delta)
+      } else {
+        id = int16(t.varint())
+      }
+      fields[id] = t.value(header & 0x0f)
+    }
+  }
+  panic("unexpected thrift type")
+}
+
+func Test_thriftWriter(t *testing.T) {
+  t.Log("Checking whether thriftWriter encodes compact protocol fields..")
+  var w thriftWriter
+  w.beginStruct()
+  w.i32(1, -2)
+  w.i64(20, 300)
+  w.str(21, "ab")
+  w.list(22, thriftI32, 2)
+  w.listI32(1, 2)
+  w.endStruct()
+  // delta 1 i32 zigzag(-2) = 3; long form i64 id 20 (zigzag 40) zigzag(300) = 600;
+  // delta 1 binary; delta 1 list of 2 i32s
+  expected := []byte{0x15, 0x03, 0x06, 0x28, 0xd8, 0x04, 0x18, 0x02, 'a', 'b', 0x19, 0x25, 0x02, 0x04, 0x00}
+  if !bytes.Equal(w.buf.Bytes(), expected) {
+    t.Errorf("Expected % x, got % x", expected, w.buf.Bytes())
+  }
+}
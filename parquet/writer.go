@@ -0,0 +1,388 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package parquet writes flat Apache Parquet files: required columns of
+// plain encoded values, with no nesting.
+package parquet
+
+import (
+  "bytes"
+  "compress/gzip"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "math"
+
+  "github.com/klauspost/compress/snappy"
+  "github.com/klauspost/compress/zstd"
+)
+
+// Type is a Parquet physical type
+type Type int32
+
+const (
+  Int32 Type = 1
+  Int64 Type = 2
+  Double Type = 5
+  ByteArray Type = 6
+)
+
+// Annotation says how to interpret a physical type
+type Annotation int
+
+const (
+  NoAnnotation Annotation = iota
+  String // UTF-8 byte array
+  TimestampMillis // milliseconds since the Unix epoch, UTC
+)
+
+// convertedTypes maps annotations to Parquet's ConvertedType
+var convertedTypes = map[Annotation]int32{String: 0, TimestampMillis: 9}
+
+// Codec is a Parquet compression codec
+type Codec int32
+
+const (
+  Uncompressed Codec = 0
+  Snappy Codec = 1
+  Gzip Codec = 2
+  Zstd Codec = 6
+)
+
+// Column describes a required, top level column
+type Column struct {
+  Name string
+  Type Type
+  Annotation Annotation
+}
+
+const magic = "PAR1"
+
+// Writer writes rows to a Parquet file. The exported fields may be changed
+// before the first row is written.
+type Writer struct {
+  Codec Codec
+  RowGroupSize int64 // encoded bytes per row group, before compression
+  PageSize int // encoded bytes per data page, before compression
+  CreatedBy string
+
+  w io.Writer
+  offset int64
+  columns []Column
+  chunks []chunk
+  rows int64
+  size int64
+  groups []rowGroup
+  numRows int64
+  metadata [][2]string
+  zstd *zstd.Encoder
+}
+
+// chunk buffers a column's pages within the current row group
+type chunk struct {
+  values bytes.Buffer
+  count int
+  pages bytes.Buffer
+  numValues int64
+  uncompressed int64
+}
+
+type rowGroup struct {
+  chunks []chunkMeta
+  numRows int64
+  size int64
+}
+
+type chunkMeta struct {
+  offset int64
+  numValues int64
+  uncompressed int64
+  compressed int64
+}
+
+// NewWriter starts a Parquet file with the given columns
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+  if len(columns) == 0 {
+    return nil, fmt.Errorf("parquet: no columns")
+  }
+  for _, c := range columns {
+    switch c.Type {
+    case Int32, Int64, Double, ByteArray:
+    default:
+      return nil, fmt.Errorf("parquet: column %s: unsupported type %d", c.Name, c.Type)
+    }
+  }
+  if _, err := io.WriteString(w, magic); err != nil {
+    return nil, err
+  }
+  return &Writer{
+    Codec: Snappy,
+    RowGroupSize: 128 * 1024 * 1024,
+    PageSize: 1024 * 1024,
+    CreatedBy: "columbus-v1000",
+    w: w,
+    offset: int64(len(magic)),
+    columns: columns,
+    chunks: make([]chunk, len(columns)),
+  }, nil
+}
+
+// SetMetadata adds a key/value pair to the file metadata
+func (w *Writer) SetMetadata(key, value string) {
+  w.metadata = append(w.metadata, [2]string{key, value})
+}
+
+// Write adds a row, with a value per column of the matching Go type: int32,
+// int64, float64, or string or []byte for byte arrays
+func (w *Writer) Write(values ...interface{}) error {
+  if len(values) != len(w.columns) {
+    return fmt.Errorf("parquet: expected %d values, got %d", len(w.columns), len(values))
+  }
+  for i, v := range values {
+    c := &w.chunks[i]
+    before := c.values.Len()
+    switch x := v.(type) {
+    case int32:
+      if w.columns[i].Type != Int32 {
+        return w.typeError(i, v)
+      }
+      binary.Write(&c.values, binary.LittleEndian, x)
+    case int64:
+      if w.columns[i].Type != Int64 {
+        return w.typeError(i, v)
+      }
+      binary.Write(&c.values, binary.LittleEndian, x)
+    case float64:
+      if w.columns[i].Type != Double {
+        return w.typeError(i, v)
+      }
+      binary.Write(&c.values, binary.LittleEndian, math.Float64bits(x))
+    case string:
+      if w.columns[i].Type != ByteArray {
+        return w.typeError(i, v)
+      }
+      binary.Write(&c.values, binary.LittleEndian, uint32(len(x)))
+      c.values.WriteString(x)
+    case []byte:
+      if w.columns[i].Type != ByteArray {
+        return w.typeError(i, v)
+      }
+      binary.Write(&c.values, binary.LittleEndian, uint32(len(x)))
+      c.values.Write(x)
+    default:
+      return w.typeError(i, v)
+    }
+    c.count++
+    w.size += int64(c.values.Len() - before)
+  }
+  for i := range w.chunks {
+    if w.chunks[i].values.Len() >= w.PageSize {
+      if err := w.finishPage(i); err != nil {
+        return err
+      }
+    }
+  }
+  w.rows++
+  if w.size >= w.RowGroupSize {
+    return w.flushRowGroup()
+  }
+  return nil
+}
+
+func (w *Writer) typeError(i int, v interface{}) error {
+  return fmt.Errorf("parquet: column %s: cannot write %T", w.columns[i].Name, v)
+}
+
+// finishPage compresses column i's buffered values into a data page
+func (w *Writer) finishPage(i int) error {
+  c := &w.chunks[i]
+  if c.count == 0 {
+    return nil
+  }
+  data := c.values.Bytes()
+  compressed, err := w.compress(data)
+  if err != nil {
+    return err
+  }
+  var t thriftWriter
+  t.beginStruct()
+  t.i32(1, 0) // DATA_PAGE
+  t.i32(2, int32(len(data)))
+  t.i32(3, int32(len(compressed)))
+  t.structField(5)
+  t.i32(1, int32(c.count))
+  t.i32(2, 0) // PLAIN
+  t.i32(3, 3) // RLE, though required columns have no levels
+  t.i32(4, 3)
+  t.endStruct()
+  t.endStruct()
+
+  c.pages.Write(t.buf.Bytes())
+  c.pages.Write(compressed)
+  c.uncompressed += int64(t.buf.Len() + len(data))
+  c.numValues += int64(c.count)
+  c.values.Reset()
+  c.count = 0
+  return nil
+}
+
+func (w *Writer) compress(data []byte) ([]byte, error) {
+  switch w.Codec {
+  case Uncompressed:
+    return append([]byte(nil), data...), nil
+  case Snappy:
+    return snappy.Encode(nil, data), nil
+  case Gzip:
+    var b bytes.Buffer
+    gz := gzip.NewWriter(&b)
+    if _, err := gz.Write(data); err != nil {
+      return nil, err
+    }
+    if err := gz.Close(); err != nil {
+      return nil, err
+    }
+    return b.Bytes(), nil
+  case Zstd:
+    if w.zstd == nil {
+      enc, err := zstd.NewWriter(nil)
+      if err != nil {
+        return nil, err
+      }
+      w.zstd = enc
+    }
+    return w.zstd.EncodeAll(data, nil), nil
+  }
+  return nil, fmt.Errorf("parquet: unsupported codec %d", w.Codec)
+}
+
+// flushRowGroup writes the buffered rows' column chunks
+func (w *Writer) flushRowGroup() error {
+  if w.rows == 0 {
+    return nil
+  }
+  group := rowGroup{numRows: w.rows}
+  for i := range w.chunks {
+    if err := w.finishPage(i); err != nil {
+      return err
+    }
+    c := &w.chunks[i]
+    meta := chunkMeta{
+      offset: w.offset,
+      numValues: c.numValues,
+      uncompressed: c.uncompressed,
+      compressed: int64(c.pages.Len()),
+    }
+    n, err := w.w.Write(c.pages.Bytes())
+    w.offset += int64(n)
+    if err != nil {
+      return err
+    }
+    group.chunks = append(group.chunks, meta)
+    group.size += meta.uncompressed
+    w.chunks[i] = chunk{}
+  }
+  w.groups = append(w.groups, group)
+  w.numRows += w.rows
+  w.rows = 0
+  w.size = 0
+  return nil
+}
+
+// Close writes any buffered rows and the file footer, and releases the zstd
+// encoder. It does not close the underlying writer.
+func (w *Writer) Close() error {
+  if w.zstd != nil {
+    defer w.zstd.Close()
+  }
+  if err := w.flushRowGroup(); err != nil {
+    return err
+  }
+  footer := w.footer()
+  if _, err := w.w.Write(footer); err != nil {
+    return err
+  }
+  if err := binary.Write(w.w, binary.LittleEndian, uint32(len(footer))); err != nil {
+    return err
+  }
+  _, err := io.WriteString(w.w, magic)
+  return err
+}
+
+// footer encodes the FileMetaData
+func (w *Writer) footer() []byte {
+  var t thriftWriter
+  t.beginStruct()
+  t.i32(1, 1) // version
+
+  t.list(2, thriftStruct, len(w.columns) + 1)
+  t.beginStruct()
+  t.str(4, "schema")
+  t.i32(5, int32(len(w.columns)))
+  t.endStruct()
+  for _, c := range w.columns {
+    t.beginStruct()
+    t.i32(1, int32(c.Type))
+    t.i32(3, 0) // REQUIRED
+    t.str(4, c.Name)
+    if converted, ok := convertedTypes[c.Annotation]; ok {
+      t.i32(6, converted)
+    }
+    t.endStruct()
+  }
+
+  t.i64(3, w.numRows)
+
+  t.list(4, thriftStruct, len(w.groups))
+  for _, g := range w.groups {
+    t.beginStruct()
+    t.list(1, thriftStruct, len(g.chunks))
+    for i, m := range g.chunks {
+      t.beginStruct()
+      t.i64(2, m.offset)
+      t.structField(3)
+      t.i32(1, int32(w.columns[i].Type))
+      t.list(2, thriftI32, 2)
+      t.listI32(0, 3) // PLAIN, RLE
+      t.list(3, thriftBinary, 1)
+      t.listStr(w.columns[i].Name)
+      t.i32(4, int32(w.Codec))
+      t.i64(5, m.numValues)
+      t.i64(6, m.uncompressed)
+      t.i64(7, m.compressed)
+      t.i64(9, m.offset)
+      t.endStruct()
+      t.endStruct()
+    }
+    t.i64(2, g.size)
+    t.i64(3, g.numRows)
+    t.endStruct()
+  }
+
+  if len(w.metadata) > 0 {
+    t.list(5, thriftStruct, len(w.metadata))
+    for _, kv := range w.metadata {
+      t.beginStruct()
+      t.str(1, kv[0])
+      t.str(2, kv[1])
+      t.endStruct()
+    }
+  }
+  t.str(6, w.CreatedBy)
+  t.endStruct()
+  return t.buf.Bytes()
+}
@@ -0,0 +1,104 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package parquet
+
+import (
+  "bytes"
+  "encoding/binary"
+  "math"
+  "testing"
+
+  "github.com/klauspost/compress/snappy"
+)
+
+func Test_Writer(t *testing.T) {
+  t.Log("Checking whether Writer writes readable row groups and metadata..")
+  var buf bytes.Buffer
+  w, err := NewWriter(&buf, []Column{
+    {Name: "id", Type: Int64},
+    {Name: "name", Type: ByteArray, Annotation: String},
+    {Name: "value", Type: Double},
+  })
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  w.RowGroupSize = 40
+  w.SetMetadata("geo", "{}")
+  for i := 0; i < 3; i++ {
+    if err := w.Write(int64(i), "ab", float64(i) / 2); err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+  }
+  if err := w.Write(int32(1), "x", 1.0); err == nil {
+    t.Errorf("Expected an error writing int32 to an INT64 column")
+  }
+  if err := w.Close(); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  data := buf.Bytes()
+  if string(data[:4]) != "PAR1" || string(data[len(data) - 4:]) != "PAR1" {
+    t.Fatalf("Expected PAR1 at both ends")
+  }
+  size := int(binary.LittleEndian.Uint32(data[len(data) - 8:]))
+  footer := data[len(data) - 8 - size:len(data) - 8]
+  meta := thriftReader{bytes.NewReader(footer)}.value(thriftStruct).(map[int16]interface{})
+
+  schema := meta[2].([]interface{})
+  if len(schema) != 4 || schema[0].(map[int16]interface{})[5] != int64(3) {
+    t.Errorf("Expected a root and 3 columns, got %v", schema)
+  }
+  if name := schema[2].(map[int16]interface{}); name[4] != "name" || name[1] != int64(ByteArray) || name[6] != int64(0) {
+    t.Errorf("Expected a UTF8 byte array column name, got %v", name)
+  }
+  if meta[3] != int64(3) {
+    t.Errorf("Expected 3 rows, got %v", meta[3])
+  }
+  kv := meta[5].([]interface{})[0].(map[int16]interface{})
+  if kv[1] != "geo" || kv[2] != "{}" {
+    t.Errorf("Expected geo metadata, got %v", kv)
+  }
+
+  // each row is 8 + 6 + 8 bytes, so rows are grouped two and one
+  groups := meta[4].([]interface{})
+  if len(groups) != 2 {
+    t.Fatalf("Expected 2 row groups, got %d", len(groups))
+  }
+  var values []float64
+  for _, g := range groups {
+    chunk := g.(map[int16]interface{})[1].([]interface{})[2].(map[int16]interface{})[3].(map[int16]interface{})
+    if chunk[4] != int64(Snappy) {
+      t.Errorf("Expected snappy compression, got %v", chunk[4])
+    }
+    r := bytes.NewReader(data[chunk[9].(int64):])
+    header := thriftReader{r}.value(thriftStruct).(map[int16]interface{})
+    page := make([]byte, header[3].(int64))
+    r.Read(page)
+    plain, err := snappy.Decode(nil, page)
+    if err != nil {
+      t.Fatalf("Unexpected error: %v", err)
+    }
+    for ; len(plain) >= 8; plain = plain[8:] {
+      values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(plain)))
+    }
+  }
+  if len(values) != 3 || values[0] != 0 || values[2] != 1 {
+    t.Errorf("Expected values 0, 0.5 and 1, got %v", values)
+  }
+}
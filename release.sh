@@ -5,5 +5,4 @@ if [ -z "$TRAVIS_TAG" ]; then
   exit 0
 fi
 
-go get github.com/inconshreveable/mousetrap
 curl -sL https://git.io/goreleaser | bash
@@ -0,0 +1,180 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package shapefile
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "time"
+  "unicode/utf8"
+)
+
+// FieldType is a dBase field type
+type FieldType byte
+
+const (
+  Character FieldType = 'C'
+  Numeric FieldType = 'N'
+  DateField FieldType = 'D'
+)
+
+// MaxFieldName is the longest field name dBase allows
+const MaxFieldName = 10
+
+// Field is a dBase attribute column. Length and Decimals apply to Character
+// and Numeric fields; dates are always eight characters, YYYYMMDD.
+type Field struct {
+  Name string
+  Type FieldType
+  Length int
+  Decimals int
+}
+
+// Table is a dBase III attribute table
+type Table struct {
+  Modified time.Time // the last update date in the header
+
+  fields []Field
+  rows bytes.Buffer
+  count int
+}
+
+// NewTable starts a table with the given fields
+func NewTable(fields []Field) (*Table, error) {
+  seen := map[string]bool{}
+  fields = append([]Field(nil), fields...)
+  for i := range fields {
+    f := &fields[i]
+    name := strings.ToUpper(f.Name)
+    if name == "" || len(name) > MaxFieldName {
+      return nil, fmt.Errorf("shapefile: field name %q must be 1 to %d characters", f.Name, MaxFieldName)
+    }
+    if seen[name] {
+      return nil, fmt.Errorf("shapefile: duplicate field name %s", name)
+    }
+    seen[name] = true
+    switch f.Type {
+    case Character, Numeric:
+      if f.Length < 1 || f.Length > 254 {
+        return nil, fmt.Errorf("shapefile: field %s: invalid length %d", name, f.Length)
+      }
+    case DateField:
+      f.Length, f.Decimals = 8, 0
+    default:
+      return nil, fmt.Errorf("shapefile: field %s: unsupported type %c", name, f.Type)
+    }
+  }
+  return &Table{Modified: time.Now(), fields: fields}, nil
+}
+
+// Add appends a row. Character fields take strings, Numeric fields take
+// integers or float64s, and date fields take time.Time.
+func (t *Table) Add(values ...interface{}) error {
+  if len(values) != len(t.fields) {
+    return fmt.Errorf("shapefile: expected %d values, got %d", len(t.fields), len(values))
+  }
+  var row bytes.Buffer
+  row.WriteByte(' ') // not deleted
+  for i, f := range t.fields {
+    s, err := formatValue(f, values[i])
+    if err != nil {
+      return fmt.Errorf("shapefile: field %s: %v", strings.ToUpper(f.Name), err)
+    }
+    row.WriteString(s)
+  }
+  t.rows.Write(row.Bytes())
+  t.count++
+  return nil
+}
+
+// formatValue renders v as exactly f.Length bytes
+func formatValue(f Field, v interface{}) (string, error) {
+  switch f.Type {
+  case Character:
+    s, ok := v.(string)
+    if !ok {
+      return "", fmt.Errorf("expected a string, got %T", v)
+    }
+    // truncate on a character boundary
+    for len(s) > f.Length {
+      _, size := utf8.DecodeLastRuneInString(s)
+      s = s[:len(s) - size]
+    }
+    return s + strings.Repeat(" ", f.Length - len(s)), nil
+  case Numeric:
+    var s string
+    switch n := v.(type) {
+    case int:
+      s = strconv.FormatInt(int64(n), 10)
+    case int64:
+      s = strconv.FormatInt(n, 10)
+    case uint16:
+      s = strconv.FormatUint(uint64(n), 10)
+    case uint32:
+      s = strconv.FormatUint(uint64(n), 10)
+    case float64:
+      s = strconv.FormatFloat(n, 'f', f.Decimals, 64)
+    default:
+      return "", fmt.Errorf("expected a number, got %T", v)
+    }
+    if len(s) > f.Length {
+      return "", fmt.Errorf("%s does not fit in %d characters", s, f.Length)
+    }
+    return strings.Repeat(" ", f.Length - len(s)) + s, nil
+  case DateField:
+    d, ok := v.(time.Time)
+    if !ok {
+      return "", fmt.Errorf("expected a time.Time, got %T", v)
+    }
+    return d.Format("20060102"), nil
+  }
+  return "", fmt.Errorf("unsupported type %c", f.Type)
+}
+
+// WriteTo writes the table as a .dbf file
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+  recordSize := 1
+  for _, f := range t.fields {
+    recordSize += f.Length
+  }
+  var b bytes.Buffer
+  b.WriteByte(0x03) // dBase III without memo
+  b.Write([]byte{byte(t.Modified.Year() - 1900), byte(t.Modified.Month()), byte(t.Modified.Day())})
+  binary.Write(&b, binary.LittleEndian, uint32(t.count))
+  binary.Write(&b, binary.LittleEndian, uint16(32 + 32 * len(t.fields) + 1))
+  binary.Write(&b, binary.LittleEndian, uint16(recordSize))
+  b.Write(make([]byte, 20))
+  for _, f := range t.fields {
+    var name [11]byte
+    copy(name[:], strings.ToUpper(f.Name))
+    b.Write(name[:])
+    b.WriteByte(byte(f.Type))
+    b.Write(make([]byte, 4))
+    b.Write([]byte{byte(f.Length), byte(f.Decimals)})
+    b.Write(make([]byte, 14))
+  }
+  b.WriteByte(0x0d)
+  b.Write(t.rows.Bytes())
+  b.WriteByte(0x1a)
+  return b.WriteTo(w)
+}
@@ -0,0 +1,86 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package shapefile
+
+import (
+  "bytes"
+  "encoding/binary"
+  "testing"
+  "time"
+)
+
+func Test_Table(t *testing.T) {
+  t.Log("Checking whether Table writes dBase III headers and rows..")
+  table, err := NewTable([]Field{
+    {Name: "name", Type: Character, Length: 4},
+    {Name: "speed_kmh", Type: Numeric, Length: 6, Decimals: 2},
+    {Name: "date", Type: DateField},
+  })
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  table.Modified = time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC)
+  day := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  if err := table.Add("héllo", 1.5, day); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if err := table.Add("a", 12345.0, day); err == nil {
+    t.Errorf("Expected an error for a number too wide for its field")
+  }
+  if err := table.Add("a", "fast", day); err == nil {
+    t.Errorf("Expected an error for a string in a numeric field")
+  }
+
+  var b bytes.Buffer
+  table.WriteTo(&b)
+  data := b.Bytes()
+  if !bytes.Equal(data[:4], []byte{3, 117, 4, 1}) {
+    t.Errorf("Expected version 3 and date 117-04-01, got % x", data[:4])
+  }
+  if n := binary.LittleEndian.Uint32(data[4:]); n != 1 {
+    t.Errorf("Expected 1 row, got %d", n)
+  }
+  headerSize, recordSize := binary.LittleEndian.Uint16(data[8:]), binary.LittleEndian.Uint16(data[10:])
+  if headerSize != 32 + 3 * 32 + 1 || recordSize != 1 + 4 + 6 + 8 {
+    t.Errorf("Expected header 129 and record 19 bytes, got %d and %d", headerSize, recordSize)
+  }
+  if name := string(bytes.TrimRight(data[64:75], "\x00")); name != "SPEED_KMH" || data[75] != 'N' || data[80] != 6 || data[81] != 2 {
+    t.Errorf("Expected field SPEED_KMH N(6,2), got %s %c(%d,%d)", name, data[75], data[80], data[81])
+  }
+  // "héllo" is truncated to four bytes on a character boundary
+  if row := string(data[headerSize:len(data) - 1]); row != " hél  1.5020170401" {
+    t.Errorf("Expected row %q, got %q", " hél  1.5020170401", row)
+  }
+  if data[len(data) - 1] != 0x1a {
+    t.Errorf("Expected an end of file marker")
+  }
+}
+
+func Test_NewTable(t *testing.T) {
+  t.Log("Checking whether NewTable enforces dBase field names..")
+  if _, err := NewTable([]Field{{Name: "temperature", Type: Numeric, Length: 4}}); err == nil {
+    t.Errorf("Expected an error for an 11 character name")
+  }
+  if _, err := NewTable([]Field{{Name: "a", Type: Numeric, Length: 4}, {Name: "A", Type: Character, Length: 1}}); err == nil {
+    t.Errorf("Expected an error for duplicate names")
+  }
+  if _, err := NewTable([]Field{{Name: "a", Type: Character}}); err == nil {
+    t.Errorf("Expected an error for a zero length")
+  }
+}
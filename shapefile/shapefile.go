@@ -0,0 +1,225 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package shapefile writes ESRI Shapefiles: the .shp geometry, .shx index,
+// .dbf attribute table and .prj projection of a single layer.
+package shapefile
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "math"
+  "strings"
+)
+
+// ShapeType is a Shapefile geometry type
+type ShapeType int32
+
+const (
+  PointZ ShapeType = 11
+  PolyLineZ ShapeType = 13
+)
+
+// Point is a vertex with elevation and measure
+type Point struct {
+  X, Y, Z, M float64
+}
+
+// WGS84 is the .prj projection for longitude/latitude on WGS 84
+const WGS84 = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+
+const fileCode = 9994
+const version = 1000
+const headerSize = 100
+
+// box is the extent of shapes in all four dimensions
+type box struct {
+  minX, minY, maxX, maxY, minZ, maxZ, minM, maxM float64
+}
+
+func emptyBox() box {
+  return box{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+}
+
+func (b *box) add(p Point) {
+  b.minX, b.maxX = math.Min(b.minX, p.X), math.Max(b.maxX, p.X)
+  b.minY, b.maxY = math.Min(b.minY, p.Y), math.Max(b.maxY, p.Y)
+  b.minZ, b.maxZ = math.Min(b.minZ, p.Z), math.Max(b.maxZ, p.Z)
+  b.minM, b.maxM = math.Min(b.minM, p.M), math.Max(b.maxM, p.M)
+}
+
+// values returns the box in header order, or zeros if it is empty
+func (b box) values() [8]float64 {
+  if b.minX > b.maxX {
+    return [8]float64{}
+  }
+  return [8]float64{b.minX, b.minY, b.maxX, b.maxY, b.minZ, b.maxZ, b.minM, b.maxM}
+}
+
+// Writer collects the shapes and attributes of a layer. Shapefile headers
+// hold the file length and extent, so nothing is written until WriteTo.
+type Writer struct {
+  shapeType ShapeType
+  shp bytes.Buffer
+  shx bytes.Buffer
+  count int
+  bounds box
+  dbf *Table
+}
+
+// NewWriter starts a layer of shapeType with the given attribute fields
+func NewWriter(shapeType ShapeType, fields []Field) (*Writer, error) {
+  switch shapeType {
+  case PointZ, PolyLineZ:
+  default:
+    return nil, fmt.Errorf("shapefile: unsupported shape type %d", shapeType)
+  }
+  dbf, err := NewTable(fields)
+  if err != nil {
+    return nil, err
+  }
+  return &Writer{shapeType: shapeType, bounds: emptyBox(), dbf: dbf}, nil
+}
+
+// Table is the layer's attribute table
+func (w *Writer) Table() *Table {
+  return w.dbf
+}
+
+// Add appends a shape and its attributes. A PointZ takes exactly one part
+// of one point; a PolyLineZ takes one or more parts of two or more points.
+func (w *Writer) Add(parts [][]Point, values ...interface{}) error {
+  var content bytes.Buffer
+  switch w.shapeType {
+  case PointZ:
+    if len(parts) != 1 || len(parts[0]) != 1 {
+      return fmt.Errorf("shapefile: a PointZ needs one point")
+    }
+    p := parts[0][0]
+    binary.Write(&content, binary.LittleEndian, int32(PointZ))
+    binary.Write(&content, binary.LittleEndian, [4]float64{p.X, p.Y, p.Z, p.M})
+    w.bounds.add(p)
+  case PolyLineZ:
+    if len(parts) == 0 {
+      return fmt.Errorf("shapefile: a PolyLineZ needs at least one part")
+    }
+    for _, part := range parts {
+      if len(part) < 2 {
+        return fmt.Errorf("shapefile: PolyLineZ parts need at least two points")
+      }
+    }
+    writePolyLineZ(&content, parts)
+    for _, part := range parts {
+      for _, p := range part {
+        w.bounds.add(p)
+      }
+    }
+  }
+  if err := w.dbf.Add(values...); err != nil {
+    return err
+  }
+
+  w.count++
+  offset := headerSize + w.shp.Len()
+  binary.Write(&w.shx, binary.BigEndian, [2]int32{int32(offset / 2), int32(content.Len() / 2)})
+  binary.Write(&w.shp, binary.BigEndian, [2]int32{int32(w.count), int32(content.Len() / 2)})
+  w.shp.Write(content.Bytes())
+  return nil
+}
+
+func writePolyLineZ(b *bytes.Buffer, parts [][]Point) {
+  extent := emptyBox()
+  var indexes []int32
+  var xy []float64
+  var z, m []float64
+  for _, part := range parts {
+    indexes = append(indexes, int32(len(xy) / 2))
+    for _, p := range part {
+      extent.add(p)
+      xy = append(xy, p.X, p.Y)
+      z = append(z, p.Z)
+      m = append(m, p.M)
+    }
+  }
+  binary.Write(b, binary.LittleEndian, int32(PolyLineZ))
+  binary.Write(b, binary.LittleEndian, [4]float64{extent.minX, extent.minY, extent.maxX, extent.maxY})
+  binary.Write(b, binary.LittleEndian, [2]int32{int32(len(parts)), int32(len(z))})
+  binary.Write(b, binary.LittleEndian, indexes)
+  binary.Write(b, binary.LittleEndian, xy)
+  binary.Write(b, binary.LittleEndian, [2]float64{extent.minZ, extent.maxZ})
+  binary.Write(b, binary.LittleEndian, z)
+  binary.Write(b, binary.LittleEndian, [2]float64{extent.minM, extent.maxM})
+  binary.Write(b, binary.LittleEndian, m)
+}
+
+// header writes the header shared by the .shp and .shx files
+func (w *Writer) header(out io.Writer, length int) error {
+  var b bytes.Buffer
+  binary.Write(&b, binary.BigEndian, [7]int32{fileCode, 0, 0, 0, 0, 0, int32(length / 2)})
+  binary.Write(&b, binary.LittleEndian, [2]int32{version, int32(w.shapeType)})
+  binary.Write(&b, binary.LittleEndian, w.bounds.values())
+  _, err := out.Write(b.Bytes())
+  return err
+}
+
+// WriteTo writes the layer's .shp, .shx and .dbf files
+func (w *Writer) WriteTo(shp, shx, dbf io.Writer) error {
+  if err := w.header(shp, headerSize + w.shp.Len()); err != nil {
+    return err
+  }
+  if _, err := shp.Write(w.shp.Bytes()); err != nil {
+    return err
+  }
+  if err := w.header(shx, headerSize + w.shx.Len()); err != nil {
+    return err
+  }
+  if _, err := shx.Write(w.shx.Bytes()); err != nil {
+    return err
+  }
+  _, err := w.dbf.WriteTo(dbf)
+  return err
+}
+
+// Create writes the layer to base.shp, base.shx, base.dbf, base.prj and
+// base.cpg, replacing any existing files
+func (w *Writer) Create(base, prj string) error {
+  base = strings.TrimSuffix(base, ".shp")
+  var shp, shx, dbf bytes.Buffer
+  if err := w.WriteTo(&shp, &shx, &dbf); err != nil {
+    return err
+  }
+  files := []struct {
+    ext string
+    data []byte
+  }{
+    {".shp", shp.Bytes()},
+    {".shx", shx.Bytes()},
+    {".dbf", dbf.Bytes()},
+    {".prj", []byte(prj)},
+    {".cpg", []byte("UTF-8")},
+  }
+  for _, f := range files {
+    if err := ioutil.WriteFile(base + f.ext, f.data, 0644); err != nil {
+      return err
+    }
+  }
+  return nil
+}
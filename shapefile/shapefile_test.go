@@ -0,0 +1,123 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package shapefile
+
+import (
+  "bytes"
+  "encoding/binary"
+  "math"
+  "testing"
+)
+
+func Test_Writer_PolyLineZ(t *testing.T) {
+  t.Log("Checking whether Writer writes PolyLineZ shapes, headers and index..")
+  w, err := NewWriter(PolyLineZ, []Field{{Name: "id", Type: Numeric, Length: 4}})
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  parts := [][]Point{
+    {{1, 2, 10, 100}, {3, 4, 20, 200}},
+    {{-1, 0, 5, 300}, {0, 1, 15, 400}},
+  }
+  if err := w.Add(parts, 1); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if err := w.Add([][]Point{{{0, 0, 0, 0}}}, 2); err == nil {
+    t.Errorf("Expected an error for a one point line")
+  }
+  var shp, shx, dbf bytes.Buffer
+  if err := w.WriteTo(&shp, &shx, &dbf); err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+
+  b := shp.Bytes()
+  // content: 4 + 32 + 8 + 2*4 + 4*16 + 16 + 4*8 + 16 + 4*8 = 212 bytes
+  if code := binary.BigEndian.Uint32(b); code != 9994 {
+    t.Errorf("Expected file code 9994, got %d", code)
+  }
+  if length := int(binary.BigEndian.Uint32(b[24:])) * 2; length != len(b) || length != 100 + 8 + 212 {
+    t.Errorf("Expected file length %d, got %d", len(b), length)
+  }
+  if typ := binary.LittleEndian.Uint32(b[32:]); typ != 13 {
+    t.Errorf("Expected shape type 13, got %d", typ)
+  }
+  var bounds [8]float64
+  binary.Read(bytes.NewReader(b[36:]), binary.LittleEndian, &bounds)
+  if bounds != [8]float64{-1, 0, 3, 4, 5, 20, 100, 400} {
+    t.Errorf("Expected bounds [-1 0 3 4 5 20 100 400], got %v", bounds)
+  }
+  if n, size := binary.BigEndian.Uint32(b[100:]), binary.BigEndian.Uint32(b[104:]); n != 1 || size != 106 {
+    t.Errorf("Expected record 1 of 106 words, got %d of %d", n, size)
+  }
+  content := b[108:]
+  if parts, points := binary.LittleEndian.Uint32(content[36:]), binary.LittleEndian.Uint32(content[40:]); parts != 2 || points != 4 {
+    t.Errorf("Expected 2 parts of 4 points, got %d of %d", parts, points)
+  }
+  if second := binary.LittleEndian.Uint32(content[48:]); second != 2 {
+    t.Errorf("Expected the second part to start at point 2, got %d", second)
+  }
+  lastM := math.Float64frombits(binary.LittleEndian.Uint64(content[len(content) - 8:]))
+  if lastM != 400 {
+    t.Errorf("Expected the last measure to be 400, got %v", lastM)
+  }
+
+  x := shx.Bytes()
+  if len(x) != 108 || int(binary.BigEndian.Uint32(x[24:])) * 2 != 108 {
+    t.Errorf("Expected a 108 byte index, got %d", len(x))
+  }
+  if offset, size := binary.BigEndian.Uint32(x[100:]), binary.BigEndian.Uint32(x[104:]); offset != 50 || size != 106 {
+    t.Errorf("Expected offset 50 and length 106, got %d and %d", offset, size)
+  }
+  if dbf.Bytes()[4] != 1 {
+    t.Errorf("Expected 1 attribute row, got %d", dbf.Bytes()[4])
+  }
+}
+
+func Test_Writer_PointZ(t *testing.T) {
+  t.Log("Checking whether Writer writes PointZ shapes..")
+  w, _ := NewWriter(PointZ, nil)
+  if err := w.Add([][]Point{{{1, 2, 3, 4}, {5, 6, 7, 8}}}); err == nil {
+    t.Errorf("Expected an error for two points")
+  }
+  w.Add([][]Point{{{1, 2, 3, 4}}})
+  var shp, shx, dbf bytes.Buffer
+  w.WriteTo(&shp, &shx, &dbf)
+  b := shp.Bytes()
+  if len(b) != 100 + 8 + 36 {
+    t.Fatalf("Expected 144 bytes, got %d", len(b))
+  }
+  var p [4]float64
+  binary.Read(bytes.NewReader(b[112:]), binary.LittleEndian, &p)
+  if p != [4]float64{1, 2, 3, 4} {
+    t.Errorf("Expected point [1 2 3 4], got %v", p)
+  }
+}
+
+func Test_Writer_empty(t *testing.T) {
+  t.Log("Checking whether an empty layer has a zero extent..")
+  w, _ := NewWriter(PointZ, nil)
+  var shp, shx, dbf bytes.Buffer
+  w.WriteTo(&shp, &shx, &dbf)
+  if shp.Len() != 100 || !bytes.Equal(shp.Bytes()[36:], make([]byte, 64)) {
+    t.Errorf("Expected a 100 byte header with zero bounds")
+  }
+  if _, err := NewWriter(ShapeType(5), nil); err == nil {
+    t.Errorf("Expected an error for shape type 5")
+  }
+}
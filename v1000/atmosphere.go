@@ -0,0 +1,42 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+)
+
+// ISA troposphere constants, as used for barometric altimetry
+const (
+  // StandardPressure is the sea level pressure in hPa
+  StandardPressure = 1013.25
+  isaHeightScale = 44330.77 // T0 / L, in metres
+  isaExponent = 0.190263    // R * L / g
+)
+
+// PressureAltitude returns the altitude in metres at which the International
+// Standard Atmosphere has the given pressure in hPa
+func PressureAltitude(hPa float64) float64 {
+  return isaHeightScale * (1 - math.Pow(hPa / StandardPressure, isaExponent))
+}
+
+// AltitudePressure is the inverse of PressureAltitude
+func AltitudePressure(metres float64) float64 {
+  return StandardPressure * math.Pow(1 - metres / isaHeightScale, 1 / isaExponent)
+}
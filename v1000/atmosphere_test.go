@@ -0,0 +1,43 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+  "testing"
+)
+
+func Test_PressureAltitude(t *testing.T) {
+  t.Log("Checking whether PressureAltitude() follows the ISA..")
+  cases := map[float64]float64{1013.25: 0, 898.75: 1000, 701.09: 3000}
+  for hPa, expected := range cases {
+    if out := PressureAltitude(hPa); math.Abs(out - expected) > 1 {
+      t.Errorf("Expected %.0fm at %.2fhPa, got %.1fm", expected, hPa, out)
+    }
+  }
+}
+
+func Test_AltitudePressure(t *testing.T) {
+  t.Log("Checking whether AltitudePressure() inverts PressureAltitude()..")
+  for _, hPa := range []float64{1050, 1013.25, 950.5, 500} {
+    if out := AltitudePressure(PressureAltitude(hPa)); math.Abs(out - hPa) > 1e-6 {
+      t.Errorf("Expected %.2f, got %.6f", hPa, out)
+    }
+  }
+}
@@ -0,0 +1,206 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "encoding/csv"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+)
+
+// CSVReader reads the CSV files the device writes when logging in CSV mode.
+// Columns are located by their header, so files from firmware with extra
+// columns are accepted.
+type CSVReader struct {
+  r *csv.Reader
+  columns map[string]int
+  line int
+}
+
+// csvRequired are the header columns a device CSV file must have
+var csvRequired = []string{"INDEX", "TAG", "DATE", "TIME", "LATITUDE N/S", "LONGITUDE E/W"}
+
+// NewCSVReader reads the header row and returns a reader positioned on the
+// first record
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+  cr := csv.NewReader(r)
+  cr.FieldsPerRecord = -1
+  cr.LazyQuotes = true
+  cr.TrimLeadingSpace = true
+  hdr, err := cr.Read()
+  if err != nil {
+    if err == io.EOF {
+      return nil, fmt.Errorf("csv: missing header")
+    }
+    return nil, err
+  }
+  columns := make(map[string]int)
+  for i, name := range hdr {
+    // files saved from a spreadsheet may start with a byte order mark
+    name = strings.TrimPrefix(name, "\ufeff")
+    name = strings.ToUpper(cleanCSVField(name))
+    columns[name] = i
+  }
+  for _, name := range csvRequired {
+    if _, ok := columns[name]; !ok {
+      return nil, fmt.Errorf("csv: missing %q column", name)
+    }
+  }
+  return &CSVReader{r: cr, columns: columns, line: 1}, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the file
+func (c *CSVReader) Read() (Record, error) {
+  var rec Record
+  var row []string
+  for {
+    var err error
+    row, err = c.r.Read()
+    if err != nil {
+      return rec, err
+    }
+    c.line++
+    // trailing rows of padding are common at the end of device files
+    if len(row) > 1 || (len(row) == 1 && cleanCSVField(row[0]) != "") {
+      break
+    }
+  }
+  field := func(name string) string {
+    if i, ok := c.columns[name]; ok && i < len(row) {
+      return cleanCSVField(row[i])
+    }
+    return ""
+  }
+
+  var err error
+  fail := func(name string, err error) (Record, error) {
+    return rec, fmt.Errorf("csv: line %d: %s: %v", c.line, name, err)
+  }
+
+  index, err := strconv.ParseUint(field("INDEX"), 10, 32)
+  if err != nil {
+    return fail("INDEX", err)
+  }
+  rec.Index = uint32(index)
+  if field("TAG") == "T" {
+    rec.Type = "T"
+  } else {
+    rec.Type = "P"
+  }
+  if rec.Time, err = parseCSVDateTime(field("DATE"), field("TIME")); err != nil {
+    return fail("DATE/TIME", err)
+  }
+  if rec.Latitude, rec.South, err = parseCSVCoord(field("LATITUDE N/S"), 'N', 'S'); err != nil {
+    return fail("LATITUDE N/S", err)
+  }
+  if rec.Longitude, rec.West, err = parseCSVCoord(field("LONGITUDE E/W"), 'E', 'W'); err != nil {
+    return fail("LONGITUDE E/W", err)
+  }
+  if value := field("HEIGHT"); value != "" {
+    alt, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+      return fail("HEIGHT", err)
+    }
+    rec.Altitude = uint32(clampPositive(alt))
+  }
+  if value := field("SPEED"); value != "" {
+    if rec.Speed, err = strconv.ParseFloat(value, 64); err != nil {
+      return fail("SPEED", err)
+    }
+  }
+  if value := field("HEADING"); value != "" {
+    heading, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+      return fail("HEADING", err)
+    }
+    rec.Heading = uint16(clampPositive(heading))
+  }
+  if value := field("PRES"); value != "" {
+    if rec.Pressure, err = strconv.ParseFloat(value, 64); err != nil {
+      return fail("PRES", err)
+    }
+  }
+  if value := field("TEMP"); value != "" {
+    temp, err := strconv.ParseFloat(value, 64)
+    if err != nil {
+      return fail("TEMP", err)
+    }
+    // Record has no room for sub-zero temperatures, as in the binary format
+    rec.Temperature = uint16(clampPositive(temp))
+  }
+  return rec, nil
+}
+
+// cleanCSVField strips the whitespace and NUL padding the device leaves in
+// fixed width fields
+func cleanCSVField(value string) string {
+  return strings.Trim(value, " \t\x00")
+}
+
+func clampPositive(value float64) float64 {
+  if value < 0 {
+    return 0
+  }
+  return value
+}
+
+// parseCSVDateTime reads the device's YYMMDD and HHMMSS columns
+func parseCSVDateTime(date string, clock string) (Date, error) {
+  var d Date
+  if len(date) != 6 || len(clock) != 6 {
+    return d, fmt.Errorf("expected YYMMDD and HHMMSS, got %q and %q", date, clock)
+  }
+  parts := []*uint32{&d.Year, &d.Month, &d.Day, &d.Hour, &d.Minute, &d.Second}
+  digits := date + clock
+  for i, part := range parts {
+    value, err := strconv.ParseUint(digits[i * 2:i * 2 + 2], 10, 32)
+    if err != nil {
+      return d, fmt.Errorf("expected YYMMDD and HHMMSS, got %q and %q", date, clock)
+    }
+    *part = uint32(value)
+  }
+  d.Year += 2000
+  return d, nil
+}
+
+// parseCSVCoord reads a coordinate with either a hemisphere suffix or a sign,
+// reporting whether it lies in the negative hemisphere
+func parseCSVCoord(value string, positive byte, negative byte) (float64, bool, error) {
+  if value == "" {
+    return 0, false, fmt.Errorf("missing value")
+  }
+  neg := false
+  switch value[len(value) - 1] {
+  case positive:
+    value = value[:len(value) - 1]
+  case negative:
+    value = value[:len(value) - 1]
+    neg = true
+  }
+  coord, err := strconv.ParseFloat(value, 64)
+  if err != nil {
+    return 0, false, err
+  }
+  if neg {
+    coord = -coord
+  }
+  return coord, coord < 0, nil
+}
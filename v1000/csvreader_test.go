@@ -0,0 +1,94 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "io"
+  "strings"
+  "testing"
+)
+
+const testDeviceCSV = "INDEX,TAG,DATE,TIME,LATITUDE N/S,LONGITUDE E/W,HEIGHT,SPEED,HEADING,PRES,TEMP\r\n" +
+  "1\x00\x00,T,170401,123456,34.987654S,99.123456E,10,1.5,180,1000.2,20\r\n" +
+  "2,C,170401,123500,1.000000N,2.500000W,-3,0.0,0,999.0,4\r\n" +
+  "\x00\x00\x00\r\n"
+
+func Test_CSVReader(t *testing.T) {
+  t.Log("Checking whether CSVReader reads device CSV rows..")
+  r, err := NewCSVReader(strings.NewReader(testDeviceCSV))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  rec, err := r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := Record{
+    Index: 1,
+    Type: "T",
+    Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56},
+    Latitude: -34.987654,
+    South: true,
+    Longitude: 99.123456,
+    Altitude: 10,
+    Speed: 1.5,
+    Heading: 180,
+    Pressure: 1000.2,
+    Temperature: 20,
+  }
+  if rec != expected {
+    t.Errorf("Expected %+v, got %+v", expected, rec)
+  }
+  rec, err = r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if rec.Type != "P" || rec.Longitude != -2.5 || !rec.West || rec.Altitude != 0 {
+    t.Errorf("Unexpected second record %+v", rec)
+  }
+  if _, err = r.Read(); err != io.EOF {
+    t.Errorf("Expected io.EOF after padding, got %v", err)
+  }
+}
+
+func Test_CSVReaderErrors(t *testing.T) {
+  t.Log("Checking whether CSVReader reports malformed input..")
+  if _, err := NewCSVReader(strings.NewReader("INDEX,TAG\r\n")); err == nil {
+    t.Errorf("Expected an error for missing columns")
+  }
+  r, err := NewCSVReader(strings.NewReader(
+    "INDEX,TAG,DATE,TIME,LATITUDE N/S,LONGITUDE E/W\r\n1,T,1704,123456,1N,1E\r\n"))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if _, err := r.Read(); err == nil || !strings.Contains(err.Error(), "line 2") {
+    t.Errorf("Expected an error naming line 2, got %v", err)
+  }
+}
+
+func Test_parseCSVCoord(t *testing.T) {
+  t.Log("Checking whether parseCSVCoord() accepts suffixes and signs..")
+  cases := map[string]float64{"12.5N": 12.5, "12.5S": -12.5, "-12.5": -12.5, "12.5": 12.5}
+  for value, expected := range cases {
+    out, neg, err := parseCSVCoord(value, 'N', 'S')
+    if err != nil || out != expected || neg != (expected < 0) {
+      t.Errorf("Expected %f for %q, got %f (%v)", expected, value, out, err)
+    }
+  }
+}
@@ -0,0 +1,242 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Filter decides whether a record should be kept
+type Filter interface {
+  Match(rec *Record) bool
+}
+
+// FilterFunc adapts a plain function to the Filter interface
+type FilterFunc func(rec *Record) bool
+
+// Match ...
+func (f FilterFunc) Match(rec *Record) bool {
+  return f(rec)
+}
+
+// All matches a record only when every one of its filters does. An empty
+// All matches everything.
+type All []Filter
+
+// Match ...
+func (filters All) Match(rec *Record) bool {
+  for _, f := range filters {
+    if !f.Match(rec) {
+      return false
+    }
+  }
+  return true
+}
+
+// Any matches a record when at least one of its filters does
+type Any []Filter
+
+// Match ...
+func (filters Any) Match(rec *Record) bool {
+  for _, f := range filters {
+    if f.Match(rec) {
+      return true
+    }
+  }
+  return false
+}
+
+// Not inverts a filter
+func Not(f Filter) Filter {
+  return FilterFunc(func(rec *Record) bool {
+    return !f.Match(rec)
+  })
+}
+
+// TypeFilter matches records of a single type ("T" or "P")
+type TypeFilter string
+
+// Match ...
+func (t TypeFilter) Match(rec *Record) bool {
+  return rec.Type == string(t)
+}
+
+// TimeRange matches records stamped between From and To, inclusive. A zero
+// bound is left open. Record times are interpreted in Location, or UTC when
+// Location is nil.
+type TimeRange struct {
+  From time.Time
+  To time.Time
+  Location *time.Location
+}
+
+// Match ...
+func (r TimeRange) Match(rec *Record) bool {
+  loc := r.Location
+  if loc == nil {
+    loc = time.UTC
+  }
+  t := rec.Time.In(loc)
+  if !r.From.IsZero() && t.Before(r.From) {
+    return false
+  }
+  if !r.To.IsZero() && t.After(r.To) {
+    return false
+  }
+  return true
+}
+
+// BoundingBox matches records inside a longitude/latitude rectangle
+type BoundingBox struct {
+  MinLon float64
+  MinLat float64
+  MaxLon float64
+  MaxLat float64
+}
+
+// ParseBoundingBox reads a "minlon,minlat,maxlon,maxlat" string
+func ParseBoundingBox(value string) (BoundingBox, error) {
+  var box BoundingBox
+  parts := strings.Split(value, ",")
+  if len(parts) != 4 {
+    return box, fmt.Errorf("bounding box %q: expected minlon,minlat,maxlon,maxlat", value)
+  }
+  coords := make([]float64, 4)
+  for i, part := range parts {
+    f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+    if err != nil {
+      return box, fmt.Errorf("bounding box %q: %v", value, err)
+    }
+    coords[i] = f
+  }
+  box = BoundingBox{MinLon: coords[0], MinLat: coords[1], MaxLon: coords[2], MaxLat: coords[3]}
+  if box.MinLon > box.MaxLon || box.MinLat > box.MaxLat {
+    return box, fmt.Errorf("bounding box %q: minimum exceeds maximum", value)
+  }
+  return box, nil
+}
+
+// Match ...
+func (b BoundingBox) Match(rec *Record) bool {
+  return rec.Longitude >= b.MinLon && rec.Longitude <= b.MaxLon &&
+    rec.Latitude >= b.MinLat && rec.Latitude <= b.MaxLat
+}
+
+// Point is a longitude/latitude pair, in GeoJSON order
+type Point [2]float64
+
+// Polygon is an outer ring followed by any number of holes
+type Polygon [][]Point
+
+// Match ...
+func (p Polygon) Match(rec *Record) bool {
+  if len(p) == 0 || !insideRing(p[0], rec.Longitude, rec.Latitude) {
+    return false
+  }
+  for _, hole := range p[1:] {
+    if insideRing(hole, rec.Longitude, rec.Latitude) {
+      return false
+    }
+  }
+  return true
+}
+
+// insideRing is the usual even-odd ray casting test
+func insideRing(ring []Point, x float64, y float64) bool {
+  inside := false
+  for i, j := 0, len(ring) - 1; i < len(ring); j, i = i, i + 1 {
+    xi, yi := ring[i][0], ring[i][1]
+    xj, yj := ring[j][0], ring[j][1]
+    if (yi > y) != (yj > y) && x < (xj - xi) * (y - yi) / (yj - yi) + xi {
+      inside = !inside
+    }
+  }
+  return inside
+}
+
+type geoJSON struct {
+  Type string `json:"type"`
+  Coordinates json.RawMessage `json:"coordinates"`
+  Geometry *geoJSON `json:"geometry"`
+  Geometries []geoJSON `json:"geometries"`
+  Features []geoJSON `json:"features"`
+}
+
+// ReadGeoJSON collects every Polygon and MultiPolygon in a GeoJSON document.
+// The result matches records falling inside any of them.
+func ReadGeoJSON(r io.Reader) (Any, error) {
+  data, err := ioutil.ReadAll(r)
+  if err != nil {
+    return nil, err
+  }
+  var doc geoJSON
+  if err := json.Unmarshal(data, &doc); err != nil {
+    return nil, err
+  }
+  var polygons Any
+  if err := collectPolygons(&doc, &polygons); err != nil {
+    return nil, err
+  }
+  if len(polygons) == 0 {
+    return nil, fmt.Errorf("geojson: no Polygon or MultiPolygon found")
+  }
+  return polygons, nil
+}
+
+func collectPolygons(doc *geoJSON, polygons *Any) error {
+  switch doc.Type {
+  case "Polygon":
+    var p Polygon
+    if err := json.Unmarshal(doc.Coordinates, &p); err != nil {
+      return fmt.Errorf("geojson: bad Polygon: %v", err)
+    }
+    *polygons = append(*polygons, p)
+  case "MultiPolygon":
+    var mp []Polygon
+    if err := json.Unmarshal(doc.Coordinates, &mp); err != nil {
+      return fmt.Errorf("geojson: bad MultiPolygon: %v", err)
+    }
+    for _, p := range mp {
+      *polygons = append(*polygons, p)
+    }
+  case "Feature":
+    if doc.Geometry != nil {
+      return collectPolygons(doc.Geometry, polygons)
+    }
+  case "FeatureCollection":
+    for i := range doc.Features {
+      if err := collectPolygons(&doc.Features[i], polygons); err != nil {
+        return err
+      }
+    }
+  case "GeometryCollection":
+    for i := range doc.Geometries {
+      if err := collectPolygons(&doc.Geometries[i], polygons); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
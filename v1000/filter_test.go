@@ -0,0 +1,128 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func Test_TimeRange(t *testing.T) {
+  t.Log("Checking whether TimeRange honours both bounds..")
+  rec := Record{Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56}}
+  at := time.Date(2017, 4, 1, 12, 34, 56, 0, time.UTC)
+  cases := []struct {
+    rng TimeRange
+    expected bool
+  }{
+    {TimeRange{}, true},
+    {TimeRange{From: at}, true},
+    {TimeRange{To: at}, true},
+    {TimeRange{From: at.Add(time.Second)}, false},
+    {TimeRange{To: at.Add(-time.Second)}, false},
+    {TimeRange{From: at.Add(-time.Hour), To: at.Add(time.Hour)}, true},
+  }
+  for idx, c := range cases {
+    if out := c.rng.Match(&rec); out != c.expected {
+      t.Errorf("Case %d: expected %v, got %v", idx, c.expected, out)
+    }
+  }
+}
+
+func Test_TimeRangeLocation(t *testing.T) {
+  t.Log("Checking whether TimeRange applies the record time zone..")
+  loc := time.FixedZone("UTC+2", 2 * 60 * 60)
+  rec := Record{Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12}}
+  rng := TimeRange{To: time.Date(2017, 4, 1, 11, 0, 0, 0, time.UTC), Location: loc}
+  if !rng.Match(&rec) {
+    t.Errorf("Expected 12:00+02:00 to fall before 11:00Z")
+  }
+}
+
+func Test_ParseBoundingBox(t *testing.T) {
+  t.Log("Checking whether ParseBoundingBox() reads minlon,minlat,maxlon,maxlat..")
+  box, err := ParseBoundingBox("-1.5, 50, 2, 52.25")
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := BoundingBox{MinLon: -1.5, MinLat: 50, MaxLon: 2, MaxLat: 52.25}
+  if box != expected {
+    t.Errorf("Expected %v, got %v", expected, box)
+  }
+  for _, bad := range []string{"1,2,3", "a,b,c,d", "3,0,1,1"} {
+    if _, err := ParseBoundingBox(bad); err == nil {
+      t.Errorf("Expected an error for %q", bad)
+    }
+  }
+}
+
+func Test_BoundingBox(t *testing.T) {
+  t.Log("Checking whether BoundingBox matches points inside it..")
+  box := BoundingBox{MinLon: -1, MinLat: 50, MaxLon: 1, MaxLat: 51}
+  if !box.Match(&Record{Longitude: 0, Latitude: 50.5}) {
+    t.Errorf("Expected point inside the box to match")
+  }
+  if box.Match(&Record{Longitude: 2, Latitude: 50.5}) {
+    t.Errorf("Expected point outside the box not to match")
+  }
+}
+
+func Test_ReadGeoJSON(t *testing.T) {
+  t.Log("Checking whether ReadGeoJSON() honours polygon holes..")
+  doc := `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {
+    "type": "Polygon", "coordinates": [
+      [[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+      [[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]
+    ]}}]}`
+  within, err := ReadGeoJSON(strings.NewReader(doc))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if !within.Match(&Record{Longitude: 2, Latitude: 2}) {
+    t.Errorf("Expected point inside the polygon to match")
+  }
+  if within.Match(&Record{Longitude: 5, Latitude: 5}) {
+    t.Errorf("Expected point inside the hole not to match")
+  }
+  if within.Match(&Record{Longitude: 12, Latitude: 5}) {
+    t.Errorf("Expected point outside the polygon not to match")
+  }
+  if _, err := ReadGeoJSON(strings.NewReader(`{"type": "Point", "coordinates": [1, 2]}`)); err == nil {
+    t.Errorf("Expected an error for a document without polygons")
+  }
+}
+
+func Test_All(t *testing.T) {
+  t.Log("Checking whether All, Any and Not compose..")
+  rec := Record{Type: "P", Longitude: 0, Latitude: 0}
+  box := BoundingBox{MinLon: -1, MinLat: -1, MaxLon: 1, MaxLat: 1}
+  if !(All{}).Match(&rec) {
+    t.Errorf("Expected an empty All to match")
+  }
+  if (All{box, TypeFilter("T")}).Match(&rec) {
+    t.Errorf("Expected All to require every filter")
+  }
+  if !(Any{box, TypeFilter("T")}).Match(&rec) {
+    t.Errorf("Expected Any to require a single filter")
+  }
+  if !Not(TypeFilter("T")).Match(&rec) {
+    t.Errorf("Expected Not to invert the filter")
+  }
+}
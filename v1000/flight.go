@@ -0,0 +1,85 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+  "time"
+)
+
+// FlightDetector finds where a flight starts and ends in a track log. A
+// record counts as airborne when the ground speed or the rate of climb or
+// sink is above its threshold; takeoff and landing are where that has been
+// true for at least MinDuration.
+type FlightDetector struct {
+  MinSpeed float64 // km/h
+  MinClimb float64 // m/s, either up or down
+  MinDuration time.Duration
+  Location *time.Location
+}
+
+// DefaultFlightDetector suits paragliders and hang gliders
+var DefaultFlightDetector = FlightDetector{
+  MinSpeed: 10,
+  MinClimb: 1,
+  MinDuration: 30 * time.Second,
+}
+
+// Detect returns the half-open range [start, end) of recs from takeoff to
+// landing, or false if no flight was found
+func (d FlightDetector) Detect(recs []Record) (int, int, bool) {
+  loc := d.Location
+  if loc == nil {
+    loc = time.UTC
+  }
+  airborne := make([]bool, len(recs))
+  for i := range recs {
+    airborne[i] = recs[i].Speed >= d.MinSpeed
+    if i > 0 && !airborne[i] {
+      dt := recs[i].Time.In(loc).Sub(recs[i - 1].Time.In(loc)).Seconds()
+      climb := float64(recs[i].Altitude) - float64(recs[i - 1].Altitude)
+      airborne[i] = dt > 0 && math.Abs(climb / dt) >= d.MinClimb
+    }
+  }
+
+  // runs longer than MinDuration are flight; the first starts at takeoff and
+  // the last finishes at landing
+  start, end := -1, -1
+  for i := 0; i < len(recs); {
+    if !airborne[i] {
+      i++
+      continue
+    }
+    j := i
+    for j + 1 < len(recs) && airborne[j + 1] {
+      j++
+    }
+    if recs[j].Time.In(loc).Sub(recs[i].Time.In(loc)) >= d.MinDuration {
+      if start < 0 {
+        start = i
+      }
+      end = j + 1
+    }
+    i = j + 1
+  }
+  if start < 0 {
+    return 0, 0, false
+  }
+  return start, end, true
+}
@@ -0,0 +1,66 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "testing"
+  "time"
+)
+
+// flightTrack builds one record a second with the given speeds
+func flightTrack(speeds []float64) []Record {
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  recs := make([]Record, len(speeds))
+  for i, speed := range speeds {
+    recs[i] = Record{Index: uint32(i), Type: "T", Speed: speed, Altitude: 500,
+      Time: DateOf(start.Add(time.Duration(i) * time.Second))}
+  }
+  return recs
+}
+
+func Test_FlightDetectorDetect(t *testing.T) {
+  t.Log("Checking whether Detect() finds takeoff and landing..")
+  d := FlightDetector{MinSpeed: 10, MinClimb: 1, MinDuration: 3 * time.Second}
+  // a short burst on the ground, then a flight with a slow patch in it
+  speeds := []float64{0, 12, 12, 0, 0, 20, 25, 30, 35, 5, 30, 25, 20, 15, 0, 0}
+  start, end, ok := d.Detect(flightTrack(speeds))
+  if !ok || start != 5 || end != 14 {
+    t.Errorf("Expected flight [5, 14), got [%d, %d) %v", start, end, ok)
+  }
+}
+
+func Test_FlightDetectorClimb(t *testing.T) {
+  t.Log("Checking whether Detect() counts climbing as flying..")
+  d := FlightDetector{MinSpeed: 10, MinClimb: 1, MinDuration: 2 * time.Second}
+  recs := flightTrack([]float64{0, 0, 0, 0, 0})
+  for i := range recs {
+    recs[i].Altitude = uint32(500 + 2 * i)
+  }
+  start, end, ok := d.Detect(recs)
+  if !ok || start != 1 || end != 5 {
+    t.Errorf("Expected flight [1, 5), got [%d, %d) %v", start, end, ok)
+  }
+}
+
+func Test_FlightDetectorGround(t *testing.T) {
+  t.Log("Checking whether Detect() finds nothing in a ground track..")
+  if _, _, ok := DefaultFlightDetector.Detect(flightTrack([]float64{0, 3, 4, 0})); ok {
+    t.Errorf("Expected no flight")
+  }
+}
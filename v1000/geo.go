@@ -0,0 +1,43 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+)
+
+// EarthRadius is the mean radius of the earth in metres
+const EarthRadius = 6371008.8
+
+// Distance returns the great circle distance between two records in metres
+func Distance(a *Record, b *Record) float64 {
+  return Haversine(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+}
+
+// Haversine returns the great circle distance between two coordinates in
+// metres
+func Haversine(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+  phi1 := lat1 * math.Pi / 180
+  phi2 := lat2 * math.Pi / 180
+  dphi := (lat2 - lat1) * math.Pi / 180
+  dlambda := (lon2 - lon1) * math.Pi / 180
+  h := math.Sin(dphi / 2) * math.Sin(dphi / 2) +
+    math.Cos(phi1) * math.Cos(phi2) * math.Sin(dlambda / 2) * math.Sin(dlambda / 2)
+  return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,40 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+  "testing"
+)
+
+func Test_Haversine(t *testing.T) {
+  t.Log("Checking whether Haversine() measures one degree of latitude..")
+  expected := 111195.08
+  if out := Haversine(0, 0, 1, 0); math.Abs(out - expected) > 0.5 {
+    t.Errorf("Expected %.2f, but got %.2f", expected, out)
+  }
+}
+
+func Test_Distance(t *testing.T) {
+  t.Log("Checking whether Distance() is zero between identical records..")
+  a := Record{Latitude: 51.5, Longitude: -0.12}
+  if out := Distance(&a, &a); out != 0 {
+    t.Errorf("Expected 0, but got %f", out)
+  }
+}
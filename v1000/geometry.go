@@ -0,0 +1,210 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bytes"
+  "encoding/binary"
+  "math"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// EncodePolyline encodes the positions of recs with Google's encoded
+// polyline algorithm, to precision decimal places (5 for Google Maps, 6 for
+// OSRM and Valhalla)
+func EncodePolyline(recs []Record, precision int) string {
+  factor := math.Pow(10, float64(precision))
+  var out []byte
+  var lat, lon int64
+  for i := range recs {
+    nextLat := int64(math.Round(recs[i].Latitude * factor))
+    nextLon := int64(math.Round(recs[i].Longitude * factor))
+    out = appendPolylineValue(out, nextLat - lat)
+    out = appendPolylineValue(out, nextLon - lon)
+    lat, lon = nextLat, nextLon
+  }
+  return string(out)
+}
+
+func appendPolylineValue(out []byte, v int64) []byte {
+  u := uint64(v) << 1
+  if v < 0 {
+    u = ^u
+  }
+  for u >= 0x20 {
+    out = append(out, byte(0x20 | u & 0x1f) + 63)
+    u >>= 5
+  }
+  return append(out, byte(u) + 63)
+}
+
+// WKT writes segments as a LINESTRING ZM, or a MULTILINESTRING ZM when there
+// is more than one, with altitude as Z and Unix time as M. Segments of a
+// single point are not lines, and are left out.
+func WKT(segments [][]Record, loc *time.Location) string {
+  segments = lineSegments(segments)
+  var b strings.Builder
+  if len(segments) > 1 {
+    b.WriteString("MULTILINESTRING ZM (")
+    for i, segment := range segments {
+      if i > 0 {
+        b.WriteString(", ")
+      }
+      writeWKTPoints(&b, segment, loc)
+    }
+    b.WriteString(")")
+    return b.String()
+  }
+  b.WriteString("LINESTRING ZM ")
+  if len(segments) == 0 || len(segments[0]) == 0 {
+    b.WriteString("EMPTY")
+    return b.String()
+  }
+  writeWKTPoints(&b, segments[0], loc)
+  return b.String()
+}
+
+func writeWKTPoints(b *strings.Builder, recs []Record, loc *time.Location) {
+  b.WriteString("(")
+  for i := range recs {
+    if i > 0 {
+      b.WriteString(", ")
+    }
+    x, y, z, m := geometryPoint(&recs[i], loc)
+    for j, v := range []float64{x, y, z, m} {
+      if j > 0 {
+        b.WriteString(" ")
+      }
+      b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+    }
+  }
+  b.WriteString(")")
+}
+
+// WKB geometry types
+const (
+  wkbPoint = 1
+  wkbPointZM = 3001
+  wkbLineStringZM = 3002
+  wkbMultiLineStringZM = 3005
+  ewkbLineString = 2
+  ewkbMultiLineString = 5
+  ewkbZ = 0x80000000
+  ewkbM = 0x40000000
+  ewkbSRID = 0x20000000
+)
+
+// WKB encodes segments as little endian ISO WKB, with the same geometry as
+// WKT
+func WKB(segments [][]Record, loc *time.Location) []byte {
+  segments = lineSegments(segments)
+  var b bytes.Buffer
+  if len(segments) > 1 {
+    writeWKBHeader(&b, wkbMultiLineStringZM)
+    binary.Write(&b, binary.LittleEndian, uint32(len(segments)))
+    for _, segment := range segments {
+      writeWKBHeader(&b, wkbLineStringZM)
+      writeWKBPoints(&b, segment, loc)
+    }
+    return b.Bytes()
+  }
+  writeWKBHeader(&b, wkbLineStringZM)
+  writeWKBPoints(&b, firstSegment(segments), loc)
+  return b.Bytes()
+}
+
+// EWKB encodes segments as little endian PostGIS extended WKB, which carries
+// the SRID
+func EWKB(segments [][]Record, loc *time.Location, srid uint32) []byte {
+  segments = lineSegments(segments)
+  var b bytes.Buffer
+  if len(segments) > 1 {
+    writeWKBHeader(&b, ewkbMultiLineString | ewkbZ | ewkbM | ewkbSRID)
+    binary.Write(&b, binary.LittleEndian, srid)
+    binary.Write(&b, binary.LittleEndian, uint32(len(segments)))
+    for _, segment := range segments {
+      writeWKBHeader(&b, ewkbLineString | ewkbZ | ewkbM)
+      writeWKBPoints(&b, segment, loc)
+    }
+    return b.Bytes()
+  }
+  writeWKBHeader(&b, ewkbLineString | ewkbZ | ewkbM | ewkbSRID)
+  binary.Write(&b, binary.LittleEndian, srid)
+  writeWKBPoints(&b, firstSegment(segments), loc)
+  return b.Bytes()
+}
+
+// PointWKB encodes a record as a little endian ISO WKB POINT ZM
+func PointWKB(rec *Record, loc *time.Location) []byte {
+  var b bytes.Buffer
+  writeWKBHeader(&b, wkbPointZM)
+  x, y, z, m := geometryPoint(rec, loc)
+  binary.Write(&b, binary.LittleEndian, [4]float64{x, y, z, m})
+  return b.Bytes()
+}
+
+// PointXYWKB encodes a record's position as a little endian WKB POINT, with
+// neither altitude nor time
+func PointXYWKB(rec *Record) []byte {
+  var b bytes.Buffer
+  writeWKBHeader(&b, wkbPoint)
+  binary.Write(&b, binary.LittleEndian, [2]float64{rec.Longitude, rec.Latitude})
+  return b.Bytes()
+}
+
+// lineSegments leaves out the segments with fewer than two points
+func lineSegments(segments [][]Record) [][]Record {
+  var lines [][]Record
+  for _, segment := range segments {
+    if len(segment) >= 2 {
+      lines = append(lines, segment)
+    }
+  }
+  return lines
+}
+
+func firstSegment(segments [][]Record) []Record {
+  if len(segments) == 0 {
+    return nil
+  }
+  return segments[0]
+}
+
+func writeWKBHeader(b *bytes.Buffer, geometryType uint32) {
+  b.WriteByte(1) // little endian
+  binary.Write(b, binary.LittleEndian, geometryType)
+}
+
+func writeWKBPoints(b *bytes.Buffer, recs []Record, loc *time.Location) {
+  binary.Write(b, binary.LittleEndian, uint32(len(recs)))
+  for i := range recs {
+    x, y, z, m := geometryPoint(&recs[i], loc)
+    binary.Write(b, binary.LittleEndian, [4]float64{x, y, z, m})
+  }
+}
+
+// geometryPoint gives a record's longitude, latitude, altitude and Unix time
+func geometryPoint(rec *Record, loc *time.Location) (x, y, z, m float64) {
+  if loc == nil {
+    loc = time.UTC
+  }
+  return rec.Longitude, rec.Latitude, float64(rec.Altitude), float64(rec.Time.In(loc).Unix())
+}
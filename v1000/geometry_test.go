@@ -0,0 +1,99 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "encoding/hex"
+  "testing"
+  "time"
+)
+
+func geometryRecords() []Record {
+  at := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  return []Record{
+    {Latitude: 51.5, Longitude: -0.1, Altitude: 10, Time: DateOf(at)},
+    {Latitude: 51.25, Longitude: 0.5, Altitude: 20, Time: DateOf(at.Add(time.Second))},
+  }
+}
+
+func Test_EncodePolyline(t *testing.T) {
+  expected := "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+  t.Logf("Checking whether EncodePolyline() matches Google's example.. (expected: %s)", expected)
+  recs := []Record{
+    {Latitude: 38.5, Longitude: -120.2},
+    {Latitude: 40.7, Longitude: -120.95},
+    {Latitude: 43.252, Longitude: -126.453},
+  }
+  if out := EncodePolyline(recs, 5); out != expected {
+    t.Errorf("Expected %s, got %s", expected, out)
+  }
+  if out := EncodePolyline(recs[:1], 6); out != "_izlhA~rlgdF" {
+    t.Errorf("Expected _izlhA~rlgdF at precision 6, got %s", out)
+  }
+}
+
+func Test_WKT(t *testing.T) {
+  t.Log("Checking whether WKT() writes LINESTRING ZM and MULTILINESTRING ZM..")
+  recs := geometryRecords()
+  expected := "LINESTRING ZM (-0.1 51.5 10 1491048000, 0.5 51.25 20 1491048001)"
+  if out := WKT([][]Record{recs}, time.UTC); out != expected {
+    t.Errorf("Expected %s, got %s", expected, out)
+  }
+  if out := WKT([][]Record{recs[:1], recs}, time.UTC); out != expected {
+    t.Errorf("Expected a single point segment to be left out, got %s", out)
+  }
+  expected = "MULTILINESTRING ZM ((-0.1 51.5 10 1491048000, 0.5 51.25 20 1491048001), " +
+    "(-0.1 51.5 10 1491048000, 0.5 51.25 20 1491048001))"
+  if out := WKT([][]Record{recs, recs}, time.UTC); out != expected {
+    t.Errorf("Expected %s, got %s", expected, out)
+  }
+  for _, segments := range [][][]Record{nil, {recs[:1]}} {
+    if out := WKT(segments, time.UTC); out != "LINESTRING ZM EMPTY" {
+      t.Errorf("Expected LINESTRING ZM EMPTY, got %s", out)
+    }
+  }
+}
+
+func Test_WKB(t *testing.T) {
+  t.Log("Checking whether WKB() and EWKB() write the geometry headers..")
+  recs := geometryRecords()
+  out := hex.EncodeToString(WKB([][]Record{recs}, time.UTC))
+  // little endian, type 3002, 2 points, then x = -0.1
+  if expected := "01ba0b000002000000" + "9a9999999999b9bf"; out[:len(expected)] != expected {
+    t.Errorf("Expected WKB to start %s, got %s", expected, out)
+  }
+  if len(out) != 2 * (1 + 4 + 4 + 2 * 32) {
+    t.Errorf("Expected %d bytes, got %d", 1 + 4 + 4 + 2 * 32, len(out) / 2)
+  }
+  if out := WKB([][]Record{recs[:1]}, time.UTC); len(out) != 1 + 4 + 4 {
+    t.Errorf("Expected a single point segment to give an empty LINESTRING, got %x", out)
+  }
+  out = hex.EncodeToString(EWKB([][]Record{recs, recs[:1], recs}, time.UTC, 4326))
+  // type 5 with Z, M and SRID flags, SRID 4326, 2 lines, then a line with Z and M
+  if expected := "01050000e0e61000000200000001020000c002000000"; out[:len(expected)] != expected {
+    t.Errorf("Expected EWKB to start %s, got %s", expected, out)
+  }
+  if out := hex.EncodeToString(PointWKB(&recs[0], time.UTC)); out[:10] != "01b90b0000" || len(out) != 2 * 37 {
+    t.Errorf("Expected a 37 byte POINT ZM, got %s", out)
+  }
+  expected := "0101000000" + "000000000000f03f" + "0000000000000040"
+  if out := hex.EncodeToString(PointXYWKB(&Record{Longitude: 1, Latitude: 2})); out != expected {
+    t.Errorf("Expected the POINT %s, got %s", expected, out)
+  }
+}
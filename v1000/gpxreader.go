@@ -0,0 +1,111 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "encoding/xml"
+  "fmt"
+  "io"
+  "time"
+)
+
+// GPXReader reads track points, route points and waypoints from a GPX file,
+// such as those the device writes when logging in GPX mode. Waypoints become
+// POI records. GPX carries no pressure or temperature, so those are zero.
+type GPXReader struct {
+  dec *xml.Decoder
+  loc *time.Location
+  index uint32
+}
+
+// gpxPoint is the subset of a GPX wptType that maps onto a Record
+type gpxPoint struct {
+  Latitude float64 `xml:"lat,attr"`
+  Longitude float64 `xml:"lon,attr"`
+  Elevation *float64 `xml:"ele"`
+  Time string `xml:"time"`
+  Course *float64 `xml:"course"`
+  Speed *float64 `xml:"speed"`
+}
+
+// NewGPXReader returns a reader that converts GPX timestamps, which are UTC,
+// into local record times in loc
+func NewGPXReader(r io.Reader, loc *time.Location) *GPXReader {
+  if loc == nil {
+    loc = time.UTC
+  }
+  return &GPXReader{dec: xml.NewDecoder(r), loc: loc}
+}
+
+// Read returns the next point, or io.EOF at the end of the file
+func (g *GPXReader) Read() (Record, error) {
+  for {
+    tok, err := g.dec.Token()
+    if err != nil {
+      return Record{}, err
+    }
+    start, ok := tok.(xml.StartElement)
+    if !ok {
+      continue
+    }
+    var typ string
+    switch start.Name.Local {
+    case "trkpt", "rtept":
+      typ = "T"
+    case "wpt":
+      typ = "P"
+    default:
+      continue
+    }
+    var pt gpxPoint
+    if err := g.dec.DecodeElement(&pt, &start); err != nil {
+      return Record{}, fmt.Errorf("gpx: %v", err)
+    }
+    g.index++
+    return g.toRecord(&pt, typ)
+  }
+}
+
+func (g *GPXReader) toRecord(pt *gpxPoint, typ string) (Record, error) {
+  rec := Record{
+    Index: g.index,
+    Type: typ,
+    Latitude: pt.Latitude,
+    South: pt.Latitude < 0,
+    Longitude: pt.Longitude,
+    West: pt.Longitude < 0,
+  }
+  if pt.Elevation != nil {
+    rec.Altitude = uint32(clampPositive(*pt.Elevation))
+  }
+  if pt.Course != nil {
+    rec.Heading = uint16(clampPositive(*pt.Course))
+  }
+  if pt.Speed != nil {
+    rec.Speed = *pt.Speed
+  }
+  if pt.Time != "" {
+    t, err := time.Parse(time.RFC3339, pt.Time)
+    if err != nil {
+      return rec, fmt.Errorf("gpx: point %d: %v", g.index, err)
+    }
+    rec.Time = DateOf(t.In(g.loc))
+  }
+  return rec, nil
+}
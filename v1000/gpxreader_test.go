@@ -0,0 +1,79 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "io"
+  "strings"
+  "testing"
+  "time"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="1.5" lon="-2.5"><ele>12</ele><time>2017-04-01T10:00:00Z</time><name>POI</name></wpt>
+  <trk><name>track</name><trkseg>
+    <trkpt lat="-34.987654" lon="99.123456"><ele>10</ele><time>2017-04-01T10:34:56Z</time><course>180</course><speed>1.5</speed></trkpt>
+    <trkpt lat="-34.9" lon="99.2"></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func Test_GPXReader(t *testing.T) {
+  t.Log("Checking whether GPXReader reads waypoints and track points..")
+  r := NewGPXReader(strings.NewReader(testGPX), time.FixedZone("UTC+2", 2 * 60 * 60))
+  wpt, err := r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if wpt.Type != "P" || wpt.Index != 1 || wpt.Latitude != 1.5 || !wpt.West || wpt.Altitude != 12 {
+    t.Errorf("Unexpected waypoint %+v", wpt)
+  }
+  rec, err := r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := Record{
+    Index: 2,
+    Type: "T",
+    Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56},
+    Latitude: -34.987654,
+    South: true,
+    Longitude: 99.123456,
+    Altitude: 10,
+    Speed: 1.5,
+    Heading: 180,
+  }
+  if rec != expected {
+    t.Errorf("Expected %+v, got %+v", expected, rec)
+  }
+  if rec, err = r.Read(); err != nil || rec.Index != 3 {
+    t.Errorf("Expected a bare third point, got %+v (%v)", rec, err)
+  }
+  if _, err = r.Read(); err != io.EOF {
+    t.Errorf("Expected io.EOF, got %v", err)
+  }
+}
+
+func Test_GPXReaderBadTime(t *testing.T) {
+  t.Log("Checking whether GPXReader rejects malformed timestamps..")
+  r := NewGPXReader(strings.NewReader(`<gpx><trk><trkseg><trkpt lat="1" lon="1"><time>noon</time></trkpt></trkseg></trk></gpx>`), nil)
+  if _, err := r.Read(); err == nil {
+    t.Errorf("Expected an error")
+  }
+}
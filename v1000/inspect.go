@@ -0,0 +1,123 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bytes"
+  "encoding/binary"
+  "io"
+  "io/ioutil"
+  "time"
+)
+
+// Layout of the binary format: a two byte header, then fixed size records
+const (
+  HeaderSize = 2
+  RecordSize = 28
+  Magic = 1799
+)
+
+// IndexGap is a break in the sequence of record indexes
+type IndexGap struct {
+  Record int // the position of the record after the break, from 0
+  Prev uint32 // the index before the break
+  Next uint32 // the index after it
+}
+
+// Summary describes the contents of a file
+type Summary struct {
+  Format Format
+  Size int64 // bytes, after any decompression
+
+  // binary files only
+  Magic uint16
+  ExpectedRecords int64 // as many whole records as the size allows
+  TrailingBytes int64 // bytes after the last whole record
+
+  Records int
+  Trackpoints int
+  POIs int
+  MinIndex uint32
+  MaxIndex uint32
+  Gaps []IndexGap
+  Stats Stats // of every record, in file order
+}
+
+// Inspect reads a whole file and summarizes it, in the given format or else
+// detecting it. Binary files are decoded whatever their header, so that
+// files with a damaged header can be looked at.
+func Inspect(r io.Reader, format Format, loc *time.Location) (Summary, error) {
+  var s Summary
+  data, err := ioutil.ReadAll(r)
+  if err != nil {
+    return s, err
+  }
+  s.Size = int64(len(data))
+  if format == FormatUnknown {
+    format = DetectFormat(data)
+  }
+  s.Format = format
+
+  var src Source
+  if format == FormatBinary {
+    if len(data) < HeaderSize {
+      s.TrailingBytes = s.Size
+      return s, nil
+    }
+    s.Magic = binary.BigEndian.Uint16(data)
+    body := s.Size - HeaderSize
+    s.ExpectedRecords = body / RecordSize
+    s.TrailingBytes = body % RecordSize
+    src = &BinaryReader{r: bytes.NewReader(data[HeaderSize:s.Size - s.TrailingBytes])}
+  } else if src, err = OpenSource(bytes.NewReader(data), format, loc); err != nil {
+    return s, err
+  }
+
+  var recs []Record
+  for {
+    rec, err := src.Read()
+    if err == io.EOF {
+      break
+    }
+    if err != nil {
+      return s, err
+    }
+    recs = append(recs, rec)
+  }
+
+  s.Records = len(recs)
+  for i, rec := range recs {
+    if rec.Type == "P" {
+      s.POIs++
+    } else {
+      s.Trackpoints++
+    }
+    if i == 0 || rec.Index < s.MinIndex {
+      s.MinIndex = rec.Index
+    }
+    if rec.Index > s.MaxIndex {
+      s.MaxIndex = rec.Index
+    }
+    if i > 0 && rec.Index != recs[i - 1].Index + 1 {
+      s.Gaps = append(s.Gaps, IndexGap{Record: i, Prev: recs[i - 1].Index, Next: rec.Index})
+    }
+  }
+  s.Stats = Summarize(recs, loc)
+  return s, nil
+}
@@ -0,0 +1,73 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bytes"
+  "testing"
+  "time"
+)
+
+// inspectBinary is testBinary's record as indexes 42, 43 and 50, with a
+// partial fourth record
+func inspectBinary() []byte {
+  data := append([]byte{}, testBinary...)
+  for _, index := range []byte{43, 50} {
+    rec := append([]byte{}, testBinary[HeaderSize:]...)
+    rec[2] = index
+    data = append(data, rec...)
+  }
+  return append(data, 1, 2, 3, 4, 5)
+}
+
+func Test_Inspect(t *testing.T) {
+  t.Log("Checking whether Inspect() summarizes a binary file..")
+  s, err := Inspect(bytes.NewReader(inspectBinary()), FormatUnknown, time.UTC)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if s.Format != FormatBinary || s.Size != 2 + 3 * 28 + 5 || s.Magic != Magic {
+    t.Errorf("Expected a binary file of 91 bytes with a good header, got %+v", s)
+  }
+  if s.ExpectedRecords != 3 || s.TrailingBytes != 5 || s.Records != 3 || s.POIs != 3 || s.Trackpoints != 0 {
+    t.Errorf("Expected 3 POIs and 5 trailing bytes, got %+v", s)
+  }
+  if s.MinIndex != 42 || s.MaxIndex != 50 {
+    t.Errorf("Expected indexes 42 to 50, got %d to %d", s.MinIndex, s.MaxIndex)
+  }
+  if len(s.Gaps) != 1 || s.Gaps[0] != (IndexGap{Record: 2, Prev: 43, Next: 50}) {
+    t.Errorf("Expected a gap from 43 to 50, got %+v", s.Gaps)
+  }
+}
+
+func Test_InspectBadHeader(t *testing.T) {
+  t.Log("Checking whether Inspect() reads binary records past a bad header..")
+  data := inspectBinary()
+  data[0], data[1] = 0x12, 0x34
+  s, err := Inspect(bytes.NewReader(data), FormatBinary, time.UTC)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if s.Magic != 0x1234 || s.Records != 3 {
+    t.Errorf("Expected magic 0x1234 and 3 records, got %#04x and %d", s.Magic, s.Records)
+  }
+  if s, _ := Inspect(bytes.NewReader([]byte{7}), FormatBinary, time.UTC); s.TrailingBytes != 1 || s.Records != 0 {
+    t.Errorf("Expected a 1 byte file to be all trailing bytes, got %+v", s)
+  }
+}
@@ -0,0 +1,201 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "fmt"
+  "math"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Line is a timing line, such as a start/finish or sector line, between two
+// points
+type Line struct {
+  A, B Point
+}
+
+// ParseLine parses lon1,lat1,lon2,lat2
+func ParseLine(value string) (Line, error) {
+  var line Line
+  parts := strings.Split(value, ",")
+  if len(parts) != 4 {
+    return line, fmt.Errorf("line %q: expected lon1,lat1,lon2,lat2", value)
+  }
+  coords := make([]float64, 4)
+  for i, part := range parts {
+    f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+    if err != nil {
+      return line, fmt.Errorf("line %q: %v", value, err)
+    }
+    coords[i] = f
+  }
+  line = Line{A: Point{coords[0], coords[1]}, B: Point{coords[2], coords[3]}}
+  if line.A == line.B {
+    return line, fmt.Errorf("line %q: the two points are the same", value)
+  }
+  return line, nil
+}
+
+// Crossing is a timing line being crossed between records Index and Index+1
+type Crossing struct {
+  Line int // 0 for start/finish, n for sector line n
+  Index int
+  Time time.Time // interpolated between the two records
+}
+
+// Lap is a completed lap, from one start/finish crossing to the next
+type Lap struct {
+  Number int
+  Start time.Time
+  End time.Time
+  StartIndex int // record before the opening crossing
+  EndIndex int // record before the closing crossing
+  Sectors []time.Duration // nil unless every sector line was crossed in order
+}
+
+// Duration is the lap time
+func (l Lap) Duration() time.Duration {
+  return l.End.Sub(l.Start)
+}
+
+// LapTimer splits a track into laps at a start/finish line, with optional
+// sector lines to be crossed in order within each lap
+type LapTimer struct {
+  Finish Line
+  Sectors []Line
+  MinLap time.Duration // ignore start/finish crossings sooner than this
+  Location *time.Location
+}
+
+// Crossings finds every crossing of the timing lines in recs, in order
+func (lt LapTimer) Crossings(recs []Record) []Crossing {
+  loc := lt.Location
+  if loc == nil {
+    loc = time.UTC
+  }
+  lines := append([]Line{lt.Finish}, lt.Sectors...)
+  var crossings []Crossing
+  for i := 0; i + 1 < len(recs); i++ {
+    p, q := &recs[i], &recs[i + 1]
+    tp, tq := p.Time.In(loc), q.Time.In(loc)
+    start := len(crossings)
+    for n, line := range lines {
+      if f, ok := line.intersect(p, q); ok {
+        at := tp.Add(time.Duration(math.Round(f * float64(tq.Sub(tp)))))
+        crossings = append(crossings, Crossing{Line: n, Index: i, Time: at})
+      }
+    }
+    // lines crossed within one step are taken in the order they were crossed
+    step := crossings[start:]
+    sort.SliceStable(step, func(a, b int) bool { return step[a].Time.Before(step[b].Time) })
+  }
+  return crossings
+}
+
+// Laps returns the completed laps in recs
+func (lt LapTimer) Laps(recs []Record) []Lap {
+  var laps []Lap
+  var current *Lap
+  var splits []time.Time
+  for _, c := range lt.Crossings(recs) {
+    if c.Line != 0 {
+      if current != nil && c.Line == len(splits) + 1 {
+        splits = append(splits, c.Time)
+      }
+      continue
+    }
+    if current != nil {
+      if c.Time.Sub(current.Start) < lt.MinLap {
+        continue
+      }
+      current.End = c.Time
+      current.EndIndex = c.Index
+      if len(splits) == len(lt.Sectors) {
+        marks := append(append([]time.Time{current.Start}, splits...), c.Time)
+        for k := 1; k < len(marks); k++ {
+          current.Sectors = append(current.Sectors, marks[k].Sub(marks[k - 1]))
+        }
+      }
+      laps = append(laps, *current)
+    }
+    current = &Lap{Number: len(laps) + 1, Start: c.Time, StartIndex: c.Index}
+    splits = nil
+  }
+  return laps
+}
+
+// intersect reports whether the step from p to q crosses the line, and how
+// far along the step it does so. Coordinates are projected onto a plane
+// about p, which is plenty accurate over the length of a timing line.
+func (line Line) intersect(p, q *Record) (float64, bool) {
+  scale := math.Cos(p.Latitude * math.Pi / 180)
+  px, py := p.Longitude * scale, p.Latitude
+  rx, ry := q.Longitude * scale - px, q.Latitude - py
+  ax, ay := line.A[0] * scale, line.A[1]
+  sx, sy := line.B[0] * scale - ax, line.B[1] - ay
+  denom := rx * sy - ry * sx
+  if denom == 0 {
+    return 0, false
+  }
+  dx, dy := ax - px, ay - py
+  t := (dx * sy - dy * sx) / denom
+  u := (dx * ry - dy * rx) / denom
+  // a crossing exactly on q belongs to the next step
+  if t < 0 || t >= 1 || u < 0 || u > 1 {
+    return 0, false
+  }
+  return t, true
+}
+
+// LapSummary picks out the best lap and sectors
+type LapSummary struct {
+  Best int // index of the fastest lap, or -1 if there are none
+  BestSectors []time.Duration // fastest time for each sector, from complete laps
+  TheoreticalBest time.Duration // sum of BestSectors
+}
+
+// SummarizeLaps finds the best lap, the best time for each sector and the
+// theoretical best lap they add up to
+func SummarizeLaps(laps []Lap) LapSummary {
+  s := LapSummary{Best: -1}
+  for i, lap := range laps {
+    if s.Best < 0 || lap.Duration() < laps[s.Best].Duration() {
+      s.Best = i
+    }
+    if lap.Sectors == nil {
+      continue
+    }
+    if s.BestSectors == nil {
+      s.BestSectors = append([]time.Duration(nil), lap.Sectors...)
+      continue
+    }
+    for k, d := range lap.Sectors {
+      if d < s.BestSectors[k] {
+        s.BestSectors[k] = d
+      }
+    }
+  }
+  for _, d := range s.BestSectors {
+    s.TheoreticalBest += d
+  }
+  return s
+}
@@ -0,0 +1,119 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+  "testing"
+  "time"
+)
+
+// circuit drives anticlockwise round a circle centred on 51.5N 0E, one
+// record per 6 degrees starting 3 degrees past due east. step returns the
+// time taken from record k to record k+1.
+func circuit(n int, step func(k int) time.Duration) []Record {
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  recs := make([]Record, n)
+  for k := range recs {
+    a := (3 + 6 * float64(k)) * math.Pi / 180
+    recs[k] = Record{Type: "T", Latitude: 51.5 + 0.001 * math.Sin(a), Longitude: 0.001 * math.Cos(a),
+      Time: DateOf(start)}
+    start = start.Add(step(k))
+  }
+  return recs
+}
+
+func Test_ParseLine(t *testing.T) {
+  t.Log("Checking whether ParseLine() reads lon1,lat1,lon2,lat2..")
+  line, err := ParseLine("0.0005, 51.5, 0.0015, 51.5")
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if line.A != (Point{0.0005, 51.5}) || line.B != (Point{0.0015, 51.5}) {
+    t.Errorf("Expected (0.0005,51.5)-(0.0015,51.5), got %v", line)
+  }
+  for _, bad := range []string{"1,2,3", "a,b,c,d", "1,2,1,2"} {
+    if _, err := ParseLine(bad); err == nil {
+      t.Errorf("Expected an error for %q", bad)
+    }
+  }
+}
+
+func Test_LapTimer(t *testing.T) {
+  t.Log("Checking whether LapTimer times laps and sectors from interpolated crossings..")
+  lt := LapTimer{
+    Finish: Line{Point{0.0005, 51.5}, Point{0.0015, 51.5}},
+    Sectors: []Line{{Point{0, 51.5005}, Point{0, 51.5015}}},
+    MinLap: 10 * time.Second,
+  }
+  // lap 1 takes 2s per record through the first sector, lap 2 through the
+  // second and lap 3 through part of the second
+  recs := circuit(250, func(k int) time.Duration {
+    switch {
+    case k >= 60 && k < 75, k >= 135 && k < 179, k >= 200 && k < 210:
+      return 2 * time.Second
+    }
+    return time.Second
+  })
+  laps := lt.Laps(recs)
+  if len(laps) != 3 {
+    t.Fatalf("Expected 3 laps, got %d", len(laps))
+  }
+  expected := [][]time.Duration{
+    {29500 * time.Millisecond, 45500 * time.Millisecond},
+    {15 * time.Second, 89 * time.Second},
+    {15 * time.Second, 55 * time.Second},
+  }
+  for i, lap := range laps {
+    if lap.Number != i + 1 || len(lap.Sectors) != 2 {
+      t.Fatalf("Expected lap %d with 2 sectors, got %+v", i + 1, lap)
+    }
+    for k, d := range expected[i] {
+      if diff := lap.Sectors[k] - d; diff < -time.Millisecond || diff > time.Millisecond {
+        t.Errorf("Expected lap %d sector %d to take %v, got %v", i + 1, k + 1, d, lap.Sectors[k])
+      }
+    }
+  }
+  if laps[0].StartIndex != 59 || laps[0].EndIndex != 119 {
+    t.Errorf("Expected lap 1 between records 59 and 119, got %d and %d", laps[0].StartIndex, laps[0].EndIndex)
+  }
+
+  s := SummarizeLaps(laps)
+  if s.Best != 2 {
+    t.Errorf("Expected lap 3 to be best, got lap %d", s.Best + 1)
+  }
+  if diff := s.TheoreticalBest - 60500 * time.Millisecond; diff < -time.Millisecond || diff > time.Millisecond {
+    t.Errorf("Expected a theoretical best of 60.5s, got %v", s.TheoreticalBest)
+  }
+}
+
+func Test_LapTimer_missedSector(t *testing.T) {
+  t.Log("Checking whether a lap that misses a sector line has no splits..")
+  lt := LapTimer{
+    Finish: Line{Point{0.0005, 51.5}, Point{0.0015, 51.5}},
+    Sectors: []Line{{Point{0.0005, 51.6}, Point{0.0015, 51.6}}},
+  }
+  laps := lt.Laps(circuit(190, func(k int) time.Duration { return time.Second }))
+  if len(laps) != 2 || laps[0].Sectors != nil {
+    t.Fatalf("Expected 2 laps without sectors, got %+v", laps)
+  }
+  if s := SummarizeLaps(laps); s.Best != 0 || s.TheoreticalBest != 0 {
+    t.Errorf("Expected lap 1 best and no theoretical best, got %+v", s)
+  }
+}
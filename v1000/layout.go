@@ -0,0 +1,90 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+// Offsets of the fields within a binary record
+const (
+  offsetIndex = 0
+  offsetFlags = 3
+  offsetTime = 4
+  offsetLatitude = 8
+  offsetLongitude = 12
+  offsetAltitude = 16
+  offsetSpeed = 20
+  offsetHeading = 22
+  offsetPressure = 24
+  offsetTemperature = 26
+)
+
+// Field is a big endian field of a binary record
+type Field struct {
+  Name string
+  Offset int
+  Size int
+}
+
+// RecordLayout lists the fields of a binary record, in order
+var RecordLayout = []Field{
+  {"index", offsetIndex, 3},
+  {"flags", offsetFlags, 1},
+  {"time", offsetTime, 4},
+  {"latitude", offsetLatitude, 4},
+  {"longitude", offsetLongitude, 4},
+  {"altitude", offsetAltitude, 4},
+  {"speed", offsetSpeed, 2},
+  {"heading", offsetHeading, 2},
+  {"pressure", offsetPressure, 2},
+  {"temperature", offsetTemperature, 2},
+}
+
+// Bytes returns the field's bytes within a record
+func (f Field) Bytes(rec []byte) []byte {
+  return rec[f.Offset:f.Offset + f.Size]
+}
+
+// Raw returns the field's unsigned value within a record
+func (f Field) Raw(rec []byte) uint32 {
+  var value uint32
+  for _, b := range f.Bytes(rec) {
+    value = value << 8 | uint32(b)
+  }
+  return value
+}
+
+// DateBits describes a component of the packed timestamp
+type DateBits struct {
+  Name string
+  Shift uint
+  Width uint
+}
+
+// DateLayout lists the components of the timestamp, from the high bits
+var DateLayout = []DateBits{
+  {"year", 26, 6},
+  {"month", 22, 4},
+  {"day", 17, 5},
+  {"hour", 12, 5},
+  {"minute", 6, 6},
+  {"second", 0, 6},
+}
+
+// Value extracts the component from a timestamp
+func (d DateBits) Value(date uint32) uint32 {
+  return date >> d.Shift & (1 << d.Width - 1)
+}
@@ -0,0 +1,60 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "testing"
+)
+
+func Test_RecordLayout(t *testing.T) {
+  t.Log("Checking whether RecordLayout covers a record and reads raw values..")
+  next := 0
+  for _, f := range RecordLayout {
+    if f.Offset != next {
+      t.Errorf("Expected %s at %d, got %d", f.Name, next, f.Offset)
+    }
+    next = f.Offset + f.Size
+  }
+  if next != RecordSize {
+    t.Errorf("Expected the fields to end at %d, got %d", RecordSize, next)
+  }
+  rec := testBinary[HeaderSize:]
+  expected := []uint32{42, 0x0d, 84068536, 34985110, 99126080, 100, 15, 180, 10000, 200}
+  for i, f := range RecordLayout {
+    if raw := f.Raw(rec); raw != expected[i] {
+      t.Errorf("Expected %s %d, got %d", f.Name, expected[i], raw)
+    }
+  }
+}
+
+func Test_DateLayout(t *testing.T) {
+  t.Log("Checking whether DateLayout agrees with parseV1000Date()..")
+  date := parseV1000Date(84068536)
+  expected := []uint32{date.Year - 2016, date.Month, date.Day, date.Hour, date.Minute, date.Second}
+  width := uint(0)
+  for i, d := range DateLayout {
+    if value := d.Value(84068536); value != expected[i] {
+      t.Errorf("Expected %s %d, got %d", d.Name, expected[i], value)
+    }
+    width += d.Width
+  }
+  if width != 32 {
+    t.Errorf("Expected 32 bits, got %d", width)
+  }
+}
@@ -0,0 +1,297 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bufio"
+  "io"
+  "math"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// knotsToKmh converts knots to kilometres per hour
+const knotsToKmh = 1.852
+
+// NMEAReader reads NMEA 0183 logs from other receivers. Sentences sharing a
+// fix time are merged into one record: RMC supplies the date, position,
+// speed and course, GGA the altitude, VTG the speed and course, and the
+// optional WIMDA and PGRMZ sentences the pressure and temperature.
+// Sentences with a bad checksum are skipped.
+type NMEAReader struct {
+  scanner *bufio.Scanner
+  loc *time.Location
+  index uint32
+  pending *nmeaFix
+  date Date
+}
+
+// nmeaFix is a record being assembled from the sentences of one fix
+type nmeaFix struct {
+  rec Record
+  clock string
+  date Date
+  hasPosition bool
+}
+
+// NMEAChecksum is the XOR of every byte between the $ and the *
+func NMEAChecksum(body string) byte {
+  var sum byte
+  for i := 0; i < len(body); i++ {
+    sum ^= body[i]
+  }
+  return sum
+}
+
+// NewNMEAReader returns a reader that converts NMEA times, which are UTC,
+// into local record times in loc
+func NewNMEAReader(r io.Reader, loc *time.Location) *NMEAReader {
+  if loc == nil {
+    loc = time.UTC
+  }
+  return &NMEAReader{scanner: bufio.NewScanner(r), loc: loc}
+}
+
+// Read returns the next fix, or io.EOF at the end of the log
+func (n *NMEAReader) Read() (Record, error) {
+  for n.scanner.Scan() {
+    fields, ok := splitNMEA(n.scanner.Text())
+    if !ok || len(fields[0]) < 3 {
+      continue
+    }
+    var done *nmeaFix
+    switch fields[0][len(fields[0]) - 3:] {
+    case "RMC":
+      done = n.rmc(fields)
+    case "GGA":
+      done = n.gga(fields)
+    case "VTG":
+      n.vtg(fields)
+    case "MDA":
+      n.mda(fields)
+    case "RMZ":
+      n.rmz(fields)
+    }
+    if done != nil {
+      return n.finish(done), nil
+    }
+  }
+  if err := n.scanner.Err(); err != nil {
+    return Record{}, err
+  }
+  if n.pending != nil {
+    done := n.pending
+    n.pending = nil
+    if done.hasPosition {
+      return n.finish(done), nil
+    }
+  }
+  return Record{}, io.EOF
+}
+
+// splitNMEA verifies the checksum, when present, and splits a sentence into
+// its comma separated fields
+func splitNMEA(line string) ([]string, bool) {
+  line = strings.TrimSpace(line)
+  start := strings.IndexByte(line, '$')
+  if start < 0 {
+    return nil, false
+  }
+  body := line[start + 1:]
+  if star := strings.LastIndexByte(body, '*'); star >= 0 {
+    sum, err := strconv.ParseUint(body[star + 1:], 16, 8)
+    if err != nil || byte(sum) != NMEAChecksum(body[:star]) {
+      return nil, false
+    }
+    body = body[:star]
+  }
+  return strings.Split(body, ","), true
+}
+
+// fix returns the record for the given time of day, starting a new one and
+// handing back the previous one once the time changes
+func (n *NMEAReader) fix(clock string) (*nmeaFix, *nmeaFix) {
+  var done *nmeaFix
+  if n.pending != nil && n.pending.clock != clock {
+    if n.pending.hasPosition {
+      done = n.pending
+    }
+    n.pending = nil
+  }
+  if n.pending == nil {
+    n.pending = &nmeaFix{clock: clock, date: n.date, rec: Record{Type: "T"}}
+  }
+  return n.pending, done
+}
+
+func (n *NMEAReader) rmc(fields []string) *nmeaFix {
+  // $xxRMC,time,status,lat,N,lon,E,knots,course,ddmmyy,...
+  if len(fields) < 10 || fields[2] != "A" {
+    return nil
+  }
+  f, done := n.fix(fields[1])
+  if date, ok := parseNMEADate(fields[9]); ok {
+    n.date = date
+    f.date = date
+  }
+  if lat, lon, ok := parseNMEAPosition(fields[3:7]); ok {
+    f.rec.Latitude, f.rec.Longitude = lat, lon
+    f.hasPosition = true
+  }
+  if knots, err := strconv.ParseFloat(fields[7], 64); err == nil {
+    f.rec.Speed = knots * knotsToKmh
+  }
+  if course, err := strconv.ParseFloat(fields[8], 64); err == nil {
+    f.rec.Heading = uint16(math.Mod(math.Round(course), 360))
+  }
+  return done
+}
+
+func (n *NMEAReader) gga(fields []string) *nmeaFix {
+  // $xxGGA,time,lat,N,lon,E,quality,sats,hdop,alt,M,...
+  if len(fields) < 10 || fields[6] == "" || fields[6] == "0" {
+    return nil
+  }
+  f, done := n.fix(fields[1])
+  if lat, lon, ok := parseNMEAPosition(fields[2:6]); ok {
+    f.rec.Latitude, f.rec.Longitude = lat, lon
+    f.hasPosition = true
+  }
+  if alt, err := strconv.ParseFloat(fields[9], 64); err == nil {
+    f.rec.Altitude = uint32(clampPositive(math.Round(alt)))
+  }
+  return done
+}
+
+func (n *NMEAReader) vtg(fields []string) {
+  // $xxVTG,course,T,course,M,knots,N,kmh,K,...
+  if n.pending == nil || len(fields) < 8 {
+    return
+  }
+  if course, err := strconv.ParseFloat(fields[1], 64); err == nil {
+    n.pending.rec.Heading = uint16(math.Mod(math.Round(course), 360))
+  }
+  if kmh, err := strconv.ParseFloat(fields[7], 64); err == nil {
+    n.pending.rec.Speed = kmh
+  }
+}
+
+func (n *NMEAReader) mda(fields []string) {
+  // $WIMDA,inHg,I,bar,B,air,C,water,C,...
+  if n.pending == nil || len(fields) < 6 {
+    return
+  }
+  if bar, err := strconv.ParseFloat(fields[3], 64); err == nil {
+    n.pending.rec.Pressure = math.Round(bar * 10000) / 10
+  }
+  if temp, err := strconv.ParseFloat(fields[5], 64); err == nil {
+    n.pending.rec.Temperature = uint16(clampPositive(math.Round(temp)))
+  }
+}
+
+func (n *NMEAReader) rmz(fields []string) {
+  // $PGRMZ,altitude,f,fix; only used when no WIMDA pressure was seen
+  if n.pending == nil || len(fields) < 3 || n.pending.rec.Pressure != 0 {
+    return
+  }
+  alt, err := strconv.ParseFloat(fields[1], 64)
+  if err != nil {
+    return
+  }
+  if fields[2] == "f" {
+    alt *= 0.3048
+  }
+  n.pending.rec.Pressure = math.Round(AltitudePressure(alt) * 10) / 10
+}
+
+// finish stamps a completed fix with its index and local time
+func (n *NMEAReader) finish(f *nmeaFix) Record {
+  n.index++
+  rec := f.rec
+  rec.Index = n.index
+  rec.South = rec.Latitude < 0
+  rec.West = rec.Longitude < 0
+  date := f.date
+  if h, m, s, ok := parseNMEATime(f.clock); ok {
+    date.Hour, date.Minute, date.Second = h, m, s
+  }
+  if date.Year != 0 {
+    date = DateOf(date.In(time.UTC).In(n.loc))
+  }
+  rec.Time = date
+  return rec
+}
+
+// parseNMEAPosition reads a ddmm.mmmm,N,dddmm.mmmm,E quadruple
+func parseNMEAPosition(fields []string) (float64, float64, bool) {
+  lat, ok := parseNMEACoord(fields[0], 2)
+  if !ok {
+    return 0, 0, false
+  }
+  lon, ok := parseNMEACoord(fields[2], 3)
+  if !ok {
+    return 0, 0, false
+  }
+  if fields[1] == "S" {
+    lat = -lat
+  }
+  if fields[3] == "W" {
+    lon = -lon
+  }
+  return lat, lon, true
+}
+
+func parseNMEACoord(value string, degreeDigits int) (float64, bool) {
+  if len(value) < degreeDigits + 2 {
+    return 0, false
+  }
+  deg, err := strconv.ParseFloat(value[:degreeDigits], 64)
+  if err != nil {
+    return 0, false
+  }
+  min, err := strconv.ParseFloat(value[degreeDigits:], 64)
+  if err != nil {
+    return 0, false
+  }
+  return deg + min / 60, true
+}
+
+func parseNMEATime(value string) (uint32, uint32, uint32, bool) {
+  if len(value) < 6 {
+    return 0, 0, 0, false
+  }
+  var parts [3]uint32
+  for i := range parts {
+    v, err := strconv.ParseUint(value[i * 2:i * 2 + 2], 10, 32)
+    if err != nil {
+      return 0, 0, 0, false
+    }
+    parts[i] = uint32(v)
+  }
+  return parts[0], parts[1], parts[2], true
+}
+
+func parseNMEADate(value string) (Date, bool) {
+  day, month, year, ok := parseNMEATime(value)
+  if !ok {
+    return Date{}, false
+  }
+  return Date{Year: 2000 + year, Month: month, Day: day}, true
+}
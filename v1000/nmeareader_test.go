@@ -0,0 +1,85 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "io"
+  "math"
+  "strings"
+  "testing"
+  "time"
+)
+
+const testNMEA = "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47\r\n" +
+  "$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,010417,003.1,W*66\r\n" +
+  "$WIMDA,29.53,I,1.0000,B,12.5,C,,C,,,,C,,T,,M,,N,,M*20\r\n" +
+  "$GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*00\r\n" +
+  "garbage\r\n" +
+  "$GPGGA,123521,4807.100,S,01131.000,W,1,08,0.9,12.0,M,46.9,M,,*7A\r\n"
+
+func Test_NMEAReader(t *testing.T) {
+  t.Log("Checking whether NMEAReader merges the sentences of each fix..")
+  r := NewNMEAReader(strings.NewReader(testNMEA), time.FixedZone("UTC+1", 60 * 60))
+  rec, err := r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if rec.Index != 1 || rec.Type != "T" || rec.Altitude != 545 || rec.Heading != 84 || rec.Temperature != 13 {
+    t.Errorf("Unexpected first record %+v", rec)
+  }
+  expected := Date{Year: 2017, Month: 4, Day: 1, Hour: 13, Minute: 35, Second: 19}
+  if rec.Time != expected {
+    t.Errorf("Expected time %+v, got %+v", expected, rec.Time)
+  }
+  if math.Abs(rec.Latitude - 48.1173) > 1e-6 || math.Abs(rec.Longitude - 11.516667) > 1e-6 {
+    t.Errorf("Unexpected position %f, %f", rec.Latitude, rec.Longitude)
+  }
+  if math.Abs(rec.Speed - 22.4 * 1.852) > 1e-9 || rec.Pressure != 1000 {
+    t.Errorf("Unexpected speed %f or pressure %f", rec.Speed, rec.Pressure)
+  }
+  // the second GGA has a bad checksum and is skipped
+  rec, err = r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if rec.Index != 2 || !rec.South || !rec.West || rec.Altitude != 12 || rec.Time.Second != 21 {
+    t.Errorf("Unexpected second record %+v", rec)
+  }
+  if _, err := r.Read(); err != io.EOF {
+    t.Errorf("Expected io.EOF, got %v", err)
+  }
+}
+
+func Test_NMEAChecksum(t *testing.T) {
+  t.Log("Checking whether NMEAChecksum() XORs the sentence body..")
+  body := "GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,"
+  if out := NMEAChecksum(body); out != 0x47 {
+    t.Errorf("Expected 0x47, got %#x", out)
+  }
+}
+
+func Test_parseNMEACoord(t *testing.T) {
+  t.Log("Checking whether parseNMEACoord() reads degrees and minutes..")
+  if out, ok := parseNMEACoord("01131.000", 3); !ok || math.Abs(out - 11.516667) > 1e-6 {
+    t.Errorf("Expected 11.516667, got %f", out)
+  }
+  if _, ok := parseNMEACoord("", 2); ok {
+    t.Errorf("Expected an empty coordinate to fail")
+  }
+}
@@ -19,7 +19,9 @@
 package v1000
 
 import (
-  "os"
+  "io"
+  "time"
+  "errors"
   "encoding/binary"
 )
 
@@ -49,8 +51,26 @@ type Date struct {
   Second uint32
 }
 
+// In converts the date to a time.Time in the given location
+func (date Date) In(loc *time.Location) time.Time {
+  return time.Date(int(date.Year), time.Month(date.Month), int(date.Day),
+    int(date.Hour), int(date.Minute), int(date.Second), 0, loc)
+}
+
+// DateOf converts a time.Time to a Date in the time's own location
+func DateOf(t time.Time) Date {
+  return Date{
+    Year: uint32(t.Year()),
+    Month: uint32(t.Month()),
+    Day: uint32(t.Day()),
+    Hour: uint32(t.Hour()),
+    Minute: uint32(t.Minute()),
+    Second: uint32(t.Second()),
+  }
+}
+
 // CheckHeader ...
-func CheckHeader(file *os.File) (bool, error) {
+func CheckHeader(file io.Reader) (bool, error) {
   hdr, err := parseShort(file)
   if err != nil {
     return false, err
@@ -58,8 +78,34 @@ func CheckHeader(file *os.File) (bool, error) {
   return hdr == 1799, nil
 }
 
+// ErrBadHeader is returned when a file does not start with the V1000 magic
+var ErrBadHeader = errors.New("not a V1000 binary file (bad header)")
+
+// BinaryReader reads records from the device's native binary format
+type BinaryReader struct {
+  r io.Reader
+}
+
+// NewBinaryReader checks the file header and returns a reader positioned on
+// the first record
+func NewBinaryReader(r io.Reader) (*BinaryReader, error) {
+  ok, err := CheckHeader(r)
+  if err != nil {
+    return nil, err
+  }
+  if !ok {
+    return nil, ErrBadHeader
+  }
+  return &BinaryReader{r: r}, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the file
+func (b *BinaryReader) Read() (Record, error) {
+  return ParseRecord(b.r)
+}
+
 // ParseRecord ...
-func ParseRecord(file *os.File) (Record, error) {
+func ParseRecord(file io.Reader) (Record, error) {
   var rec Record
 
   err := parseIndex(file, &rec)
@@ -101,7 +147,7 @@ func ParseRecord(file *os.File) (Record, error) {
   return rec, nil
 }
 
-func parseShort(file *os.File) (uint16, error) {
+func parseShort(file io.Reader) (uint16, error) {
   var value uint16
   err := binary.Read(file, binary.BigEndian, &value)
   if err != nil {
@@ -110,7 +156,7 @@ func parseShort(file *os.File) (uint16, error) {
   return value, nil
 }
 
-func parseLong(file *os.File) (uint32, error) {
+func parseLong(file io.Reader) (uint32, error) {
   var value uint32
   err := binary.Read(file, binary.BigEndian, &value)
   if err != nil {
@@ -119,9 +165,9 @@ func parseLong(file *os.File) (uint32, error) {
   return value, nil
 }
 
-func parseIndex(file *os.File, rec *Record) (error) {
+func parseIndex(file io.Reader, rec *Record) (error) {
   data := make([]byte, 3)
-  _, err := file.Read(data)
+  _, err := io.ReadFull(file, data)
   if err != nil {
     return err
   }
@@ -129,7 +175,7 @@ func parseIndex(file *os.File, rec *Record) (error) {
   return nil
 }
 
-func parseTypeAndDir(file *os.File, rec *Record) (error) {
+func parseTypeAndDir(file io.Reader, rec *Record) (error) {
   var blob byte
   err := binary.Read(file, binary.BigEndian, &blob)
   if err != nil {
@@ -145,7 +191,7 @@ func parseTypeAndDir(file *os.File, rec *Record) (error) {
   return nil
 }
 
-func parseTime(file *os.File, rec *Record) (error) {
+func parseTime(file io.Reader, rec *Record) (error) {
   value, err := parseLong(file)
   if err != nil {
     return err
@@ -154,7 +200,7 @@ func parseTime(file *os.File, rec *Record) (error) {
   return nil
 }
 
-func parseCoords(file *os.File, rec *Record) (error) {
+func parseCoords(file io.Reader, rec *Record) (error) {
   value, err := parseLong(file)
   if err != nil {
     return err
@@ -174,7 +220,7 @@ func parseCoords(file *os.File, rec *Record) (error) {
   return nil
 }
 
-func parseAltitude(file *os.File, rec *Record) error {
+func parseAltitude(file io.Reader, rec *Record) error {
   value, err := parseLong(file)
   if err != nil {
     return err
@@ -183,7 +229,7 @@ func parseAltitude(file *os.File, rec *Record) error {
   return nil
 }
 
-func parseSpeed(file *os.File, rec *Record) error {
+func parseSpeed(file io.Reader, rec *Record) error {
   value, err := parseShort(file)
   if err != nil {
     return err
@@ -192,7 +238,7 @@ func parseSpeed(file *os.File, rec *Record) error {
   return nil
 }
 
-func parseHeading(file *os.File, rec *Record) error {
+func parseHeading(file io.Reader, rec *Record) error {
   value, err := parseShort(file)
   if err != nil {
     return err
@@ -201,7 +247,7 @@ func parseHeading(file *os.File, rec *Record) error {
   return nil
 }
 
-func parsePressure(file *os.File, rec *Record) error {
+func parsePressure(file io.Reader, rec *Record) error {
   value, err := parseShort(file)
   if err != nil {
     return err
@@ -210,7 +256,7 @@ func parsePressure(file *os.File, rec *Record) error {
   return nil
 }
 
-func parseTemperature(file *os.File, rec *Record) error {
+func parseTemperature(file io.Reader, rec *Record) error {
   value, err := parseShort(file)
   if err != nil {
     return err
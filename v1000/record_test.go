@@ -19,6 +19,8 @@
 package v1000
 
 import (
+  "bytes"
+  "io"
   "testing"
 )
 
@@ -74,3 +76,56 @@ func Test_shift(t *testing.T) {
     t.Errorf("Expected %d, but got %d", expected, out)
   }
 }
+
+// testBinary is a header followed by a single southern, western POI record
+var testBinary = []byte{
+  0x07, 0x07,
+  0x00, 0x00, 0x2a, 0x0d,
+  0x05, 0x02, 0xc8, 0xb8,
+  0x02, 0x15, 0xd4, 0x96,
+  0x05, 0xe8, 0x8b, 0x40,
+  0x00, 0x00, 0x00, 0x64,
+  0x00, 0x0f,
+  0x00, 0xb4,
+  0x27, 0x10,
+  0x00, 0xc8,
+}
+
+func Test_BinaryReader(t *testing.T) {
+  t.Log("Checking whether BinaryReader decodes a record..")
+  r, err := NewBinaryReader(bytes.NewReader(testBinary))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  rec, err := r.Read()
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := Record{
+    Index: 42,
+    Type: "P",
+    Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 34, Second: 56},
+    Latitude: -34.985110,
+    South: true,
+    Longitude: -99.126080,
+    West: true,
+    Altitude: 10,
+    Speed: 1.5,
+    Heading: 180,
+    Pressure: 1000,
+    Temperature: 20,
+  }
+  if rec != expected {
+    t.Errorf("Expected %+v, got %+v", expected, rec)
+  }
+  if _, err := r.Read(); err != io.EOF {
+    t.Errorf("Expected io.EOF, got %v", err)
+  }
+}
+
+func Test_NewBinaryReaderBadHeader(t *testing.T) {
+  t.Log("Checking whether NewBinaryReader() rejects a bad header..")
+  if _, err := NewBinaryReader(bytes.NewReader([]byte{0x12, 0x34})); err != ErrBadHeader {
+    t.Errorf("Expected ErrBadHeader, got %v", err)
+  }
+}
@@ -0,0 +1,44 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "time"
+)
+
+// SplitSegments splits a track wherever consecutive records are more than
+// gap apart, as happens when the logger is switched off or loses its fix. A
+// zero gap never splits. The segments share recs' backing array.
+func SplitSegments(recs []Record, gap time.Duration, loc *time.Location) [][]Record {
+  if len(recs) == 0 {
+    return nil
+  }
+  if loc == nil {
+    loc = time.UTC
+  }
+  var segments [][]Record
+  start := 0
+  for i := 1; i < len(recs); i++ {
+    if gap > 0 && recs[i].Time.In(loc).Sub(recs[i - 1].Time.In(loc)) > gap {
+      segments = append(segments, recs[start:i])
+      start = i
+    }
+  }
+  return append(segments, recs[start:])
+}
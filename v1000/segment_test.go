@@ -0,0 +1,44 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "testing"
+  "time"
+)
+
+func Test_SplitSegments(t *testing.T) {
+  t.Log("Checking whether SplitSegments() splits at time gaps..")
+  start := time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC)
+  offsets := []int{0, 1, 2, 100, 101, 500}
+  recs := make([]Record, len(offsets))
+  for i, offset := range offsets {
+    recs[i] = Record{Index: uint32(i), Time: DateOf(start.Add(time.Duration(offset) * time.Second))}
+  }
+  segments := SplitSegments(recs, time.Minute, nil)
+  if len(segments) != 3 || len(segments[0]) != 3 || len(segments[1]) != 2 || len(segments[2]) != 1 {
+    t.Errorf("Expected segments of 3, 2 and 1 records, got %v", segments)
+  }
+  if segments := SplitSegments(recs, 0, nil); len(segments) != 1 {
+    t.Errorf("Expected a zero gap not to split, got %d segments", len(segments))
+  }
+  if segments := SplitSegments(nil, time.Minute, nil); segments != nil {
+    t.Errorf("Expected no segments for no records, got %v", segments)
+  }
+}
@@ -0,0 +1,119 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bufio"
+  "bytes"
+  "fmt"
+  "io"
+  "strings"
+  "time"
+)
+
+// Source produces records one at a time, returning io.EOF when exhausted.
+// Each of the readers in this package is a Source.
+type Source interface {
+  Read() (Record, error)
+}
+
+// Format names an input file format
+type Format string
+
+// Supported input formats
+const (
+  FormatUnknown Format = ""
+  FormatBinary Format = "binary"
+  FormatCSV Format = "csv"
+  FormatGPX Format = "gpx"
+  FormatNMEA Format = "nmea"
+)
+
+// Formats lists every named input format
+var Formats = []Format{FormatBinary, FormatCSV, FormatGPX, FormatNMEA}
+
+// sniffLength is how much of the input DetectFormat needs to see
+const sniffLength = 512
+
+// ParseFormat checks a format name, as given on the command line. An empty
+// name means the format should be detected.
+func ParseFormat(name string) (Format, error) {
+  name = strings.ToLower(name)
+  if name == "" || name == "auto" {
+    return FormatUnknown, nil
+  }
+  for _, f := range Formats {
+    if string(f) == name {
+      return f, nil
+    }
+  }
+  return FormatUnknown, fmt.Errorf("unknown input format %q", name)
+}
+
+// DetectFormat guesses the format of a file from its first few hundred bytes
+func DetectFormat(head []byte) Format {
+  if len(head) >= 2 && head[0] == 0x07 && head[1] == 0x07 {
+    return FormatBinary
+  }
+  text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n\x00")
+  switch {
+  case bytes.HasPrefix(text, []byte("INDEX")):
+    return FormatCSV
+  case bytes.HasPrefix(text, []byte("<gpx")):
+    return FormatGPX
+  case bytes.HasPrefix(text, []byte("<?xml")) && bytes.Contains(text, []byte("<gpx")):
+    return FormatGPX
+  case bytes.HasPrefix(text, []byte("$GP")) || bytes.HasPrefix(text, []byte("$GN")):
+    return FormatNMEA
+  }
+  return FormatUnknown
+}
+
+// OpenSource returns a Source reading r in the given format, detecting the
+// format from the content when it is FormatUnknown. GPX and NMEA, which use
+// UTC timestamps, are converted into local record times in loc.
+func OpenSource(r io.Reader, format Format, loc *time.Location) (Source, error) {
+  buf := bufio.NewReader(r)
+  if format == FormatUnknown {
+    head, err := buf.Peek(sniffLength)
+    if err != nil && err != io.EOF {
+      return nil, err
+    }
+    format = DetectFormat(head)
+  }
+  switch format {
+  case FormatBinary:
+    return NewBinaryReader(buf)
+  case FormatCSV:
+    return NewCSVReader(buf)
+  case FormatGPX:
+    return NewGPXReader(buf, loc), nil
+  case FormatNMEA:
+    return NewNMEAReader(buf, loc), nil
+  }
+  return nil, fmt.Errorf("unrecognised input format (expected one of %s)", formatNames())
+}
+
+func formatNames() string {
+  names := make([]string, len(Formats))
+  for i, f := range Formats {
+    names[i] = string(f)
+  }
+  return strings.Join(names, ", ")
+}
@@ -0,0 +1,83 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+)
+
+func Test_DetectFormat(t *testing.T) {
+  t.Log("Checking whether DetectFormat() recognises each format..")
+  cases := map[string]Format{
+    "\x07\x07\x00\x00\x01": FormatBinary,
+    "\xef\xbb\xbfINDEX,TAG,DATE": FormatCSV,
+    "\r\n<?xml version=\"1.0\"?>\n<gpx version=\"1.1\">": FormatGPX,
+    "<gpx>": FormatGPX,
+    "<?xml version=\"1.0\"?><kml>": FormatUnknown,
+    "$GPGGA,123456.00,3459.2592,S": FormatNMEA,
+    "$GNRMC,123456.00,A": FormatNMEA,
+    "hello": FormatUnknown,
+    "": FormatUnknown,
+  }
+  for input, expected := range cases {
+    if out := DetectFormat([]byte(input)); out != expected {
+      t.Errorf("Expected %q for %q, got %q", expected, input, out)
+    }
+  }
+}
+
+func Test_ParseFormat(t *testing.T) {
+  t.Log("Checking whether ParseFormat() accepts format names..")
+  for _, f := range Formats {
+    if out, err := ParseFormat(strings.ToUpper(string(f))); err != nil || out != f {
+      t.Errorf("Expected %q, got %q (%v)", f, out, err)
+    }
+  }
+  if out, err := ParseFormat("auto"); err != nil || out != FormatUnknown {
+    t.Errorf("Expected auto to mean detection, got %q (%v)", out, err)
+  }
+  if _, err := ParseFormat("kml"); err == nil {
+    t.Errorf("Expected an error for an unknown format")
+  }
+}
+
+func Test_OpenSource(t *testing.T) {
+  t.Log("Checking whether OpenSource() detects or obeys the format..")
+  src, err := OpenSource(strings.NewReader(testDeviceCSV), FormatUnknown, nil)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if out := fmt.Sprintf("%T", src); out != "*v1000.CSVReader" {
+    t.Errorf("Expected a CSVReader, got %s", out)
+  }
+  // a GPX document without an XML declaration or leading <gpx> element
+  gpx := "<!-- exported --><gpx><wpt lat=\"1\" lon=\"2\"/></gpx>"
+  if _, err := OpenSource(strings.NewReader(gpx), FormatUnknown, nil); err == nil {
+    t.Errorf("Expected detection to fail")
+  }
+  src, err = OpenSource(strings.NewReader(gpx), FormatGPX, nil)
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  if rec, err := src.Read(); err != nil || rec.Longitude != 2 {
+    t.Errorf("Expected the waypoint, got %+v (%v)", rec, err)
+  }
+}
@@ -0,0 +1,98 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+  "time"
+)
+
+// Stats summarises a run of records, such as a track segment
+type Stats struct {
+  Count int
+  Start time.Time
+  End time.Time
+  Distance float64 // metres
+  MaxSpeed float64 // km/h
+  MinAltitude float64 // metres
+  MaxAltitude float64
+  Ascent float64 // metres climbed
+  Descent float64 // metres descended
+  MinTemperature float64 // degrees C
+  MaxTemperature float64
+  AvgTemperature float64
+  Bounds BoundingBox
+}
+
+// Summarize computes the statistics of recs, taken in order
+func Summarize(recs []Record, loc *time.Location) Stats {
+  var s Stats
+  if len(recs) == 0 {
+    return s
+  }
+  if loc == nil {
+    loc = time.UTC
+  }
+  s.Count = len(recs)
+  s.Start = recs[0].Time.In(loc)
+  s.End = recs[len(recs) - 1].Time.In(loc)
+  s.MinAltitude, s.MaxAltitude = math.Inf(1), math.Inf(-1)
+  s.MinTemperature, s.MaxTemperature = math.Inf(1), math.Inf(-1)
+  s.Bounds = BoundingBox{MinLon: 181, MinLat: 91, MaxLon: -181, MaxLat: -91}
+  var temperatures float64
+  for i := range recs {
+    rec := &recs[i]
+    if i > 0 {
+      prev := &recs[i - 1]
+      s.Distance += Distance(prev, rec)
+      climb := float64(rec.Altitude) - float64(prev.Altitude)
+      if climb > 0 {
+        s.Ascent += climb
+      } else {
+        s.Descent -= climb
+      }
+    }
+    s.MaxSpeed = math.Max(s.MaxSpeed, rec.Speed)
+    s.MinAltitude = math.Min(s.MinAltitude, float64(rec.Altitude))
+    s.MaxAltitude = math.Max(s.MaxAltitude, float64(rec.Altitude))
+    s.MinTemperature = math.Min(s.MinTemperature, float64(rec.Temperature))
+    s.MaxTemperature = math.Max(s.MaxTemperature, float64(rec.Temperature))
+    temperatures += float64(rec.Temperature)
+    s.Bounds.MinLon = math.Min(s.Bounds.MinLon, rec.Longitude)
+    s.Bounds.MinLat = math.Min(s.Bounds.MinLat, rec.Latitude)
+    s.Bounds.MaxLon = math.Max(s.Bounds.MaxLon, rec.Longitude)
+    s.Bounds.MaxLat = math.Max(s.Bounds.MaxLat, rec.Latitude)
+  }
+  s.AvgTemperature = temperatures / float64(len(recs))
+  return s
+}
+
+// Duration is the time from the first record to the last
+func (s Stats) Duration() time.Duration {
+  return s.End.Sub(s.Start)
+}
+
+// AvgSpeed is the distance over the duration, in km/h
+func (s Stats) AvgSpeed() float64 {
+  secs := s.Duration().Seconds()
+  if secs <= 0 {
+    return 0
+  }
+  return s.Distance / secs * 3.6
+}
@@ -0,0 +1,64 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "math"
+  "testing"
+  "time"
+)
+
+func Test_Summarize(t *testing.T) {
+  t.Log("Checking whether Summarize() totals a segment..")
+  recs := []Record{
+    {Latitude: 0, Longitude: 0, Altitude: 100, Speed: 10, Temperature: 10,
+      Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12}},
+    {Latitude: 0.01, Longitude: 0, Altitude: 150, Speed: 30, Temperature: 20,
+      Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 1}},
+    {Latitude: 0.01, Longitude: 0.01, Altitude: 120, Speed: 20, Temperature: 15,
+      Time: Date{Year: 2017, Month: 4, Day: 1, Hour: 12, Minute: 2}},
+  }
+  s := Summarize(recs, time.UTC)
+  if s.Count != 3 || s.Duration() != 2 * time.Minute {
+    t.Errorf("Unexpected count %d or duration %v", s.Count, s.Duration())
+  }
+  if math.Abs(s.Distance - 2223.9) > 0.5 {
+    t.Errorf("Expected about 2223.9m, got %f", s.Distance)
+  }
+  if math.Abs(s.AvgSpeed() - s.Distance / 120 * 3.6) > 1e-9 || s.MaxSpeed != 30 {
+    t.Errorf("Unexpected speeds %f, %f", s.AvgSpeed(), s.MaxSpeed)
+  }
+  if s.Ascent != 50 || s.Descent != 30 || s.MinAltitude != 100 || s.MaxAltitude != 150 {
+    t.Errorf("Unexpected altitudes %+v", s)
+  }
+  if s.AvgTemperature != 15 || s.MinTemperature != 10 || s.MaxTemperature != 20 {
+    t.Errorf("Unexpected temperatures %+v", s)
+  }
+  expected := BoundingBox{MinLon: 0, MinLat: 0, MaxLon: 0.01, MaxLat: 0.01}
+  if s.Bounds != expected {
+    t.Errorf("Expected bounds %+v, got %+v", expected, s.Bounds)
+  }
+}
+
+func Test_SummarizeEmpty(t *testing.T) {
+  t.Log("Checking whether Summarize() copes with no records..")
+  if s := Summarize(nil, nil); s.Count != 0 || s.AvgSpeed() != 0 {
+    t.Errorf("Expected empty stats, got %+v", s)
+  }
+}
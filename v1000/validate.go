@@ -0,0 +1,196 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "io/ioutil"
+  "time"
+)
+
+// Severity says how serious a validation issue is
+type Severity int
+
+const (
+  Warning Severity = iota // the data is suspect, but readable
+  Error // the file breaks the format
+)
+
+func (s Severity) String() string {
+  if s == Error {
+    return "error"
+  }
+  return "warning"
+}
+
+// MarshalText writes the severity by name
+func (s Severity) MarshalText() ([]byte, error) {
+  return []byte(s.String()), nil
+}
+
+// Rule is one of the checks made by Validate
+type Rule struct {
+  ID string
+  Severity Severity
+  Description string
+}
+
+// Limits of plausible readings
+const (
+  MaxSpeed = 1000.0 // km/h
+  MinPressure = 300.0 // hPa
+  MaxPressure = 1100.0
+  MaxTemperature = 85 // °C
+)
+
+// Rules lists every check, file level first
+var Rules = []Rule{
+  {"header-magic", Error, "the file starts with the header value 1799"},
+  {"record-alignment", Error, "the file is a whole number of 28 byte records"},
+  {"index-continuity", Warning, "each record's index is one more than the last"},
+  {"date-valid", Error, "the timestamp is a real date and time"},
+  {"time-order", Warning, "timestamps do not go backwards"},
+  {"latitude-range", Error, "the latitude is within 90 degrees"},
+  {"longitude-range", Error, "the longitude is within 180 degrees"},
+  {"speed-range", Warning, fmt.Sprintf("the speed is under %.0f km/h", MaxSpeed)},
+  {"position-jump", Warning, fmt.Sprintf("trackpoints are no further apart than %.0f km/h allows", MaxSpeed)},
+  {"heading-range", Error, "the heading is from 0 to 359 degrees"},
+  {"pressure-range", Warning, fmt.Sprintf("the pressure is from %.0f to %.0f hPa", MinPressure, MaxPressure)},
+  {"temperature-range", Warning, fmt.Sprintf("the temperature is at most %d °C", MaxTemperature)},
+}
+
+var rulesByID = func() map[string]Rule {
+  m := make(map[string]Rule)
+  for _, r := range Rules {
+    m[r.ID] = r
+  }
+  return m
+}()
+
+// Issue is a broken rule
+type Issue struct {
+  Rule string `json:"rule"`
+  Severity Severity `json:"severity"`
+  Offset int64 `json:"offset"` // of the offending bytes
+  Record int `json:"record"` // from 0, or -1 for the file as a whole
+  Message string `json:"message"`
+}
+
+type validator struct {
+  issues []Issue
+}
+
+func (v *validator) add(id string, offset int64, record int, format string, args ...interface{}) {
+  v.issues = append(v.issues, Issue{
+    Rule: id,
+    Severity: rulesByID[id].Severity,
+    Offset: offset,
+    Record: record,
+    Message: fmt.Sprintf(format, args...),
+  })
+}
+
+// Validate checks a binary file against the format's invariants and
+// returns every issue found, in file order. Records are checked even when
+// the header is wrong.
+func Validate(r io.Reader) ([]Issue, error) {
+  data, err := ioutil.ReadAll(r)
+  if err != nil {
+    return nil, err
+  }
+  var v validator
+  if len(data) < HeaderSize {
+    v.add("header-magic", 0, -1, "file is %d bytes, too short for a header", len(data))
+    return v.issues, nil
+  }
+  if magic := uint16(data[0]) << 8 | uint16(data[1]); magic != Magic {
+    v.add("header-magic", 0, -1, "header is %d (0x%04x), expected %d", magic, magic, Magic)
+  }
+
+  var prev Record
+  var prevTime time.Time
+  n := (len(data) - HeaderSize) / RecordSize
+  for i := 0; i < n; i++ {
+    start := int64(HeaderSize + i * RecordSize)
+    rec, err := ParseRecord(bytes.NewReader(data[start:start + RecordSize]))
+    if err != nil {
+      return nil, err
+    }
+    at := func(field int64) int64 { return start + field }
+
+    if i > 0 && rec.Index != prev.Index + 1 {
+      v.add("index-continuity", at(offsetIndex), i, "index %d follows %d", rec.Index, prev.Index)
+    }
+    t, ok := rec.Time.valid()
+    if !ok {
+      d := rec.Time
+      v.add("date-valid", at(offsetTime), i, "invalid timestamp %04d-%02d-%02d %02d:%02d:%02d",
+        d.Year, d.Month, d.Day, d.Hour, d.Minute, d.Second)
+    } else if !prevTime.IsZero() && t.Before(prevTime) {
+      v.add("time-order", at(offsetTime), i, "time %s is before the previous %s",
+        t.Format("2006-01-02 15:04:05"), prevTime.Format("2006-01-02 15:04:05"))
+    }
+    if rec.Latitude > 90 || rec.Latitude < -90 {
+      v.add("latitude-range", at(offsetLatitude), i, "latitude %f is out of range", rec.Latitude)
+    }
+    if rec.Longitude > 180 || rec.Longitude < -180 {
+      v.add("longitude-range", at(offsetLongitude), i, "longitude %f is out of range", rec.Longitude)
+    }
+    if rec.Speed >= MaxSpeed {
+      v.add("speed-range", at(offsetSpeed), i, "speed %.1f km/h is implausible", rec.Speed)
+    }
+    if ok && !prevTime.IsZero() && rec.Type == "T" && prev.Type == "T" {
+      if secs := t.Sub(prevTime).Seconds(); secs > 0 {
+        if kmh := Distance(&prev, &rec) / secs * 3.6; kmh >= MaxSpeed {
+          v.add("position-jump", at(offsetLatitude), i, "position moved at %.0f km/h since the previous record", kmh)
+        }
+      }
+    }
+    if rec.Heading > 359 {
+      v.add("heading-range", at(offsetHeading), i, "heading %d is out of range", rec.Heading)
+    }
+    if rec.Pressure < MinPressure || rec.Pressure > MaxPressure {
+      v.add("pressure-range", at(offsetPressure), i, "pressure %.1f hPa is implausible", rec.Pressure)
+    }
+    if rec.Temperature > MaxTemperature {
+      v.add("temperature-range", at(offsetTemperature), i, "temperature %d °C is implausible", rec.Temperature)
+    }
+
+    prev = rec
+    if ok {
+      prevTime = t
+    }
+  }
+
+  if trailing := (len(data) - HeaderSize) % RecordSize; trailing > 0 {
+    v.add("record-alignment", int64(len(data) - trailing), -1,
+      "%d trailing bytes after the last whole record", trailing)
+  }
+  return v.issues, nil
+}
+
+// valid returns the date as a UTC time, and whether every field is in range
+func (date Date) valid() (time.Time, bool) {
+  t := date.In(time.UTC)
+  ok := date.Month >= 1 && date.Month <= 12 && date.Day >= 1 && date.Hour < 24 &&
+    date.Minute < 60 && date.Second < 60 && int(date.Day) == t.Day()
+  return t, ok
+}
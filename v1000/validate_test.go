@@ -0,0 +1,99 @@
+// Copyright © 2017 Adam Snodgrass <asnodgrass@sarchasm.us>
+//
+// This file is part of columbus-v1000.
+//
+// columbus-v1000 is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// columbus-v1000 is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with columbus-v1000. If not, see <http://www.gnu.org/licenses/>.
+//
+
+package v1000
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "testing"
+)
+
+func Test_Validate(t *testing.T) {
+  t.Log("Checking whether Validate() reports each broken rule at its offset..")
+  data := inspectBinary()
+  data[0] = 0x08
+  rec := func(i int) []byte { return data[HeaderSize + i * RecordSize:] }
+  binary.BigEndian.PutUint32(rec(1)[offsetTime:], binary.BigEndian.Uint32(rec(1)[offsetTime:]) - 30)
+  binary.BigEndian.PutUint16(rec(1)[offsetHeading:], 400)
+  date := binary.BigEndian.Uint32(rec(2)[offsetTime:])
+  binary.BigEndian.PutUint32(rec(2)[offsetTime:], date &^ (0xf << 22) | 13 << 22)
+
+  issues, err := Validate(bytes.NewReader(data))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  expected := []struct {
+    rule string
+    severity Severity
+    offset int64
+    record int
+  }{
+    {"header-magic", Error, 0, -1},
+    {"time-order", Warning, 34, 1},
+    {"heading-range", Error, 52, 1},
+    {"index-continuity", Warning, 58, 2},
+    {"date-valid", Error, 62, 2},
+    {"record-alignment", Error, 86, -1},
+  }
+  if len(issues) != len(expected) {
+    t.Fatalf("Expected %d issues, got %+v", len(expected), issues)
+  }
+  for i, e := range expected {
+    got := issues[i]
+    if got.Rule != e.rule || got.Severity != e.severity || got.Offset != e.offset || got.Record != e.record {
+      t.Errorf("Expected %+v, got %+v", e, got)
+    }
+  }
+
+  issues, _ = Validate(bytes.NewReader(testBinary))
+  if len(issues) != 0 {
+    t.Errorf("Expected no issues in testBinary, got %+v", issues)
+  }
+}
+
+func Test_ValidateRanges(t *testing.T) {
+  t.Log("Checking whether Validate() flags implausible readings..")
+  data := append([]byte{}, testBinary...)
+  second := append([]byte{}, testBinary[HeaderSize:]...)
+  second[2] = 43
+  second[3] = 0x0c // a trackpoint, like the first
+  data[HeaderSize + 3] = 0x0c
+  binary.BigEndian.PutUint32(second[offsetTime:], binary.BigEndian.Uint32(second[offsetTime:]) + 1)
+  binary.BigEndian.PutUint32(second[offsetLatitude:], 95000000)
+  binary.BigEndian.PutUint16(second[offsetSpeed:], 12000)
+  binary.BigEndian.PutUint16(second[offsetPressure:], 200)
+  binary.BigEndian.PutUint16(second[offsetTemperature:], 6000)
+  issues, err := Validate(bytes.NewReader(append(data, second...)))
+  if err != nil {
+    t.Fatalf("Unexpected error: %v", err)
+  }
+  var rules []string
+  for _, issue := range issues {
+    rules = append(rules, issue.Rule)
+  }
+  expected := "[latitude-range speed-range position-jump pressure-range temperature-range]"
+  if got := fmt.Sprint(rules); got != expected {
+    t.Errorf("Expected %s, got %s", expected, got)
+  }
+
+  if issues, _ := Validate(bytes.NewReader([]byte{7})); len(issues) != 1 || issues[0].Rule != "header-magic" {
+    t.Errorf("Expected a short file to fail header-magic, got %+v", issues)
+  }
+}